@@ -1,17 +1,15 @@
 package cell
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"github/com/codecrafters-io/sqlite-starter-go/app/header"
 	"github/com/codecrafters-io/sqlite-starter-go/app/utils"
-	"os"
 )
 
 type NewInteriorTablePageCellRequest struct {
 	PageType     header.PageType
-	PageOffset   uint64
+	Page         []byte
 	HeaderOffset uint64
 	CellCount    uint64
 }
@@ -23,17 +21,18 @@ type InteriorTablePageCell struct {
 
 type InteriorTablePageCells []*InteriorTablePageCell
 
-func NewInteriorTablePageCells(f *os.File, r *NewInteriorTablePageCellRequest) (InteriorTablePageCells, error) {
+func NewInteriorTablePageCells(r *NewInteriorTablePageCellRequest) (InteriorTablePageCells, error) {
 	cells := make(InteriorTablePageCells, 0)
 	for i := uint64(0); i < r.CellCount; i++ {
-		cellContentOffset, err := GetCellContentOffset(f, int64(r.PageOffset+r.HeaderOffset+2*i))
+		cellContentOffset, err := GetCellContentOffset(r.Page, int64(r.HeaderOffset+2*i))
 		if err != nil {
 			return nil, err
 		}
 
-		cell, err := GetInteriorTablePageCell(f, &GetInteriorTablePageCellRequest{
+		cell, err := GetInteriorTablePageCell(&GetInteriorTablePageCellRequest{
 			PageType: r.PageType,
-			Offset:   int64(r.PageOffset + uint64(cellContentOffset)),
+			Page:     r.Page,
+			Offset:   int64(cellContentOffset),
 		})
 		if err != nil {
 			return nil, err
@@ -45,25 +44,21 @@ func NewInteriorTablePageCells(f *os.File, r *NewInteriorTablePageCellRequest) (
 
 type GetInteriorTablePageCellRequest struct {
 	PageType header.PageType
+	Page     []byte
 	Offset   int64
 }
 
-func GetInteriorTablePageCell(f *os.File, r *GetInteriorTablePageCellRequest) (*InteriorTablePageCell, error) {
+func GetInteriorTablePageCell(r *GetInteriorTablePageCellRequest) (*InteriorTablePageCell, error) {
 	if r.PageType != header.InteriorTableBTree && r.PageType != header.InteriorIndexBTree {
 		return nil, fmt.Errorf("GetInteriorTablePageCell() is not implemented for pageType: %v", r.PageType)
 	}
 
-	buf := make([]byte, 4)
-	if _, err := f.ReadAt(buf, r.Offset); err != nil {
-		return nil, err
-	}
-
-	var leftChildPageNum uint32
-	if err := binary.Read(bytes.NewReader(buf), binary.BigEndian, &leftChildPageNum); err != nil {
-		return nil, err
+	if r.Offset < 0 || r.Offset+4 > int64(len(r.Page)) {
+		return nil, fmt.Errorf("cell offset %d is out of the page", r.Offset)
 	}
+	leftChildPageNum := binary.BigEndian.Uint32(r.Page[r.Offset : r.Offset+4])
 
-	rowID, _, err := utils.ReadUvarint(f, r.Offset+4)
+	rowID, _, err := utils.ReadUvarint(r.Page, r.Offset+4)
 	if err != nil {
 		return nil, err
 	}
@@ -1,19 +1,16 @@
 package cell
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"github/com/codecrafters-io/sqlite-starter-go/app/header"
-	"github/com/codecrafters-io/sqlite-starter-go/app/parser"
+	"github/com/codecrafters-io/sqlite-starter-go/app/pager"
 	"github/com/codecrafters-io/sqlite-starter-go/app/utils"
-	"os"
-	"strconv"
 )
 
+// Where filters cells while they are read, Match being called with every column of the cell decoded
 type Where struct {
-	Clause    *parser.WhereClause
-	ColumnPos int
+	Match func(c *LeafTablePageCell) (bool, error)
 }
 
 type LeafTablePageCell struct {
@@ -23,29 +20,47 @@ type LeafTablePageCell struct {
 
 type LeafTablePageCells []*LeafTablePageCell
 
+// NewLeafTablePageCell builds a cell out of decoded values, e.g. rows read back from a sort
+func NewLeafTablePageCell(rowID uint64, values []Value) *LeafTablePageCell {
+	srs := make([]*SerialTypeAndRecord, len(values))
+	for i, v := range values {
+		srs[i] = NewSerialTypeAndRecord(v)
+	}
+	return &LeafTablePageCell{
+		RowID:                rowID,
+		SerialTypeAndRecords: srs,
+	}
+}
+
+// Values decodes the records of the cell, resolving auto increment primary keys to the row id
+func (c *LeafTablePageCell) Values() ([]Value, error) {
+	values := make([]Value, len(c.SerialTypeAndRecords))
+	for i, sr := range c.SerialTypeAndRecords {
+		if sr.SerialType == SerialTypeAutoIncrPrimaryKey {
+			values[i] = IntegerValue(int64(c.RowID))
+			continue
+		}
+
+		v, err := sr.Value()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
 func (cs LeafTablePageCells) RowsInStrings() ([][]string, error) {
 	rows := make([][]string, len(cs))
 	for i, c := range cs {
-		row := make([]string, 0)
-		for _, sr := range c.SerialTypeAndRecords {
-			switch sr.SerialType {
-			case SerialTypeString, SerialTypeNull:
-				str, err := sr.String()
-				if err != nil {
-					return nil, err
-				}
-				row = append(row, str)
-			case SerialTypeI8:
-				i8, err := sr.Int8()
-				if err != nil {
-					return nil, err
-				}
-				row = append(row, fmt.Sprintf("%d", i8))
-			case SerialTypeAutoIncrPrimaryKey:
-				row = append(row, fmt.Sprintf("%d", c.RowID))
-			default:
-				return nil, fmt.Errorf("print() is not implemented for serial type %v", sr.SerialType)
-			}
+		values, err := c.Values()
+		if err != nil {
+			return nil, err
+		}
+
+		row := make([]string, len(values))
+		for j, v := range values {
+			row[j] = v.String()
 		}
 		rows[i] = row
 	}
@@ -54,9 +69,11 @@ func (cs LeafTablePageCells) RowsInStrings() ([][]string, error) {
 
 type NewLeafTablePageCellRequest struct {
 	PageType           header.PageType
-	PageOffset         uint64
+	Page               []byte
 	HeaderOffset       uint64
 	CellCount          uint64
+	UsableSize         uint64
+	TextEncoding       header.TextEncoding
 	ColumnPosList      []int
 	AutoIncrKeyPosList []int
 	Where              *Where
@@ -64,25 +81,31 @@ type NewLeafTablePageCellRequest struct {
 
 type NewLeafTablePageCellsByPKsRequest struct {
 	PageType           header.PageType
-	PageOffset         uint64
+	Page               []byte
 	HeaderOffset       uint64
 	CellCount          uint64
+	UsableSize         uint64
+	TextEncoding       header.TextEncoding
 	ColumnPosList      []int
 	AutoIncrKeyPosList []int
 	PrimaryKeys        []int
+	Where              *Where
 }
 
-func NewLeafTablePageCells(f *os.File, r *NewLeafTablePageCellRequest) (LeafTablePageCells, error) {
+func NewLeafTablePageCells(p *pager.Pager, r *NewLeafTablePageCellRequest) (LeafTablePageCells, error) {
 	cells := make(LeafTablePageCells, 0)
 	for i := uint64(0); i < r.CellCount; i++ {
-		cellContentOffset, err := GetCellContentOffset(f, int64(r.PageOffset+r.HeaderOffset+2*i))
+		cellContentOffset, err := GetCellContentOffset(r.Page, int64(r.HeaderOffset+2*i))
 		if err != nil {
 			return nil, err
 		}
 
-		cell, err := GetLeafTablePageCell(f, &GetLeafTablePageCellRequest{
+		cell, err := GetLeafTablePageCell(p, &GetLeafTablePageCellRequest{
 			PageType:           r.PageType,
-			Offset:             int64(r.PageOffset + uint64(cellContentOffset)),
+			Page:               r.Page,
+			Offset:             int64(cellContentOffset),
+			UsableSize:         r.UsableSize,
+			TextEncoding:       r.TextEncoding,
 			ColumnPosList:      r.ColumnPosList,
 			AutoIncrKeyPosList: r.AutoIncrKeyPosList,
 			Where:              r.Where,
@@ -98,73 +121,67 @@ func NewLeafTablePageCells(f *os.File, r *NewLeafTablePageCellRequest) (LeafTabl
 	return cells, nil
 }
 
-func GetCellContentOffset(f *os.File, offset int64) (uint16, error) {
-	buf := make([]byte, 2)
-	if _, err := f.ReadAt(buf, offset); err != nil {
-		return 0, err
+// GetCellContentOffset reads the cell pointer at offset in page, which is where the cell starts in the page
+func GetCellContentOffset(page []byte, offset int64) (uint16, error) {
+	if offset < 0 || offset+2 > int64(len(page)) {
+		return 0, fmt.Errorf("cell pointer offset %d is out of the page", offset)
 	}
-
-	var off uint16
-	if err := binary.Read(bytes.NewReader(buf), binary.BigEndian, &off); err != nil {
-		return 0, err
-	}
-	return off, nil
+	return binary.BigEndian.Uint16(page[offset : offset+2]), nil
 }
 
 type GetLeafTablePageCellRequest struct {
 	PageType           header.PageType
+	Page               []byte
 	Offset             int64
+	UsableSize         uint64
+	TextEncoding       header.TextEncoding
 	ColumnPosList      []int
 	AutoIncrKeyPosList []int
 	Where              *Where
 }
 
-func GetLeafTablePageCell(f *os.File, r *GetLeafTablePageCellRequest) (*LeafTablePageCell, error) {
+func GetLeafTablePageCell(p *pager.Pager, r *GetLeafTablePageCellRequest) (*LeafTablePageCell, error) {
 	if r.PageType != header.LeafTableBTree {
 		return nil, fmt.Errorf("GetLeafTablePageCell() is not implemented for pageType: %v", r.PageType)
 	}
 
 	readAtOffset := r.Offset
 
-	payloadBytes, read, err := utils.ReadUvarint(f, readAtOffset)
+	payloadBytes, read, err := utils.ReadUvarint(r.Page, readAtOffset)
 	if err != nil {
 		return nil, err
 	}
 	readAtOffset += int64(read)
 
-	rowID, read, err := utils.ReadUvarint(f, readAtOffset)
+	rowID, read, err := utils.ReadUvarint(r.Page, readAtOffset)
 	if err != nil {
 		return nil, err
 	}
 	readAtOffset += int64(read)
 
-	recordHeaderSize, read, err := utils.ReadUvarint(f, readAtOffset)
+	payload, err := GetPayload(p, &GetPayloadRequest{
+		PageType:    r.PageType,
+		Page:        r.Page,
+		Offset:      readAtOffset,
+		PayloadSize: payloadBytes,
+		UsableSize:  r.UsableSize,
+	})
 	if err != nil {
 		return nil, err
 	}
-	readAtOffset += int64(read)
 
-	scs := make([]*SerialTypeAndContentSize, 0)
-	headerRemain := recordHeaderSize - uint64(read) // The varint value is the size of the header in bytes including the size varint itself.
-	currentColumnPos := 0
-	for headerRemain > 0 {
-		serialType, read, err := utils.ReadUvarint(f, readAtOffset)
-		if err != nil {
-			return nil, err
-		}
-		// has possibility to be auto increment primary key when null
-		if serialType == uint64(SerialTypeNull) {
-			if utils.SliceIncludes(r.AutoIncrKeyPosList, currentColumnPos) {
-				serialType = uint64(SerialTypeAutoIncrPrimaryKey)
-			}
-		}
-		scs = append(scs, GetSerialTypeAndContentSize(serialType))
-		headerRemain -= uint64(read)
-		readAtOffset += int64(read)
-		currentColumnPos++
+	srs, err := NewSerialTypeAndRecords(payload, r.AutoIncrKeyPosList)
+	if err != nil {
+		return nil, err
+	}
+	decodeTextRecords(srs, r.TextEncoding)
+
+	c := &LeafTablePageCell{
+		RowID:                rowID,
+		SerialTypeAndRecords: srs,
 	}
 
-	match, err := doesCellMatchCondition(f, scs, readAtOffset, r.Where)
+	match, err := doesCellMatchCondition(c, r.Where)
 	if err != nil {
 		return nil, err
 	}
@@ -172,114 +189,35 @@ func GetLeafTablePageCell(f *os.File, r *GetLeafTablePageCellRequest) (*LeafTabl
 		return nil, nil
 	}
 
-	srs := make([]*SerialTypeAndRecord, 0)
-
-	if len(r.ColumnPosList) == 0 {
-		bodyRemain := payloadBytes - recordHeaderSize
-		for bodyRemain > 0 {
-			for _, sc := range scs {
-				off := readAtOffset
-				bodyRemain -= sc.ContentSize
-				readAtOffset += int64(sc.ContentSize)
-
-				buf := make([]byte, sc.ContentSize)
-				if _, err := f.ReadAt(buf, off); err != nil {
-					return nil, err
-				}
-
-				srs = append(srs, &SerialTypeAndRecord{
-					SerialType: sc.SerialType,
-					Record:     buf,
-				})
-			}
-		}
-	} else {
-		for _, columnPos := range r.ColumnPosList {
-			off := readAtOffset
-			for i, sc := range scs {
-				if i != columnPos {
-					off += int64(sc.ContentSize)
-					continue
-				}
-
-				buf := make([]byte, sc.ContentSize)
-				if _, err := f.ReadAt(buf, off); err != nil {
-					return nil, err
-				}
-
-				srs = append(srs, &SerialTypeAndRecord{
-					SerialType: sc.SerialType,
-					Record:     buf,
-				})
-				off += int64(sc.ContentSize)
-			}
-		}
-	}
-
-	return &LeafTablePageCell{
-		RowID:                rowID,
-		SerialTypeAndRecords: srs,
-	}, nil
+	c.SerialTypeAndRecords = SelectColumns(srs, r.ColumnPosList)
+	return c, nil
 }
 
-func doesCellMatchCondition(f *os.File, scs []*SerialTypeAndContentSize, currentOffset int64, where *Where) (bool, error) {
-	if where == nil || where.Clause == nil {
+func doesCellMatchCondition(c *LeafTablePageCell, where *Where) (bool, error) {
+	if where == nil || where.Match == nil {
 		return true, nil
 	}
-
-	wherePosOffset := currentOffset
-	for i, sc := range scs {
-		if i == where.ColumnPos {
-			buf := make([]byte, sc.ContentSize)
-			if _, err := f.ReadAt(buf, wherePosOffset); err != nil {
-				return false, err
-			}
-
-			sr := &SerialTypeAndRecord{
-				SerialType: sc.SerialType,
-				Record:     buf,
-			}
-
-			switch sr.SerialType {
-			case SerialTypeString, SerialTypeNull:
-				str, err := sr.String()
-				if err != nil {
-					return false, err
-				}
-				if str != where.Clause.Value {
-					return false, nil
-				}
-			case SerialTypeI8:
-				i8, err := sr.Int8()
-				if err != nil {
-					return false, err
-				}
-				if strconv.Itoa(int(i8)) != where.Clause.Value {
-					return false, nil
-				}
-			default:
-				return false, fmt.Errorf("where-check is not implemented for serial type %v", sc.SerialType)
-			}
-		}
-		wherePosOffset += int64(sc.ContentSize)
-	}
-	return true, nil
+	return where.Match(c)
 }
 
-func NewLeafTablePageCellsByPK(f *os.File, r *NewLeafTablePageCellsByPKsRequest) (LeafTablePageCells, error) {
+func NewLeafTablePageCellsByPK(p *pager.Pager, r *NewLeafTablePageCellsByPKsRequest) (LeafTablePageCells, error) {
 	cells := make(LeafTablePageCells, 0)
 	for i := uint64(0); i < r.CellCount; i++ {
-		cellContentOffset, err := GetCellContentOffset(f, int64(r.PageOffset+r.HeaderOffset+2*i))
+		cellContentOffset, err := GetCellContentOffset(r.Page, int64(r.HeaderOffset+2*i))
 		if err != nil {
 			return nil, err
 		}
 
-		cell, err := GetLeafTablePageCellByPK(f, &GetLeafTablePageCellByPKsRequest{
+		cell, err := GetLeafTablePageCellByPK(p, &GetLeafTablePageCellByPKsRequest{
 			PageType:           r.PageType,
-			Offset:             int64(r.PageOffset + uint64(cellContentOffset)),
+			Page:               r.Page,
+			Offset:             int64(cellContentOffset),
+			UsableSize:         r.UsableSize,
+			TextEncoding:       r.TextEncoding,
 			ColumnPosList:      r.ColumnPosList,
 			AutoIncrKeyPosList: r.AutoIncrKeyPosList,
 			PrimaryKeys:        r.PrimaryKeys,
+			Where:              r.Where,
 		})
 		if err != nil {
 			return nil, err
@@ -294,26 +232,30 @@ func NewLeafTablePageCellsByPK(f *os.File, r *NewLeafTablePageCellsByPKsRequest)
 
 type GetLeafTablePageCellByPKsRequest struct {
 	PageType           header.PageType
+	Page               []byte
 	Offset             int64
+	UsableSize         uint64
+	TextEncoding       header.TextEncoding
 	ColumnPosList      []int
 	AutoIncrKeyPosList []int
 	PrimaryKeys        []int
+	Where              *Where
 }
 
-func GetLeafTablePageCellByPK(f *os.File, r *GetLeafTablePageCellByPKsRequest) (*LeafTablePageCell, error) {
+func GetLeafTablePageCellByPK(p *pager.Pager, r *GetLeafTablePageCellByPKsRequest) (*LeafTablePageCell, error) {
 	if r.PageType != header.LeafTableBTree {
 		return nil, fmt.Errorf("GetLeafTablePageCell() is not implemented for pageType: %v", r.PageType)
 	}
 
 	readAtOffset := r.Offset
 
-	payloadBytes, read, err := utils.ReadUvarint(f, readAtOffset)
+	payloadBytes, read, err := utils.ReadUvarint(r.Page, readAtOffset)
 	if err != nil {
 		return nil, err
 	}
 	readAtOffset += int64(read)
 
-	rowID, read, err := utils.ReadUvarint(f, readAtOffset)
+	rowID, read, err := utils.ReadUvarint(r.Page, readAtOffset)
 	if err != nil {
 		return nil, err
 	}
@@ -323,77 +265,36 @@ func GetLeafTablePageCellByPK(f *os.File, r *GetLeafTablePageCellByPKsRequest) (
 
 	readAtOffset += int64(read)
 
-	recordHeaderSize, read, err := utils.ReadUvarint(f, readAtOffset)
+	payload, err := GetPayload(p, &GetPayloadRequest{
+		PageType:    r.PageType,
+		Page:        r.Page,
+		Offset:      readAtOffset,
+		PayloadSize: payloadBytes,
+		UsableSize:  r.UsableSize,
+	})
 	if err != nil {
 		return nil, err
 	}
-	readAtOffset += int64(read)
 
-	scs := make([]*SerialTypeAndContentSize, 0)
-	headerRemain := recordHeaderSize - uint64(read) // The varint value is the size of the header in bytes including the size varint itself.
-	currentColumnPos := 0
-	for headerRemain > 0 {
-		serialType, read, err := utils.ReadUvarint(f, readAtOffset)
-		if err != nil {
-			return nil, err
-		}
-		// has possibility to be auto increment primary key when null
-		if serialType == uint64(SerialTypeNull) {
-			if utils.SliceIncludes(r.AutoIncrKeyPosList, currentColumnPos) {
-				serialType = uint64(SerialTypeAutoIncrPrimaryKey)
-			}
-		}
-		scs = append(scs, GetSerialTypeAndContentSize(serialType))
-		headerRemain -= uint64(read)
-		readAtOffset += int64(read)
-		currentColumnPos++
-	}
-
-	srs := make([]*SerialTypeAndRecord, 0)
-	if len(r.ColumnPosList) == 0 {
-		bodyRemain := payloadBytes - recordHeaderSize
-		for bodyRemain > 0 {
-			for _, sc := range scs {
-				off := readAtOffset
-				bodyRemain -= sc.ContentSize
-				readAtOffset += int64(sc.ContentSize)
-
-				buf := make([]byte, sc.ContentSize)
-				if _, err := f.ReadAt(buf, off); err != nil {
-					return nil, err
-				}
-
-				srs = append(srs, &SerialTypeAndRecord{
-					SerialType: sc.SerialType,
-					Record:     buf,
-				})
-			}
-		}
-	} else {
-		for _, columnPos := range r.ColumnPosList {
-			off := readAtOffset
-			for i, sc := range scs {
-				if i != columnPos {
-					off += int64(sc.ContentSize)
-					continue
-				}
-
-				buf := make([]byte, sc.ContentSize)
-				if _, err := f.ReadAt(buf, off); err != nil {
-					return nil, err
-				}
-
-				srs = append(srs, &SerialTypeAndRecord{
-					SerialType: sc.SerialType,
-					Record:     buf,
-				})
-				off += int64(sc.ContentSize)
-			}
-		}
+	srs, err := NewSerialTypeAndRecords(payload, r.AutoIncrKeyPosList)
+	if err != nil {
+		return nil, err
 	}
+	decodeTextRecords(srs, r.TextEncoding)
 
-	return &LeafTablePageCell{
+	c := &LeafTablePageCell{
 		RowID:                rowID,
 		SerialTypeAndRecords: srs,
-	}, nil
+	}
+
+	match, err := doesCellMatchCondition(c, r.Where)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, nil
+	}
+
+	c.SerialTypeAndRecords = SelectColumns(srs, r.ColumnPosList)
+	return c, nil
 }
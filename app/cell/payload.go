@@ -0,0 +1,93 @@
+package cell
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/header"
+	"github/com/codecrafters-io/sqlite-starter-go/app/pager"
+)
+
+const (
+	overflowPageNumSize = 4
+)
+
+type GetPayloadRequest struct {
+	PageType header.PageType
+	Page     []byte
+	// Offset is where the payload starts in Page, right after the cell's varints
+	Offset      int64
+	PayloadSize uint64
+	UsableSize  uint64
+}
+
+// GetLocalPayloadSize returns how many bytes of a payload are stored on the b-tree page itself.
+// The rest spills onto the overflow page chain. See https://www.sqlite.org/fileformat.html#cellformat
+func GetLocalPayloadSize(pageType header.PageType, payloadSize, usableSize uint64) (uint64, error) {
+	var maxLocal uint64
+	switch pageType {
+	case header.LeafTableBTree:
+		maxLocal = usableSize - 35
+	case header.LeafIndexBTree, header.InteriorIndexBTree:
+		maxLocal = ((usableSize-12)*64)/255 - 23
+	default:
+		return 0, fmt.Errorf("GetLocalPayloadSize() is not implemented for pageType: %v", pageType)
+	}
+
+	if payloadSize <= maxLocal {
+		return payloadSize, nil
+	}
+
+	minLocal := ((usableSize-12)*32)/255 - 23
+	local := minLocal + (payloadSize-minLocal)%(usableSize-4)
+	if local <= maxLocal {
+		return local, nil
+	}
+	return minLocal, nil
+}
+
+// GetPayload reads the whole payload of a cell, following the overflow page chain when it does not fit in the page
+func GetPayload(p *pager.Pager, r *GetPayloadRequest) ([]byte, error) {
+	localSize, err := GetLocalPayloadSize(r.PageType, r.PayloadSize, r.UsableSize)
+	if err != nil {
+		return nil, err
+	}
+
+	end := r.Offset + int64(localSize)
+	if localSize < r.PayloadSize {
+		end += overflowPageNumSize
+	}
+	if r.Offset < 0 || end > int64(len(r.Page)) {
+		return nil, fmt.Errorf("payload of %d bytes at offset %d is out of the page", r.PayloadSize, r.Offset)
+	}
+
+	// a payload stored in the page is sliced out of it rather than copied, the page being left unmodified
+	if localSize == r.PayloadSize {
+		return r.Page[r.Offset:end:end], nil
+	}
+
+	payload := make([]byte, localSize, r.PayloadSize)
+	copy(payload, r.Page[r.Offset:])
+
+	overflowPageNum := binary.BigEndian.Uint32(r.Page[end-overflowPageNumSize : end])
+	overflowContentSize := r.UsableSize - overflowPageNumSize
+	for remain := r.PayloadSize - localSize; remain > 0; {
+		if overflowPageNum == 0 {
+			return nil, fmt.Errorf("overflow page chain ended with %d bytes of payload remaining", remain)
+		}
+
+		overflowPage, err := p.Page(uint(overflowPageNum))
+		if err != nil {
+			return nil, err
+		}
+
+		size := min(remain, overflowContentSize)
+		if overflowPageNumSize+size > uint64(len(overflowPage)) {
+			return nil, fmt.Errorf("overflow page %d is shorter than its content", overflowPageNum)
+		}
+		overflowPageNum = binary.BigEndian.Uint32(overflowPage[:overflowPageNumSize])
+		payload = append(payload, overflowPage[overflowPageNumSize:overflowPageNumSize+size]...)
+		remain -= size
+	}
+
+	return payload, nil
+}
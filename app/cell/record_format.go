@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/utils"
+	"math"
 )
 
 type SerialType int
@@ -53,6 +55,11 @@ func (r Record) Int16() (int16, error) {
 	return i16, nil
 }
 
+func (r Record) Int24() (int32, error) {
+	i, err := r.signedInt(3)
+	return int32(i), err
+}
+
 func (r Record) Int32() (int32, error) {
 	var i32 int32
 	if err := binary.Read(bytes.NewReader(r), binary.BigEndian, &i32); err != nil {
@@ -61,20 +68,51 @@ func (r Record) Int32() (int32, error) {
 	return i32, nil
 }
 
+func (r Record) Int48() (int64, error) {
+	return r.signedInt(6)
+}
+
+func (r Record) Int64() (int64, error) {
+	var i64 int64
+	if err := binary.Read(bytes.NewReader(r), binary.BigEndian, &i64); err != nil {
+		return 0, err
+	}
+	return i64, nil
+}
+
+func (r Record) Float64() (float64, error) {
+	var f64 float64
+	if err := binary.Read(bytes.NewReader(r), binary.BigEndian, &f64); err != nil {
+		return 0, err
+	}
+	return f64, nil
+}
+
+// signedInt decodes a big-endian two's complement integer of the given width, sign-extending it to 64 bits
+func (r Record) signedInt(size int) (int64, error) {
+	if len(r) < size {
+		return 0, fmt.Errorf("record has %d bytes, expected %d", len(r), size)
+	}
+
+	var u uint64
+	for _, b := range r[:size] {
+		u = u<<8 | uint64(b)
+	}
+	shift := 64 - 8*size
+	return int64(u<<shift) >> shift, nil
+}
+
 type SerialTypeAndRecord struct {
 	SerialType SerialType
 	Record     Record
 }
 
 func (sr *SerialTypeAndRecord) String() (string, error) {
-	switch sr.SerialType {
-	case SerialTypeString:
-		return string(sr.Record), nil
-	case SerialTypeNull:
-		return "<null>", nil
-	default:
-		return "", fmt.Errorf("SerialTypeAndRecord.String() is not implemented for SerialType: %v", sr.SerialType)
+	v, err := sr.Value()
+	if err != nil {
+		return "", err
 	}
+	return v.String(), nil
 }
 
 func (sr *SerialTypeAndRecord) Int8() (int8, error) {
@@ -92,30 +130,80 @@ func (sr *SerialTypeAndRecord) Int16() (int16, error) {
 }
 
 func (sr *SerialTypeAndRecord) Int32() (int32, error) {
-	if sr.SerialType != SerialTypeI32 && sr.SerialType != SerialTypeI24 {
+	switch sr.SerialType {
+	case SerialTypeI24:
+		return sr.Record.Int24()
+	case SerialTypeI32:
+		return sr.Record.Int32()
+	default:
 		return 0, fmt.Errorf("SerialTypeAndRecord.Int32() is not implemented for SerialType: %v", sr.SerialType)
 	}
-	// prepend 0 in order to avoid EOF error when reading binaries and to convert to int32
-	if sr.SerialType == SerialTypeI24 {
-		sr.Record = append([]byte{0}, sr.Record...)
-	}
-	return sr.Record.Int32()
 }
 
-func (sr *SerialTypeAndRecord) Int() (int, error) {
+func (sr *SerialTypeAndRecord) Int64() (int64, error) {
 	switch sr.SerialType {
 	case SerialTypeI8:
 		i8, err := sr.Int8()
-		return int(i8), err
+		return int64(i8), err
 	case SerialTypeI16:
 		i16, err := sr.Int16()
-		return int(i16), err
+		return int64(i16), err
 	case SerialTypeI24, SerialTypeI32:
-		i, err := sr.Int32()
-		return int(i), err
+		i32, err := sr.Int32()
+		return int64(i32), err
+	case SerialTypeI48:
+		return sr.Record.Int48()
+	case SerialTypeI64:
+		return sr.Record.Int64()
+	case SerialTypeI0:
+		return 0, nil
+	case SerialTypeI1:
+		return 1, nil
 	default:
+		return 0, fmt.Errorf("SerialTypeAndRecord.Int64() is not implemented for SerialType: %v", sr.SerialType)
+	}
+}
+
+func (sr *SerialTypeAndRecord) Int() (int, error) {
+	i64, err := sr.Int64()
+	if err != nil {
 		return 0, fmt.Errorf("SerialTypeAndRecord.Int() is not implemented for SerialType: %v", sr.SerialType)
 	}
+	return int(i64), nil
+}
+
+func (sr *SerialTypeAndRecord) Float64() (float64, error) {
+	if sr.SerialType != SerialTypeF64 {
+		return 0, fmt.Errorf("SerialTypeAndRecord.Float64() is not implemented for SerialType: %v", sr.SerialType)
+	}
+	return sr.Record.Float64()
+}
+
+// Value decodes the record into its storage class.
+// SerialTypeAutoIncrPrimaryKey has no content of its own, use LeafTablePageCell.Values to resolve it to the row id.
+func (sr *SerialTypeAndRecord) Value() (Value, error) {
+	switch sr.SerialType {
+	case SerialTypeNull:
+		return NullValue(), nil
+	case SerialTypeI8, SerialTypeI16, SerialTypeI24, SerialTypeI32, SerialTypeI48, SerialTypeI64, SerialTypeI0, SerialTypeI1:
+		i64, err := sr.Int64()
+		if err != nil {
+			return Value{}, err
+		}
+		return IntegerValue(i64), nil
+	case SerialTypeF64:
+		f64, err := sr.Float64()
+		if err != nil {
+			return Value{}, err
+		}
+		return RealValue(f64), nil
+	case SerialTypeString:
+		return TextValue(string(sr.Record)), nil
+	case SerialTypeBLOB:
+		return BlobValue(sr.Record), nil
+	default:
+		return Value{}, fmt.Errorf("SerialTypeAndRecord.Value() is not implemented for SerialType: %v", sr.SerialType)
+	}
 }
 
 func GetSerialTypeAndContentSize(num uint64) *SerialTypeAndContentSize {
@@ -170,3 +258,141 @@ func GetSerialTypeAndContentSize(num uint64) *SerialTypeAndContentSize {
 
 	return &sc
 }
+
+// NewSerialTypeAndRecords decodes a record, its header and its body, out of the full payload of a cell
+func NewSerialTypeAndRecords(payload []byte, autoIncrKeyPosList []int) ([]*SerialTypeAndRecord, error) {
+	recordHeaderSize, read := utils.Uvarint(payload)
+	if recordHeaderSize > uint64(len(payload)) {
+		return nil, fmt.Errorf("record header size %d exceeds payload size %d", recordHeaderSize, len(payload))
+	}
+
+	scs := make([]*SerialTypeAndContentSize, 0)
+	headerOffset := uint64(read) // The varint value is the size of the header in bytes including the size varint itself.
+	currentColumnPos := 0
+	for headerOffset < recordHeaderSize {
+		serialType, read := utils.Uvarint(payload[headerOffset:recordHeaderSize])
+		// has possibility to be auto increment primary key when null
+		if serialType == uint64(SerialTypeNull) {
+			if utils.SliceIncludes(autoIncrKeyPosList, currentColumnPos) {
+				serialType = uint64(SerialTypeAutoIncrPrimaryKey)
+			}
+		}
+		scs = append(scs, GetSerialTypeAndContentSize(serialType))
+		headerOffset += uint64(read)
+		currentColumnPos++
+	}
+
+	srs := make([]*SerialTypeAndRecord, 0, len(scs))
+	bodyOffset := recordHeaderSize
+	for _, sc := range scs {
+		if bodyOffset+sc.ContentSize > uint64(len(payload)) {
+			return nil, fmt.Errorf("record body exceeds payload size %d", len(payload))
+		}
+		srs = append(srs, &SerialTypeAndRecord{
+			SerialType: sc.SerialType,
+			Record:     payload[bodyOffset : bodyOffset+sc.ContentSize],
+		})
+		bodyOffset += sc.ContentSize
+	}
+	return srs, nil
+}
+
+// SelectColumns picks the records at columnPosList, keeping all of them when columnPosList is empty.
+// Columns missing from the record, e.g. added by ALTER TABLE after the row was written, are read as NULL.
+func SelectColumns(srs []*SerialTypeAndRecord, columnPosList []int) []*SerialTypeAndRecord {
+	if len(columnPosList) == 0 {
+		return srs
+	}
+
+	selected := make([]*SerialTypeAndRecord, 0, len(columnPosList))
+	for _, columnPos := range columnPosList {
+		if columnPos >= len(srs) {
+			selected = append(selected, &SerialTypeAndRecord{SerialType: SerialTypeNull})
+			continue
+		}
+		selected = append(selected, srs[columnPos])
+	}
+	return selected
+}
+
+// NewSerialTypeAndRecord encodes v with the smallest serial type able to hold it
+func NewSerialTypeAndRecord(v Value) *SerialTypeAndRecord {
+	switch v.Type {
+	case ValueTypeInteger:
+		switch i := v.Integer; {
+		case i == 0:
+			return &SerialTypeAndRecord{SerialType: SerialTypeI0}
+		case i == 1:
+			return &SerialTypeAndRecord{SerialType: SerialTypeI1}
+		case -1<<7 <= i && i < 1<<7:
+			return &SerialTypeAndRecord{SerialType: SerialTypeI8, Record: bigEndian(i, 1)}
+		case -1<<15 <= i && i < 1<<15:
+			return &SerialTypeAndRecord{SerialType: SerialTypeI16, Record: bigEndian(i, 2)}
+		case -1<<23 <= i && i < 1<<23:
+			return &SerialTypeAndRecord{SerialType: SerialTypeI24, Record: bigEndian(i, 3)}
+		case -1<<31 <= i && i < 1<<31:
+			return &SerialTypeAndRecord{SerialType: SerialTypeI32, Record: bigEndian(i, 4)}
+		case -1<<47 <= i && i < 1<<47:
+			return &SerialTypeAndRecord{SerialType: SerialTypeI48, Record: bigEndian(i, 6)}
+		default:
+			return &SerialTypeAndRecord{SerialType: SerialTypeI64, Record: bigEndian(i, 8)}
+		}
+	case ValueTypeReal:
+		return &SerialTypeAndRecord{SerialType: SerialTypeF64, Record: bigEndian(int64(math.Float64bits(v.Real)), 8)}
+	case ValueTypeText:
+		return &SerialTypeAndRecord{SerialType: SerialTypeString, Record: v.Bytes}
+	case ValueTypeBlob:
+		return &SerialTypeAndRecord{SerialType: SerialTypeBLOB, Record: v.Bytes}
+	default:
+		return &SerialTypeAndRecord{SerialType: SerialTypeNull}
+	}
+}
+
+func bigEndian(i int64, size int) Record {
+	r := make(Record, size)
+	for j := size - 1; j >= 0; j-- {
+		r[j] = byte(i)
+		i >>= 8
+	}
+	return r
+}
+
+// serialTypeNum returns the number the serial type is stored as in a record header
+func (sr *SerialTypeAndRecord) serialTypeNum() uint64 {
+	switch sr.SerialType {
+	case SerialTypeBLOB:
+		return 12 + 2*uint64(len(sr.Record))
+	case SerialTypeString:
+		return 13 + 2*uint64(len(sr.Record))
+	case SerialTypeAutoIncrPrimaryKey:
+		return uint64(SerialTypeNull)
+	default:
+		return uint64(sr.SerialType)
+	}
+}
+
+// EncodeRecord encodes values in the record format, the inverse of NewSerialTypeAndRecords
+func EncodeRecord(values []Value) []byte {
+	srs := make([]*SerialTypeAndRecord, len(values))
+	header := make([]byte, 0, len(values))
+	bodySize := 0
+	for i, v := range values {
+		srs[i] = NewSerialTypeAndRecord(v)
+		header = utils.AppendUvarint(header, srs[i].serialTypeNum())
+		bodySize += len(srs[i].Record)
+	}
+
+	// the header size counts its own varint
+	headerSize := uint64(len(header) + 1)
+	for uint64(len(utils.AppendUvarint(nil, headerSize))+len(header)) != headerSize {
+		headerSize++
+	}
+
+	record := make([]byte, 0, int(headerSize)+bodySize)
+	record = utils.AppendUvarint(record, headerSize)
+	record = append(record, header...)
+	for _, sr := range srs {
+		record = append(record, sr.Record...)
+	}
+	return record
+}
@@ -0,0 +1,74 @@
+package cell
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github/com/codecrafters-io/sqlite-starter-go/app/header"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// DecodeText converts text stored in the encoding enc to UTF-8, invalid UTF-16 becoming U+FFFD
+func DecodeText(enc header.TextEncoding, b []byte) []byte {
+	order := utf16ByteOrder(enc)
+	if order == nil {
+		return b
+	}
+
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = order.Uint16(b[2*i:])
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(units))
+	for _, r := range utf16.Decode(units) {
+		buf.WriteRune(r)
+	}
+	return buf.Bytes()
+}
+
+// EncodeText converts UTF-8 text to the encoding enc, e.g. to compare text the way a database of enc orders it
+func EncodeText(enc header.TextEncoding, b []byte) []byte {
+	order := utf16ByteOrder(enc)
+	if order == nil {
+		return b
+	}
+
+	units := make([]uint16, 0, utf8.RuneCount(b))
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		units = utf16.AppendRune(units, r)
+		b = b[size:]
+	}
+
+	encoded := make([]byte, 2*len(units))
+	for i, u := range units {
+		order.PutUint16(encoded[2*i:], u)
+	}
+	return encoded
+}
+
+// utf16ByteOrder returns nil for UTF-8
+func utf16ByteOrder(enc header.TextEncoding) binary.ByteOrder {
+	switch enc {
+	case header.TextEncodingUTF16LE:
+		return binary.LittleEndian
+	case header.TextEncodingUTF16BE:
+		return binary.BigEndian
+	default:
+		return nil
+	}
+}
+
+// decodeTextRecords converts the TEXT records of srs from the encoding enc to UTF-8
+func decodeTextRecords(srs []*SerialTypeAndRecord, enc header.TextEncoding) {
+	if utf16ByteOrder(enc) == nil {
+		return
+	}
+	for _, sr := range srs {
+		if sr.SerialType == SerialTypeString {
+			sr.Record = DecodeText(enc, sr.Record)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package cell
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ValueType is one of SQLite's storage classes. See https://www.sqlite.org/datatype3.html
+type ValueType int
+
+const (
+	ValueTypeNull ValueType = iota
+	ValueTypeInteger
+	ValueTypeReal
+	ValueTypeText
+	ValueTypeBlob
+)
+
+// String returns the name of the storage class as typeof() reports it
+func (t ValueType) String() string {
+	switch t {
+	case ValueTypeInteger:
+		return "integer"
+	case ValueTypeReal:
+		return "real"
+	case ValueTypeText:
+		return "text"
+	case ValueTypeBlob:
+		return "blob"
+	default:
+		return "null"
+	}
+}
+
+// Value is a decoded column value
+type Value struct {
+	Type    ValueType
+	Integer int64
+	Real    float64
+	// Bytes holds the content of TEXT and BLOB values
+	Bytes []byte
+}
+
+func NullValue() Value {
+	return Value{Type: ValueTypeNull}
+}
+
+func IntegerValue(i int64) Value {
+	return Value{Type: ValueTypeInteger, Integer: i}
+}
+
+func RealValue(f float64) Value {
+	return Value{Type: ValueTypeReal, Real: f}
+}
+
+func TextValue(s string) Value {
+	return Value{Type: ValueTypeText, Bytes: []byte(s)}
+}
+
+func BlobValue(b []byte) Value {
+	return Value{Type: ValueTypeBlob, Bytes: b}
+}
+
+func (v Value) IsNull() bool {
+	return v.Type == ValueTypeNull
+}
+
+// String renders the value the way the sqlite3 shell prints it, NULL being an empty string
+func (v Value) String() string {
+	switch v.Type {
+	case ValueTypeInteger:
+		return strconv.FormatInt(v.Integer, 10)
+	case ValueTypeReal:
+		return FormatReal(v.Real)
+	case ValueTypeText, ValueTypeBlob:
+		return string(v.Bytes)
+	default:
+		return ""
+	}
+}
+
+// FormatReal formats f like SQLite's "%!.15g", which always keeps a decimal point
+func FormatReal(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return ""
+	case f == 0:
+		// negative zero is printed without its sign
+		f = 0
+	}
+
+	s := strconv.FormatFloat(f, 'g', 15, 64)
+	mantissa, exponent, hasExponent := strings.Cut(s, "e")
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	if hasExponent {
+		return mantissa + "e" + exponent
+	}
+	return mantissa
+}
+
+// Quote renders the value as an SQL literal the way quote() does, doubling the quotes of text and writing blobs
+// in hexadecimal, e.g.
+//
+//	'it''s'
+//	X'00ff'
+func (v Value) Quote() string {
+	switch v.Type {
+	case ValueTypeText:
+		return "'" + strings.ReplaceAll(string(v.Bytes), "'", "''") + "'"
+	case ValueTypeBlob:
+		return "X'" + strings.ToUpper(hex.EncodeToString(v.Bytes)) + "'"
+	case ValueTypeNull:
+		return "NULL"
+	default:
+		return v.String()
+	}
+}
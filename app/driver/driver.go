@@ -0,0 +1,229 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/eval"
+	"github/com/codecrafters-io/sqlite-starter-go/app/sqlite"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Name is the name the driver is registered under, e.g. sql.Open(Name, "sample.db")
+const Name = "codecrafters-sqlite"
+
+var errReadOnly = errors.New("the database is read-only")
+
+func init() {
+	sql.Register(Name, &Driver{})
+}
+
+// Driver opens read-only connections to SQLite database files
+type Driver struct{}
+
+var _ sqldriver.Driver = (*Driver)(nil)
+
+// Open opens the database file name, which may be written as a file: URI. The mmap=1 query parameter maps
+// the file in memory, e.g. "file:sample.db?mmap=1".
+func (d *Driver) Open(name string) (sqldriver.Conn, error) {
+	path, query, _ := strings.Cut(strings.TrimPrefix(name, "file:"), "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &sqlite.Options{}
+	if v := params.Get("mmap"); v != "" {
+		if opts.Mmap, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid mmap parameter: %s", v)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sqlite.OpenFile(f, opts)
+	if err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+	return &conn{f: f, db: db}, nil
+}
+
+type conn struct {
+	f  *os.File
+	db sqlite.DB
+}
+
+var (
+	_ sqldriver.Conn           = (*conn)(nil)
+	_ sqldriver.QueryerContext = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (sqldriver.Stmt, error) {
+	s, err := c.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{stmt: s}, nil
+}
+
+func (c *conn) Close() error {
+	return errors.Join(c.db.Close(), c.f.Close())
+}
+
+func (c *conn) Begin() (sqldriver.Tx, error) {
+	return nil, errReadOnly
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r, err := c.db.Query(query, namedArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{rows: r}, nil
+}
+
+// namedArgs converts the arguments of a query to the ones of sqlite.DB, a named value becoming a NamedArg
+func namedArgs(args []sqldriver.NamedValue) []any {
+	values := make([]any, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+		if a.Name != "" {
+			values[i] = sql.Named(a.Name, a.Value)
+		}
+	}
+	return values
+}
+
+type stmt struct {
+	stmt *sqlite.Stmt
+}
+
+var (
+	_ sqldriver.Stmt             = (*stmt)(nil)
+	_ sqldriver.StmtQueryContext = (*stmt)(nil)
+)
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *stmt) Exec(args []sqldriver.Value) (sqldriver.Result, error) {
+	return nil, errReadOnly
+}
+
+func (s *stmt) Query(args []sqldriver.Value) (sqldriver.Rows, error) {
+	values := make([]any, len(args))
+	for i, a := range args {
+		values[i] = a
+	}
+	r, err := s.stmt.Query(values...)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{rows: r}, nil
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r, err := s.stmt.Query(namedArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{rows: r}, nil
+}
+
+type rows struct {
+	rows *sqlite.Rows
+}
+
+var (
+	_ sqldriver.Rows                           = (*rows)(nil)
+	_ sqldriver.RowsColumnTypeDatabaseTypeName = (*rows)(nil)
+	_ sqldriver.RowsColumnTypeScanType         = (*rows)(nil)
+)
+
+func (r *rows) Columns() []string {
+	columns := r.rows.Columns()
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+func (r *rows) Close() error {
+	return r.rows.Close()
+}
+
+func (r *rows) Next(dest []sqldriver.Value) error {
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+
+	for i, v := range r.rows.Values() {
+		dest[i] = driverValue(v)
+	}
+	return nil
+}
+
+// ColumnTypeDatabaseTypeName returns the declared type of the column, empty for expressions
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(r.rows.Columns()[index].DeclType)
+}
+
+// ColumnTypeScanType returns the Go type the values of the column are expected to have given its declared type
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	declType := r.rows.Columns()[index].DeclType
+	switch eval.AffinityFromType(declType) {
+	case eval.AffinityInteger:
+		return reflect.TypeOf(int64(0))
+	case eval.AffinityReal:
+		return reflect.TypeOf(float64(0))
+	case eval.AffinityText:
+		return reflect.TypeOf("")
+	}
+	if strings.Contains(strings.ToUpper(declType), "BLOB") {
+		return reflect.TypeOf([]byte(nil))
+	}
+	return reflect.TypeOf((*any)(nil)).Elem()
+}
+
+func driverValue(v cell.Value) sqldriver.Value {
+	switch v.Type {
+	case cell.ValueTypeNull:
+		return nil
+	case cell.ValueTypeInteger:
+		return v.Integer
+	case cell.ValueTypeReal:
+		return v.Real
+	case cell.ValueTypeText:
+		return string(v.Bytes)
+	default:
+		return append([]byte(nil), v.Bytes...)
+	}
+}
@@ -0,0 +1,244 @@
+package eval
+
+import (
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Affinity is the type a column prefers to store its values as. See https://www.sqlite.org/datatype3.html#type_affinity
+type Affinity int
+
+const (
+	// AffinityNone is also the affinity of BLOB columns and of expressions that are not column references
+	AffinityNone Affinity = iota
+	AffinityText
+	AffinityNumeric
+	AffinityInteger
+	AffinityReal
+)
+
+// AffinityFromType determines the affinity of a column from its declared type
+func AffinityFromType(declaredType string) Affinity {
+	t := strings.ToUpper(declaredType)
+	switch {
+	case strings.Contains(t, "INT"):
+		return AffinityInteger
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return AffinityText
+	case strings.Contains(t, "BLOB"), t == "":
+		return AffinityNone
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return AffinityReal
+	default:
+		return AffinityNumeric
+	}
+}
+
+// IsNumeric reports whether a is one of the affinities converting text that looks like a number
+func (a Affinity) IsNumeric() bool {
+	return a == AffinityNumeric || a == AffinityInteger || a == AffinityReal
+}
+
+// Apply converts v the way a column of this affinity would store it
+func (a Affinity) Apply(v cell.Value) cell.Value {
+	switch {
+	case a == AffinityText:
+		if v.Type == cell.ValueTypeInteger || v.Type == cell.ValueTypeReal {
+			return cell.TextValue(v.String())
+		}
+	case a.IsNumeric():
+		if v.Type == cell.ValueTypeText {
+			n, ok := parseNumeric(string(v.Bytes))
+			if !ok {
+				return v
+			}
+			v = n
+		}
+		if a == AffinityReal && v.Type == cell.ValueTypeInteger {
+			return cell.RealValue(float64(v.Integer))
+		}
+		if a != AffinityReal && v.Type == cell.ValueTypeReal {
+			if i, ok := realToExactInteger(v.Real); ok {
+				return cell.IntegerValue(i)
+			}
+		}
+	}
+	return v
+}
+
+// parseNumeric converts s to an INTEGER or a REAL when the whole text, leading and trailing spaces aside, is a number
+func parseNumeric(s string) (cell.Value, bool) {
+	s = strings.TrimSpace(s)
+	n, size := numericPrefix(s)
+	if size == 0 || size != len(s) {
+		return cell.Value{}, false
+	}
+	return n, true
+}
+
+// numericPrefix parses the longest prefix of s that is a number, returning how many bytes it spans
+func numericPrefix(s string) (cell.Value, int) {
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digitsStart := i
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	intDigits := i - digitsStart
+	isReal := false
+	if i < len(s) && s[i] == '.' {
+		j := i + 1
+		for j < len(s) && isDigit(s[j]) {
+			j++
+		}
+		if intDigits > 0 || j > i+1 {
+			isReal = true
+			i = j
+		}
+	}
+	if intDigits == 0 && !isReal {
+		return cell.Value{}, 0
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			isReal = true
+			i = j
+		}
+	}
+
+	if !isReal {
+		if n, err := strconv.ParseInt(s[:i], 10, 64); err == nil {
+			return cell.IntegerValue(n), i
+		}
+	}
+	f, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil && !math.IsInf(f, 0) {
+		return cell.Value{}, 0
+	}
+	return cell.RealValue(f), i
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+// realToExactInteger returns f as an integer when the conversion loses nothing
+func realToExactInteger(f float64) (int64, bool) {
+	if f != math.Trunc(f) || f < -9223372036854775808 || f >= 9223372036854775808 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// toNumeric converts v for arithmetic: text is read up to its longest numeric prefix, and is 0 without one
+func toNumeric(v cell.Value) cell.Value {
+	switch v.Type {
+	case cell.ValueTypeInteger, cell.ValueTypeReal, cell.ValueTypeNull:
+		return v
+	default:
+		n, size := numericPrefix(strings.TrimLeft(string(v.Bytes), " \t\n\r"))
+		if size == 0 {
+			return cell.IntegerValue(0)
+		}
+		return n
+	}
+}
+
+// toInteger converts v like CAST(v AS INTEGER): text is read up to its longest integer prefix, so that '1e3'
+// is 1, and reals are truncated, both clamped to 64 bits
+func toInteger(v cell.Value) int64 {
+	switch v.Type {
+	case cell.ValueTypeText, cell.ValueTypeBlob:
+		return integerPrefix(strings.TrimLeft(string(v.Bytes), " \t\n\r"))
+	case cell.ValueTypeReal:
+		switch {
+		case math.IsNaN(v.Real):
+			return 0
+		case v.Real >= 9223372036854775807:
+			return math.MaxInt64
+		case v.Real <= -9223372036854775808:
+			return math.MinInt64
+		}
+		return int64(v.Real)
+	default:
+		return v.Integer
+	}
+}
+
+// integerPrefix returns the integer spelled by the sign and digits s starts with, 0 without any
+func integerPrefix(s string) int64 {
+	neg := false
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		neg, s = s[0] == '-', s[1:]
+	}
+
+	var n uint64
+	for i := 0; i < len(s) && isDigit(s[i]); i++ {
+		// digits past the 64 bits keep the value clamped
+		if n > (math.MaxUint64-9)/10 {
+			n = math.MaxUint64
+			continue
+		}
+		n = n*10 + uint64(s[i]-'0')
+	}
+
+	switch {
+	case neg && n >= 1<<63:
+		return math.MinInt64
+	case neg:
+		return -int64(n)
+	case n > math.MaxInt64:
+		return math.MaxInt64
+	default:
+		return int64(n)
+	}
+}
+
+// toReal converts v like CAST(v AS REAL)
+func toReal(v cell.Value) float64 {
+	n := toNumeric(v)
+	if n.Type == cell.ValueTypeInteger {
+		return float64(n.Integer)
+	}
+	return n.Real
+}
+
+// Cast converts v like CAST(v AS declaredType), NULL staying NULL
+func Cast(v cell.Value, declaredType string) cell.Value {
+	if v.IsNull() {
+		return v
+	}
+
+	switch AffinityFromType(declaredType) {
+	case AffinityInteger:
+		return cell.IntegerValue(toInteger(v))
+	case AffinityReal:
+		return cell.RealValue(toReal(v))
+	case AffinityText:
+		return cell.TextValue(v.String())
+	case AffinityNone:
+		if v.Type == cell.ValueTypeBlob {
+			return v
+		}
+		return cell.BlobValue([]byte(v.String()))
+	default:
+		n := toNumeric(v)
+		if n.Type == cell.ValueTypeReal {
+			if i, ok := realToExactInteger(n.Real); ok {
+				return cell.IntegerValue(i)
+			}
+		}
+		return n
+	}
+}
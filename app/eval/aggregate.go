@@ -0,0 +1,342 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/rqlite/sql"
+)
+
+// AggregateRow is a Row of an aggregate query, which the results of its aggregate calls are read from
+type AggregateRow interface {
+	Row
+	Aggregate(call *sql.Call) (cell.Value, error)
+}
+
+// Aggregator computes an aggregate function over the rows of a group
+type Aggregator interface {
+	// Step adds a row, args being the evaluated arguments of the call
+	Step(args []cell.Value) error
+	Final() (cell.Value, error)
+}
+
+// RowSelector is implemented by the aggregators of min() and max(), whose result comes from a single row.
+// Bare columns of a query whose only aggregate is one of them are read from that row.
+type RowSelector interface {
+	// Selected reports whether the last step picked its row
+	Selected() bool
+}
+
+// IsAggregateCall reports whether call is a call of an aggregate function
+func IsAggregateCall(call *sql.Call) bool {
+	switch strings.ToLower(call.Name.Name) {
+	case "count", "sum", "total", "avg", "group_concat":
+		return true
+	case "min", "max":
+		// with more than one argument min() and max() are scalar functions
+		return len(call.Args) == 1
+	default:
+		return false
+	}
+}
+
+// AggregateCalls returns the aggregate calls found in exprs, in the order they appear
+func AggregateCalls(exprs ...sql.Expr) ([]*sql.Call, error) {
+	c := &aggregateCollector{calls: make([]*sql.Call, 0)}
+	for _, expr := range exprs {
+		if expr == nil {
+			continue
+		}
+		if err := sql.Walk(c, expr); err != nil {
+			return nil, err
+		}
+	}
+	return c.calls, nil
+}
+
+type aggregateCollector struct {
+	calls []*sql.Call
+}
+
+func (c *aggregateCollector) Visit(node sql.Node) (sql.Visitor, error) {
+	call, ok := node.(*sql.Call)
+	if !ok || !IsAggregateCall(call) {
+		return c, nil
+	}
+
+	// the arguments of an aggregate are evaluated for every row, so they cannot hold aggregates themselves
+	nested, err := AggregateCalls(call.Args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(nested) > 0 {
+		return nil, fmt.Errorf("misuse of aggregate: %s()", nested[0].Name.Name)
+	}
+
+	c.calls = append(c.calls, call)
+	return nil, nil
+}
+
+func (c *aggregateCollector) VisitEnd(sql.Node) error {
+	return nil
+}
+
+// NewAggregator returns the aggregator computing call, whose argument compares with c in min(), max() and DISTINCT
+func NewAggregator(call *sql.Call, c Comparator) (Aggregator, error) {
+	name := strings.ToLower(call.Name.Name)
+	if call.Filter != nil || call.Over != nil {
+		return nil, fmt.Errorf("%s() with FILTER or OVER is not supported", name)
+	}
+
+	if call.Star.IsValid() {
+		if name != "count" {
+			return nil, fmt.Errorf("wrong number of arguments to function %s()", name)
+		}
+		return &countAggregator{star: true}, nil
+	}
+
+	argCount := 1
+	if name == "group_concat" && len(call.Args) == 2 && !call.Distinct.IsValid() {
+		argCount = 2
+	}
+	if len(call.Args) != argCount {
+		if name == "group_concat" && call.Distinct.IsValid() {
+			return nil, errors.New("DISTINCT aggregates must have exactly one argument")
+		}
+		return nil, fmt.Errorf("wrong number of arguments to function %s()", name)
+	}
+
+	var a Aggregator
+	switch name {
+	case "count":
+		a = &countAggregator{}
+	case "sum":
+		a = &sumAggregator{}
+	case "total":
+		a = &sumAggregator{total: true}
+	case "avg":
+		// unlike sum(), avg() does not fail on integer overflow
+		a = &avgAggregator{sum: sumAggregator{total: true}}
+	case "min":
+		a = &minMaxAggregator{sign: -1, compare: c}
+	case "max":
+		a = &minMaxAggregator{sign: 1, compare: c}
+	case "group_concat":
+		a = &groupConcatAggregator{}
+	default:
+		return nil, fmt.Errorf("no such function: %s", call.Name.Name)
+	}
+
+	if call.Distinct.IsValid() {
+		return &distinctAggregator{Aggregator: a, collation: c.Collation, seen: make(map[string]struct{})}, nil
+	}
+	return a, nil
+}
+
+// DistinctKey encodes values so that values comparing equal get the same key, e.g. 1 and 1.0
+func DistinctKey(values ...cell.Value) string {
+	var b strings.Builder
+	for _, v := range values {
+		switch v.Type {
+		case cell.ValueTypeNull:
+			b.WriteString("n;")
+		case cell.ValueTypeInteger:
+			b.WriteString("i" + strconv.FormatInt(v.Integer, 10) + ";")
+		case cell.ValueTypeReal:
+			if i, ok := realToExactInteger(v.Real); ok {
+				b.WriteString("i" + strconv.FormatInt(i, 10) + ";")
+			} else {
+				b.WriteString("r" + strconv.FormatUint(math.Float64bits(v.Real), 16) + ";")
+			}
+		case cell.ValueTypeText:
+			b.WriteString("t" + strconv.Itoa(len(v.Bytes)) + ":" + string(v.Bytes))
+		default:
+			b.WriteString("b" + strconv.Itoa(len(v.Bytes)) + ":" + string(v.Bytes))
+		}
+	}
+	return b.String()
+}
+
+// distinctAggregator passes each distinct non-NULL value to its aggregator once
+type distinctAggregator struct {
+	Aggregator
+	collation Collation
+	seen      map[string]struct{}
+}
+
+func (a *distinctAggregator) Step(args []cell.Value) error {
+	if args[0].IsNull() {
+		return nil
+	}
+	key := DistinctKey(a.collation.Fold(args[0]))
+	if _, ok := a.seen[key]; ok {
+		return nil
+	}
+	a.seen[key] = struct{}{}
+	return a.Aggregator.Step(args)
+}
+
+func (a *distinctAggregator) Selected() bool {
+	s, ok := a.Aggregator.(RowSelector)
+	return ok && s.Selected()
+}
+
+type countAggregator struct {
+	star  bool
+	count int64
+}
+
+func (a *countAggregator) Step(args []cell.Value) error {
+	if a.star || !args[0].IsNull() {
+		a.count++
+	}
+	return nil
+}
+
+func (a *countAggregator) Final() (cell.Value, error) {
+	return cell.IntegerValue(a.count), nil
+}
+
+// sumAggregator adds integers exactly as long as no REAL or non-numeric value comes up.
+// sum() is NULL without any non-NULL value and fails on integer overflow, total() is always REAL.
+type sumAggregator struct {
+	total   bool
+	count   int64
+	isReal  bool
+	integer int64
+	real    float64
+}
+
+func (a *sumAggregator) Step(args []cell.Value) error {
+	v := args[0]
+	if v.IsNull() {
+		return nil
+	}
+	a.count++
+
+	// text is added as the number it spells, a REAL staying REAL even when its value is integral
+	if v.Type == cell.ValueTypeText {
+		if n, ok := parseNumeric(string(v.Bytes)); ok {
+			v = n
+		}
+	}
+	if v.Type == cell.ValueTypeInteger && !a.isReal {
+		r, ok := integerArithmetic(sql.PLUS, a.integer, v.Integer)
+		if ok {
+			a.integer = r.Integer
+			return nil
+		}
+		if !a.total {
+			return errors.New("integer overflow")
+		}
+		a.isReal = true
+		a.real = float64(a.integer)
+	}
+
+	if !a.isReal {
+		a.isReal = true
+		a.real = float64(a.integer)
+	}
+	a.real += toReal(v)
+	return nil
+}
+
+func (a *sumAggregator) Final() (cell.Value, error) {
+	switch {
+	case a.total && !a.isReal:
+		return cell.RealValue(float64(a.integer)), nil
+	case a.total || a.isReal:
+		return cell.RealValue(a.real), nil
+	case a.count == 0:
+		return cell.NullValue(), nil
+	default:
+		return cell.IntegerValue(a.integer), nil
+	}
+}
+
+type avgAggregator struct {
+	sum sumAggregator
+}
+
+func (a *avgAggregator) Step(args []cell.Value) error {
+	return a.sum.Step(args)
+}
+
+func (a *avgAggregator) Final() (cell.Value, error) {
+	if a.sum.count == 0 {
+		return cell.NullValue(), nil
+	}
+	total := float64(a.sum.integer)
+	if a.sum.isReal {
+		total = a.sum.real
+	}
+	return cell.RealValue(total / float64(a.sum.count)), nil
+}
+
+// minMaxAggregator keeps the lowest value when sign is -1 and the highest one when it is 1, ignoring NULLs
+type minMaxAggregator struct {
+	sign     int
+	compare  Comparator
+	value    cell.Value
+	found    bool
+	selected bool
+}
+
+func (a *minMaxAggregator) Step(args []cell.Value) error {
+	a.selected = false
+	v := args[0]
+	if v.IsNull() {
+		return nil
+	}
+	if !a.found || a.compare.Compare(v, a.value)*a.sign > 0 {
+		a.value = v
+		a.found = true
+		a.selected = true
+	}
+	return nil
+}
+
+func (a *minMaxAggregator) Final() (cell.Value, error) {
+	if !a.found {
+		return cell.NullValue(), nil
+	}
+	return a.value, nil
+}
+
+func (a *minMaxAggregator) Selected() bool {
+	return a.selected
+}
+
+type groupConcatAggregator struct {
+	b     strings.Builder
+	found bool
+}
+
+func (a *groupConcatAggregator) Step(args []cell.Value) error {
+	v := args[0]
+	if v.IsNull() {
+		return nil
+	}
+
+	if a.found {
+		separator := ","
+		if len(args) > 1 {
+			separator = args[1].String()
+		}
+		a.b.WriteString(separator)
+	}
+	a.b.WriteString(v.String())
+	a.found = true
+	return nil
+}
+
+func (a *groupConcatAggregator) Final() (cell.Value, error) {
+	if !a.found {
+		return cell.NullValue(), nil
+	}
+	return cell.TextValue(a.b.String()), nil
+}
@@ -0,0 +1,132 @@
+package eval
+
+import (
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"math"
+
+	"github.com/rqlite/sql"
+)
+
+func negate(v cell.Value) cell.Value {
+	if v.Type == cell.ValueTypeReal {
+		return cell.RealValue(-v.Real)
+	}
+	if v.Integer == math.MinInt64 {
+		return cell.RealValue(-float64(v.Integer))
+	}
+	return cell.IntegerValue(-v.Integer)
+}
+
+// arithmetic applies +, -, *, / or % to x and y. Integers overflowing 64 bits turn into REAL,
+// and dividing by zero gives NULL.
+func arithmetic(op sql.Token, x, y cell.Value) cell.Value {
+	if x.IsNull() || y.IsNull() {
+		return cell.NullValue()
+	}
+
+	if op == sql.REM {
+		// % works on the integer parts of its operands, text being read like CAST(x AS INTEGER), and keeps REAL
+		// if either was one
+		xi, yi := toInteger(x), toInteger(y)
+		if yi == 0 {
+			return cell.NullValue()
+		}
+		r := int64(0)
+		if yi != -1 {
+			r = xi % yi
+		}
+		if toNumeric(x).Type == cell.ValueTypeReal || toNumeric(y).Type == cell.ValueTypeReal {
+			return cell.RealValue(float64(r))
+		}
+		return cell.IntegerValue(r)
+	}
+
+	x, y = toNumeric(x), toNumeric(y)
+	if x.Type == cell.ValueTypeInteger && y.Type == cell.ValueTypeInteger {
+		if v, ok := integerArithmetic(op, x.Integer, y.Integer); ok {
+			return v
+		}
+	}
+
+	xf, yf := numberAsFloat(x), numberAsFloat(y)
+	switch op {
+	case sql.PLUS:
+		return cell.RealValue(xf + yf)
+	case sql.MINUS:
+		return cell.RealValue(xf - yf)
+	case sql.STAR:
+		return cell.RealValue(xf * yf)
+	default:
+		if yf == 0 {
+			return cell.NullValue()
+		}
+		return cell.RealValue(xf / yf)
+	}
+}
+
+// integerArithmetic computes x op y, ok being false when the result does not fit in an integer
+func integerArithmetic(op sql.Token, x, y int64) (cell.Value, bool) {
+	switch op {
+	case sql.PLUS:
+		r := x + y
+		if (r > x) != (y > 0) {
+			return cell.Value{}, false
+		}
+		return cell.IntegerValue(r), true
+	case sql.MINUS:
+		r := x - y
+		if (r < x) != (y > 0) {
+			return cell.Value{}, false
+		}
+		return cell.IntegerValue(r), true
+	case sql.STAR:
+		if x == 0 || y == 0 {
+			return cell.IntegerValue(0), true
+		}
+		r := x * y
+		if r/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+			return cell.Value{}, false
+		}
+		return cell.IntegerValue(r), true
+	default:
+		if y == 0 {
+			return cell.NullValue(), true
+		}
+		if x == math.MinInt64 && y == -1 {
+			return cell.Value{}, false
+		}
+		return cell.IntegerValue(x / y), true
+	}
+}
+
+func bitwise(op sql.Token, x, y cell.Value) cell.Value {
+	if x.IsNull() || y.IsNull() {
+		return cell.NullValue()
+	}
+	xi, yi := toInteger(x), toInteger(y)
+
+	switch op {
+	case sql.BITAND:
+		return cell.IntegerValue(xi & yi)
+	case sql.BITOR:
+		return cell.IntegerValue(xi | yi)
+	}
+
+	// a negative shift amount shifts the other way
+	if op == sql.RSHIFT {
+		yi = -yi
+	}
+	switch {
+	case yi >= 64:
+		return cell.IntegerValue(0)
+	case yi >= 0:
+		return cell.IntegerValue(xi << uint(yi))
+	case yi <= -64:
+		if xi < 0 {
+			return cell.IntegerValue(-1)
+		}
+		return cell.IntegerValue(0)
+	default:
+		return cell.IntegerValue(xi >> uint(-yi))
+	}
+}
@@ -0,0 +1,110 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"strings"
+
+	"github.com/rqlite/sql"
+)
+
+// Collation is how text compares, as named by the COLLATE clause of a column or an index
+type Collation int
+
+const (
+	CollationBinary Collation = iota
+	// CollationNoCase ignores the case of ASCII letters
+	CollationNoCase
+	// CollationRTrim ignores trailing spaces
+	CollationRTrim
+)
+
+var collationNames = []string{"BINARY", "NOCASE", "RTRIM"}
+
+func (c Collation) String() string {
+	if int(c) < len(collationNames) {
+		return collationNames[c]
+	}
+	return fmt.Sprintf("collation(%d)", int(c))
+}
+
+// ParseCollation returns the collation named name, BINARY when name is empty
+func ParseCollation(name string) (Collation, error) {
+	if name == "" {
+		return CollationBinary, nil
+	}
+	for i, n := range collationNames {
+		if strings.EqualFold(n, name) {
+			return Collation(i), nil
+		}
+	}
+	return CollationBinary, fmt.Errorf("no such collation sequence: %s", name)
+}
+
+// fold returns the UTF-8 text b compares as under NOCASE and RTRIM
+func (c Collation) fold(b []byte) []byte {
+	switch c {
+	case CollationNoCase:
+		folded := make([]byte, len(b))
+		for i, ch := range b {
+			if 'A' <= ch && ch <= 'Z' {
+				ch += 'a' - 'A'
+			}
+			folded[i] = ch
+		}
+		return folded
+	case CollationRTrim:
+		return []byte(strings.TrimRight(string(b), " "))
+	default:
+		return b
+	}
+}
+
+// Fold returns v with its text replaced by what it compares as, so that values equal under c get the same
+// DistinctKey
+func (c Collation) Fold(v cell.Value) cell.Value {
+	if v.Type != cell.ValueTypeText || c == CollationBinary {
+		return v
+	}
+	return cell.TextValue(string(c.fold(v.Bytes)))
+}
+
+// ExprCollation returns the collation of expr, which is that of the column it refers to, looking through
+// parentheses, unary + and CAST. ok is false when expr is not a column reference, the other operand of a
+// comparison then deciding how it compares. column returns the collation of a column.
+func ExprCollation(expr sql.Expr, column func(table, column string) (Collation, error)) (c Collation, ok bool, err error) {
+	for {
+		var table, name string
+		switch x := expr.(type) {
+		case *sql.ParenExpr:
+			expr = x.X
+			continue
+		case *sql.UnaryExpr:
+			if x.Op != sql.PLUS {
+				return CollationBinary, false, nil
+			}
+			expr = x.X
+			continue
+		case *sql.CastExpr:
+			expr = x.X
+			continue
+		case *sql.Ident:
+			name = x.Name
+		case *sql.QualifiedRef:
+			if x.Star.IsValid() {
+				return CollationBinary, false, nil
+			}
+			table, name = x.Table.Name, x.Column.Name
+		default:
+			return CollationBinary, false, nil
+		}
+
+		// a reference that does not resolve, like a double-quoted string, has no collation
+		c, err := column(table, name)
+		if errors.Is(err, ErrNoSuchColumn) {
+			return CollationBinary, false, nil
+		}
+		return c, err == nil, err
+	}
+}
@@ -0,0 +1,88 @@
+package eval
+
+import (
+	"bytes"
+	"cmp"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/header"
+)
+
+// typeOrder ranks storage classes the way SQLite sorts them: NULL, then numbers, then text, then blobs
+func typeOrder(t cell.ValueType) int {
+	switch t {
+	case cell.ValueTypeNull:
+		return 0
+	case cell.ValueTypeInteger, cell.ValueTypeReal:
+		return 1
+	case cell.ValueTypeText:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Comparator orders values the way a database sorts them, comparing text with a collation. BINARY compares the
+// bytes of text in the encoding of the database, which for UTF-16 is not the order of the UTF-8 text is decoded
+// to, while NOCASE and RTRIM are defined over UTF-8. The zero value compares UTF-8 with BINARY.
+type Comparator struct {
+	Collation Collation
+	Encoding  header.TextEncoding
+}
+
+// Compare orders two values with the BINARY collation over UTF-8 text, returning -1, 0 or +1.
+// No affinity is applied, NULL compares equal to NULL and lower than anything else.
+func Compare(x, y cell.Value) int {
+	return Comparator{}.Compare(x, y)
+}
+
+// Compare orders two values like the package-level Compare, text with the collation of the comparator
+func (c Comparator) Compare(x, y cell.Value) int {
+	if c := cmp.Compare(typeOrder(x.Type), typeOrder(y.Type)); c != 0 {
+		return c
+	}
+
+	switch x.Type {
+	case cell.ValueTypeNull:
+		return 0
+	case cell.ValueTypeInteger, cell.ValueTypeReal:
+		if x.Type == cell.ValueTypeInteger && y.Type == cell.ValueTypeInteger {
+			return cmp.Compare(x.Integer, y.Integer)
+		}
+		return cmp.Compare(numberAsFloat(x), numberAsFloat(y))
+	case cell.ValueTypeText:
+		if c.Collation != CollationBinary {
+			return bytes.Compare(c.Collation.fold(x.Bytes), c.Collation.fold(y.Bytes))
+		}
+		return bytes.Compare(cell.EncodeText(c.Encoding, x.Bytes), cell.EncodeText(c.Encoding, y.Bytes))
+	default:
+		return bytes.Compare(x.Bytes, y.Bytes)
+	}
+}
+
+func numberAsFloat(v cell.Value) float64 {
+	if v.Type == cell.ValueTypeInteger {
+		return float64(v.Integer)
+	}
+	return v.Real
+}
+
+// applyComparisonAffinity converts the operands of a comparison following https://www.sqlite.org/datatype3.html#type_conversions_prior_to_comparison
+func applyComparisonAffinity(x, y cell.Value, xa, ya Affinity) (cell.Value, cell.Value) {
+	switch {
+	case xa.IsNumeric() && !ya.IsNumeric():
+		y = AffinityNumeric.Apply(y)
+	case ya.IsNumeric() && !xa.IsNumeric():
+		x = AffinityNumeric.Apply(x)
+	case xa == AffinityText && ya == AffinityNone:
+		y = AffinityText.Apply(y)
+	case ya == AffinityText && xa == AffinityNone:
+		x = AffinityText.Apply(x)
+	}
+	return x, y
+}
+
+// CompareWithAffinity compares two operands of a comparison operator after applying their affinities
+func (c Comparator) CompareWithAffinity(x, y cell.Value, xa, ya Affinity) int {
+	x, y = applyComparisonAffinity(x, y, xa, ya)
+	return c.Compare(x, y)
+}
@@ -0,0 +1,458 @@
+package eval
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/header"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rqlite/sql"
+)
+
+// ErrNoSuchColumn is returned by Row implementations when a column reference does not resolve
+var ErrNoSuchColumn = errors.New("no such column")
+
+// Row resolves the column references of an expression
+type Row interface {
+	// Column returns the value, the affinity and the collation of a column, table being empty for unqualified
+	// references
+	Column(table, column string) (cell.Value, Affinity, Collation, error)
+	// Encoding is the text encoding of the database the row is read from, which text is compared in
+	Encoding() header.TextEncoding
+}
+
+type evaluator struct {
+	row Row
+}
+
+// Eval evaluates expr against row. row may be nil for expressions without column references.
+func Eval(expr sql.Expr, row Row) (cell.Value, error) {
+	v, _, err := (&evaluator{row: row}).eval(expr)
+	return v, err
+}
+
+// EvalWithAffinity evaluates expr like Eval, also returning the affinity of the expression
+func EvalWithAffinity(expr sql.Expr, row Row) (cell.Value, Affinity, error) {
+	return (&evaluator{row: row}).eval(expr)
+}
+
+// comparator compares values the way the database of the row sorts them, with the BINARY collation
+func (e *evaluator) comparator() Comparator {
+	if e.row == nil {
+		return Comparator{}
+	}
+	return Comparator{Encoding: e.row.Encoding()}
+}
+
+// comparatorOf returns the comparator of a comparison of exprs, which uses the collation of the first one having
+// one, BINARY when none has
+func (e *evaluator) comparatorOf(exprs ...sql.Expr) (Comparator, error) {
+	c := e.comparator()
+	for _, expr := range exprs {
+		collation, ok, err := ExprCollation(expr, e.columnCollation)
+		if err != nil || ok {
+			c.Collation = collation
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+func (e *evaluator) columnCollation(table, column string) (Collation, error) {
+	if e.row == nil {
+		return CollationBinary, ErrNoSuchColumn
+	}
+	_, _, c, err := e.row.Column(table, column)
+	return c, err
+}
+
+// IsTrue reports whether a WHERE clause evaluating to v keeps the row, NULL being false
+func IsTrue(v cell.Value) bool {
+	b, ok := truth(v)
+	return ok && b
+}
+
+// truth converts v to a boolean, ok being false for NULL
+func truth(v cell.Value) (bool, bool) {
+	switch v.Type {
+	case cell.ValueTypeNull:
+		return false, false
+	case cell.ValueTypeInteger:
+		return v.Integer != 0, true
+	default:
+		return toReal(v) != 0, true
+	}
+}
+
+func boolValue(b bool) cell.Value {
+	if b {
+		return cell.IntegerValue(1)
+	}
+	return cell.IntegerValue(0)
+}
+
+func (e *evaluator) eval(expr sql.Expr) (cell.Value, Affinity, error) {
+	switch x := expr.(type) {
+	case *sql.NullLit:
+		return cell.NullValue(), AffinityNone, nil
+	case *sql.NumberLit:
+		v, err := ParseNumber(x.Value)
+		return v, AffinityNone, err
+	case *sql.StringLit:
+		return cell.TextValue(x.Value), AffinityNone, nil
+	case *sql.BlobLit:
+		b, err := hex.DecodeString(x.Value)
+		if err != nil {
+			return cell.Value{}, AffinityNone, fmt.Errorf("malformed blob literal: %s", x.String())
+		}
+		return cell.BlobValue(b), AffinityNone, nil
+	case *sql.BoolLit:
+		return boolValue(x.Value), AffinityNone, nil
+	case *sql.TimestampLit:
+		return evalTimestamp(x), AffinityNone, nil
+	case *sql.Ident:
+		return e.evalColumn("", x)
+	case *sql.QualifiedRef:
+		if x.Star.IsValid() {
+			return cell.Value{}, AffinityNone, fmt.Errorf("%s is only allowed as a result column", x.String())
+		}
+		return e.evalColumn(x.Table.Name, x.Column)
+	case *sql.ParenExpr:
+		return e.eval(x.X)
+	case *sql.UnaryExpr:
+		return e.evalUnary(x)
+	case *sql.BinaryExpr:
+		return e.evalBinary(x)
+	case *sql.CastExpr:
+		v, _, err := e.eval(x.X)
+		if err != nil {
+			return cell.Value{}, AffinityNone, err
+		}
+		return Cast(v, x.Type.String()), AffinityFromType(x.Type.String()), nil
+	case *sql.CaseExpr:
+		return e.evalCase(x)
+	case *sql.Call:
+		if IsAggregateCall(x) {
+			ar, ok := e.row.(AggregateRow)
+			if !ok {
+				return cell.Value{}, AffinityNone, fmt.Errorf("misuse of aggregate: %s()", x.Name.Name)
+			}
+			v, err := ar.Aggregate(x)
+			return v, AffinityNone, err
+		}
+		return e.evalCall(x)
+	default:
+		return cell.Value{}, AffinityNone, fmt.Errorf("expression is not supported: %s", expr.String())
+	}
+}
+
+// ParseNumber converts a numeric literal, decimal or hexadecimal, to an INTEGER or a REAL
+func ParseNumber(lit string) (cell.Value, error) {
+	if len(lit) > 2 && (strings.HasPrefix(lit, "0x") || strings.HasPrefix(lit, "0X")) {
+		u, err := strconv.ParseUint(lit[2:], 16, 64)
+		if err != nil {
+			return cell.Value{}, fmt.Errorf("hex literal too big: %s", lit)
+		}
+		return cell.IntegerValue(int64(u)), nil
+	}
+
+	v, ok := parseNumeric(lit)
+	if !ok {
+		return cell.Value{}, fmt.Errorf("malformed number: %s", lit)
+	}
+	return v, nil
+}
+
+func evalTimestamp(lit *sql.TimestampLit) cell.Value {
+	now := time.Now().UTC()
+	switch strings.ToUpper(lit.Value) {
+	case "CURRENT_DATE":
+		return cell.TextValue(now.Format("2006-01-02"))
+	case "CURRENT_TIME":
+		return cell.TextValue(now.Format("15:04:05"))
+	default:
+		return cell.TextValue(now.Format("2006-01-02 15:04:05"))
+	}
+}
+
+func (e *evaluator) evalColumn(table string, ident *sql.Ident) (cell.Value, Affinity, error) {
+	if e.row == nil {
+		return cell.Value{}, AffinityNone, fmt.Errorf("%w: %s", ErrNoSuchColumn, ident.Name)
+	}
+
+	v, a, _, err := e.row.Column(table, ident.Name)
+	// like SQLite, a double-quoted identifier that is not a column is read as a string literal
+	if errors.Is(err, ErrNoSuchColumn) && table == "" && ident.Quoted {
+		return cell.TextValue(ident.Name), AffinityNone, nil
+	}
+	return v, a, err
+}
+
+func (e *evaluator) evalUnary(expr *sql.UnaryExpr) (cell.Value, Affinity, error) {
+	// -9223372036854775808 only fits in an integer with its sign
+	if lit, ok := expr.X.(*sql.NumberLit); ok && expr.Op == sql.MINUS {
+		v, err := ParseNumber("-" + lit.Value)
+		return v, AffinityNone, err
+	}
+
+	x, _, err := e.eval(expr.X)
+	if err != nil {
+		return cell.Value{}, AffinityNone, err
+	}
+
+	switch expr.Op {
+	case sql.PLUS:
+		return x, AffinityNone, nil
+	case sql.MINUS:
+		if x.IsNull() {
+			return x, AffinityNone, nil
+		}
+		return negate(toNumeric(x)), AffinityNone, nil
+	case sql.NOT:
+		b, ok := truth(x)
+		if !ok {
+			return cell.NullValue(), AffinityNone, nil
+		}
+		return boolValue(!b), AffinityNone, nil
+	default:
+		return cell.Value{}, AffinityNone, fmt.Errorf("unary operator is not supported: %s", expr.Op)
+	}
+}
+
+func (e *evaluator) evalBinary(expr *sql.BinaryExpr) (cell.Value, Affinity, error) {
+	switch expr.Op {
+	case sql.AND, sql.OR:
+		v, err := e.evalLogical(expr)
+		return v, AffinityNone, err
+	case sql.IN, sql.NOTIN:
+		v, err := e.evalIn(expr)
+		return v, AffinityNone, err
+	case sql.BETWEEN, sql.NOTBETWEEN:
+		v, err := e.evalBetween(expr)
+		return v, AffinityNone, err
+	case sql.LIKE, sql.NOTLIKE:
+		v, err := e.evalLike(expr)
+		return v, AffinityNone, err
+	}
+
+	x, xa, err := e.eval(expr.X)
+	if err != nil {
+		return cell.Value{}, AffinityNone, err
+	}
+	y, ya, err := e.eval(expr.Y)
+	if err != nil {
+		return cell.Value{}, AffinityNone, err
+	}
+
+	switch expr.Op {
+	case sql.EQ, sql.NE, sql.LT, sql.LE, sql.GT, sql.GE:
+		if x.IsNull() || y.IsNull() {
+			return cell.NullValue(), AffinityNone, nil
+		}
+		c, err := e.comparatorOf(expr.X, expr.Y)
+		if err != nil {
+			return cell.Value{}, AffinityNone, err
+		}
+		return boolValue(compareOp(expr.Op, c.CompareWithAffinity(x, y, xa, ya))), AffinityNone, nil
+	case sql.IS, sql.ISNOT:
+		c, err := e.comparatorOf(expr.X, expr.Y)
+		if err != nil {
+			return cell.Value{}, AffinityNone, err
+		}
+		equal := x.IsNull() == y.IsNull()
+		if equal && !x.IsNull() {
+			equal = c.CompareWithAffinity(x, y, xa, ya) == 0
+		}
+		return boolValue(equal == (expr.Op == sql.IS)), AffinityNone, nil
+	case sql.PLUS, sql.MINUS, sql.STAR, sql.SLASH, sql.REM:
+		return arithmetic(expr.Op, x, y), AffinityNone, nil
+	case sql.BITAND, sql.BITOR, sql.LSHIFT, sql.RSHIFT:
+		return bitwise(expr.Op, x, y), AffinityNone, nil
+	case sql.CONCAT:
+		if x.IsNull() || y.IsNull() {
+			return cell.NullValue(), AffinityNone, nil
+		}
+		return cell.TextValue(x.String() + y.String()), AffinityNone, nil
+	case sql.GLOB, sql.NOTGLOB:
+		if x.IsNull() || y.IsNull() {
+			return cell.NullValue(), AffinityNone, nil
+		}
+		return boolValue(Glob(y.String(), x.String()) == (expr.Op == sql.GLOB)), AffinityNone, nil
+	default:
+		return cell.Value{}, AffinityNone, fmt.Errorf("binary operator is not supported: %s", expr.Op)
+	}
+}
+
+func compareOp(op sql.Token, c int) bool {
+	switch op {
+	case sql.EQ:
+		return c == 0
+	case sql.NE:
+		return c != 0
+	case sql.LT:
+		return c < 0
+	case sql.LE:
+		return c <= 0
+	case sql.GT:
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// evalLogical evaluates AND and OR with SQL's three-valued logic, skipping the right operand when the left decides
+func (e *evaluator) evalLogical(expr *sql.BinaryExpr) (cell.Value, error) {
+	x, _, err := e.eval(expr.X)
+	if err != nil {
+		return cell.Value{}, err
+	}
+	xb, xok := truth(x)
+	if xok && xb == (expr.Op == sql.OR) {
+		return boolValue(xb), nil
+	}
+
+	y, _, err := e.eval(expr.Y)
+	if err != nil {
+		return cell.Value{}, err
+	}
+	yb, yok := truth(y)
+	if yok && yb == (expr.Op == sql.OR) {
+		return boolValue(yb), nil
+	}
+	if !xok || !yok {
+		return cell.NullValue(), nil
+	}
+	return boolValue(expr.Op == sql.AND), nil
+}
+
+func (e *evaluator) evalIn(expr *sql.BinaryExpr) (cell.Value, error) {
+	list, ok := expr.Y.(*sql.ExprList)
+	if !ok {
+		return cell.Value{}, fmt.Errorf("IN is only supported with a list of values: %s", expr.String())
+	}
+
+	x, xa, err := e.eval(expr.X)
+	if err != nil {
+		return cell.Value{}, err
+	}
+	if x.IsNull() {
+		return cell.NullValue(), nil
+	}
+	// the values of the list compare with the collation of the left operand alone
+	c, err := e.comparatorOf(expr.X)
+	if err != nil {
+		return cell.Value{}, err
+	}
+
+	found, sawNull := false, false
+	for _, item := range list.Exprs {
+		y, ya, err := e.eval(item)
+		if err != nil {
+			return cell.Value{}, err
+		}
+		if y.IsNull() {
+			sawNull = true
+			continue
+		}
+		if c.CompareWithAffinity(x, y, xa, ya) == 0 {
+			found = true
+			break
+		}
+	}
+
+	if !found && sawNull {
+		return cell.NullValue(), nil
+	}
+	return boolValue(found == (expr.Op == sql.IN)), nil
+}
+
+func (e *evaluator) evalBetween(expr *sql.BinaryExpr) (cell.Value, error) {
+	rng, ok := expr.Y.(*sql.Range)
+	if !ok {
+		return cell.Value{}, fmt.Errorf("invalid BETWEEN expression: %s", expr.String())
+	}
+
+	lower := &sql.BinaryExpr{X: expr.X, Op: sql.GE, Y: rng.X}
+	upper := &sql.BinaryExpr{X: expr.X, Op: sql.LE, Y: rng.Y}
+	v, err := e.evalLogical(&sql.BinaryExpr{X: lower, Op: sql.AND, Y: upper})
+	if err != nil || expr.Op == sql.BETWEEN || v.IsNull() {
+		return v, err
+	}
+	return boolValue(v.Integer == 0), nil
+}
+
+// evalLike matches the left operand of expr against its pattern, which is followed by the escape character of
+// an ESCAPE clause if any
+func (e *evaluator) evalLike(expr *sql.BinaryExpr) (cell.Value, error) {
+	pattern, escapeExpr := expr.Y, sql.Expr(nil)
+	if esc, ok := expr.Y.(*sql.BinaryExpr); ok && esc.Op == sql.ESCAPE {
+		pattern, escapeExpr = esc.X, esc.Y
+	}
+
+	values := make([]cell.Value, 0, 3)
+	for _, x := range []sql.Expr{expr.X, pattern, escapeExpr} {
+		if x == nil {
+			continue
+		}
+		v, _, err := e.eval(x)
+		if err != nil {
+			return cell.Value{}, err
+		}
+		if v.IsNull() {
+			return cell.NullValue(), nil
+		}
+		values = append(values, v)
+	}
+
+	escape := rune(noEscape)
+	if escapeExpr != nil {
+		runes := []rune(values[2].String())
+		if len(runes) != 1 {
+			return cell.Value{}, errors.New("ESCAPE expression must be a single character")
+		}
+		escape = runes[0]
+	}
+	return boolValue(like(values[1].String(), values[0].String(), escape) == (expr.Op == sql.LIKE)), nil
+}
+
+func (e *evaluator) evalCase(expr *sql.CaseExpr) (cell.Value, Affinity, error) {
+	var operand cell.Value
+	var operandAffinity Affinity
+	if expr.Operand != nil {
+		var err error
+		if operand, operandAffinity, err = e.eval(expr.Operand); err != nil {
+			return cell.Value{}, AffinityNone, err
+		}
+	}
+
+	for _, blk := range expr.Blocks {
+		cond, condAffinity, err := e.eval(blk.Condition)
+		if err != nil {
+			return cell.Value{}, AffinityNone, err
+		}
+
+		var match bool
+		switch {
+		case expr.Operand == nil:
+			match = IsTrue(cond)
+		case !operand.IsNull() && !cond.IsNull():
+			c, err := e.comparatorOf(expr.Operand, blk.Condition)
+			if err != nil {
+				return cell.Value{}, AffinityNone, err
+			}
+			match = c.CompareWithAffinity(operand, cond, operandAffinity, condAffinity) == 0
+		}
+		if match {
+			return e.eval(blk.Body)
+		}
+	}
+
+	if expr.ElseExpr != nil {
+		return e.eval(expr.ElseExpr)
+	}
+	return cell.NullValue(), AffinityNone, nil
+}
@@ -0,0 +1,436 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/header"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rqlite/sql"
+)
+
+// scalarFunction is a built-in function computing a value from the arguments of a single row
+type scalarFunction struct {
+	// minArgs and maxArgs bound the number of arguments, maxArgs being -1 when there is no upper bound
+	minArgs, maxArgs int
+	call             func(args []cell.Value) (cell.Value, error)
+	// encoded is called in place of call by the functions depending on the text encoding of the database
+	encoded func(args []cell.Value, enc header.TextEncoding) (cell.Value, error)
+	// collated is called in place of call by the functions comparing their arguments, which compare with the
+	// collation of the first argument having one
+	collated func(args []cell.Value, c Comparator) (cell.Value, error)
+	// lazy is called in place of call by the functions evaluating only the arguments they need
+	lazy func(e *evaluator, args []sql.Expr) (cell.Value, Affinity, error)
+}
+
+// scalarFunctions is filled in by init, as the functions evaluating their own arguments refer back to it
+var scalarFunctions map[string]*scalarFunction
+
+func init() {
+	scalarFunctions = map[string]*scalarFunction{
+		"length":    {minArgs: 1, maxArgs: 1, call: length},
+		"lower":     {minArgs: 1, maxArgs: 1, call: textFunction(lowerASCII)},
+		"upper":     {minArgs: 1, maxArgs: 1, call: textFunction(upperASCII)},
+		"substr":    {minArgs: 2, maxArgs: 3, call: substr},
+		"substring": {minArgs: 2, maxArgs: 3, call: substr},
+		"trim":      {minArgs: 1, maxArgs: 2, call: trimFunction(true, true)},
+		"ltrim":     {minArgs: 1, maxArgs: 2, call: trimFunction(true, false)},
+		"rtrim":     {minArgs: 1, maxArgs: 2, call: trimFunction(false, true)},
+		"replace":   {minArgs: 3, maxArgs: 3, call: replace},
+		"instr":     {minArgs: 2, maxArgs: 2, call: instr},
+		"abs":       {minArgs: 1, maxArgs: 1, call: abs},
+		"round":     {minArgs: 1, maxArgs: 2, call: round},
+		"coalesce":  {minArgs: 2, maxArgs: -1, lazy: coalesce},
+		"ifnull":    {minArgs: 2, maxArgs: 2, lazy: coalesce},
+		"nullif":    {minArgs: 2, maxArgs: 2, collated: nullif},
+		"iif":       {minArgs: 2, maxArgs: 3, lazy: iif},
+		"typeof":    {minArgs: 1, maxArgs: 1, call: typeOf},
+		"hex":       {minArgs: 1, maxArgs: 1, encoded: hexFunction},
+		"quote":     {minArgs: 1, maxArgs: 1, call: quote},
+		"printf":    {minArgs: 0, maxArgs: -1, call: printf},
+		"format":    {minArgs: 0, maxArgs: -1, call: printf},
+		"unicode":   {minArgs: 1, maxArgs: 1, call: unicodeFunction},
+		"char":      {minArgs: 0, maxArgs: -1, call: char},
+		"random":    {minArgs: 0, maxArgs: 0, call: random},
+		// with a single argument min() and max() are aggregates
+		"min": {minArgs: 2, maxArgs: -1, collated: minMax(-1)},
+		"max": {minArgs: 2, maxArgs: -1, collated: minMax(1)},
+	}
+}
+
+// evalCall evaluates a call of a scalar function
+func (e *evaluator) evalCall(call *sql.Call) (cell.Value, Affinity, error) {
+	name := strings.ToLower(call.Name.Name)
+	f, ok := scalarFunctions[name]
+	switch {
+	case !ok:
+		return cell.Value{}, AffinityNone, fmt.Errorf("no such function: %s", call.Name.Name)
+	case call.Filter != nil:
+		return cell.Value{}, AffinityNone, fmt.Errorf("FILTER may not be used with non-aggregate %s()", name)
+	case call.Over != nil:
+		return cell.Value{}, AffinityNone, fmt.Errorf("%s() may not be used as a window function", name)
+	case call.Star.IsValid() || len(call.Args) < f.minArgs || f.maxArgs >= 0 && len(call.Args) > f.maxArgs:
+		return cell.Value{}, AffinityNone, fmt.Errorf("wrong number of arguments to function %s()", name)
+	}
+
+	if f.lazy != nil {
+		return f.lazy(e, call.Args)
+	}
+	args := make([]cell.Value, len(call.Args))
+	for i, arg := range call.Args {
+		var err error
+		if args[i], _, err = e.eval(arg); err != nil {
+			return cell.Value{}, AffinityNone, err
+		}
+	}
+	switch {
+	case f.encoded != nil:
+		v, err := f.encoded(args, e.comparator().Encoding)
+		return v, AffinityNone, err
+	case f.collated != nil:
+		c, err := e.comparatorOf(call.Args...)
+		if err != nil {
+			return cell.Value{}, AffinityNone, err
+		}
+		v, err := f.collated(args, c)
+		return v, AffinityNone, err
+	}
+	v, err := f.call(args)
+	return v, AffinityNone, err
+}
+
+// anyNull reports whether one of args is NULL, which most functions return NULL for
+func anyNull(args []cell.Value) bool {
+	for _, v := range args {
+		if v.IsNull() {
+			return true
+		}
+	}
+	return false
+}
+
+// length counts the characters of text up to the first NUL character, the bytes of a blob and the characters of
+// a number rendered as text
+func length(args []cell.Value) (cell.Value, error) {
+	v := args[0]
+	switch v.Type {
+	case cell.ValueTypeNull:
+		return v, nil
+	case cell.ValueTypeBlob:
+		return cell.IntegerValue(int64(len(v.Bytes))), nil
+	default:
+		s := v.String()
+		if i := strings.IndexByte(s, 0); i >= 0 {
+			s = s[:i]
+		}
+		return cell.IntegerValue(int64(utf8.RuneCountInString(s))), nil
+	}
+}
+
+// textFunction returns a function converting the text of its argument with convert, NULL staying NULL
+func textFunction(convert func(s string) string) func(args []cell.Value) (cell.Value, error) {
+	return func(args []cell.Value) (cell.Value, error) {
+		if args[0].IsNull() {
+			return args[0], nil
+		}
+		return cell.TextValue(convert(args[0].String())), nil
+	}
+}
+
+// lowerASCII and upperASCII only convert ASCII letters, as SQLite does without the ICU extension
+func lowerASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+func upperASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}, s)
+}
+
+// substr returns the characters of text, or the bytes of a blob, from the 1-based position args[1] on, the
+// length args[2] counting backwards when it is negative. A negative position counts from the end.
+func substr(args []cell.Value) (cell.Value, error) {
+	if anyNull(args) {
+		return cell.NullValue(), nil
+	}
+
+	v := args[0]
+	isBlob := v.Type == cell.ValueTypeBlob
+	var units []rune
+	if !isBlob {
+		units = []rune(v.String())
+	}
+	size := int64(len(units))
+	if isBlob {
+		size = int64(len(v.Bytes))
+	}
+
+	start := toInteger(args[1])
+	count, backwards := int64(math.MaxInt64), false
+	if len(args) == 3 {
+		count = toInteger(args[2])
+		if count < 0 {
+			count, backwards = -max(count, -math.MaxInt64), true
+		}
+	}
+	switch {
+	case start < 0:
+		start += size
+		if start < 0 {
+			count += start
+			start = 0
+		}
+	case start > 0:
+		start--
+	case count > 0:
+		// position 0 is before the first character, which takes one of the characters asked for
+		count--
+	}
+	if backwards {
+		start -= count
+		if start < 0 {
+			count += start
+			start = 0
+		}
+	}
+	start = min(start, size)
+	count = min(max(count, 0), size-start)
+
+	if isBlob {
+		return cell.BlobValue(bytes.Clone(v.Bytes[start : start+count])), nil
+	}
+	return cell.TextValue(string(units[start : start+count])), nil
+}
+
+// trimFunction returns trim, ltrim or rtrim, removing the characters of args[1], spaces by default, from the
+// left and the right of the text of args[0]
+func trimFunction(left, right bool) func(args []cell.Value) (cell.Value, error) {
+	return func(args []cell.Value) (cell.Value, error) {
+		if anyNull(args) {
+			return cell.NullValue(), nil
+		}
+		s, cutset := args[0].String(), " "
+		if len(args) == 2 {
+			cutset = args[1].String()
+		}
+		if left {
+			s = strings.TrimLeft(s, cutset)
+		}
+		if right {
+			s = strings.TrimRight(s, cutset)
+		}
+		return cell.TextValue(s), nil
+	}
+}
+
+// replace replaces every occurrence of args[1] in the text of args[0] with args[2]
+func replace(args []cell.Value) (cell.Value, error) {
+	if anyNull(args) {
+		return cell.NullValue(), nil
+	}
+	s, old := args[0].String(), args[1].String()
+	if old == "" {
+		return cell.TextValue(s), nil
+	}
+	return cell.TextValue(strings.ReplaceAll(s, old, args[2].String())), nil
+}
+
+// instr returns the 1-based position of the first occurrence of args[1] in args[0], in characters, or in bytes
+// when both are blobs, and 0 when there is none
+func instr(args []cell.Value) (cell.Value, error) {
+	if anyNull(args) {
+		return cell.NullValue(), nil
+	}
+	if args[0].Type == cell.ValueTypeBlob && args[1].Type == cell.ValueTypeBlob {
+		return cell.IntegerValue(int64(bytes.Index(args[0].Bytes, args[1].Bytes) + 1)), nil
+	}
+
+	s := args[0].String()
+	i := strings.Index(s, args[1].String())
+	if i < 0 {
+		return cell.IntegerValue(0), nil
+	}
+	return cell.IntegerValue(int64(utf8.RuneCountInString(s[:i]) + 1)), nil
+}
+
+// abs keeps integers integers, failing on the lowest one whose absolute value does not fit, and converts
+// anything else to a REAL
+func abs(args []cell.Value) (cell.Value, error) {
+	v := args[0]
+	switch v.Type {
+	case cell.ValueTypeNull:
+		return v, nil
+	case cell.ValueTypeInteger:
+		if v.Integer == math.MinInt64 {
+			return cell.Value{}, errors.New("integer overflow")
+		}
+		if v.Integer < 0 {
+			return cell.IntegerValue(-v.Integer), nil
+		}
+		return v, nil
+	default:
+		return cell.RealValue(math.Abs(toReal(v))), nil
+	}
+}
+
+// round rounds half away from zero to args[1] digits after the decimal point, between 0 and 30, always
+// returning a REAL
+func round(args []cell.Value) (cell.Value, error) {
+	if anyNull(args) {
+		return cell.NullValue(), nil
+	}
+	digits := int64(0)
+	if len(args) == 2 {
+		digits = min(max(toInteger(args[1]), 0), 30)
+	}
+
+	f := toReal(args[0])
+	switch {
+	// beyond 2^52 a REAL has no fractional part to round
+	case f < -4503599627370496 || f > 4503599627370496:
+	case digits == 0:
+		f = float64(int64(f + math.Copysign(0.5, f)))
+	default:
+		rounded, _ := strconv.ParseFloat(formatFixed(math.Abs(f), int(digits)), 64)
+		f = math.Copysign(rounded, f)
+	}
+	return cell.RealValue(f), nil
+}
+
+// coalesce returns its first argument that is not NULL, the following ones being left unevaluated
+func coalesce(e *evaluator, args []sql.Expr) (cell.Value, Affinity, error) {
+	for _, arg := range args {
+		v, _, err := e.eval(arg)
+		if err != nil || !v.IsNull() {
+			return v, AffinityNone, err
+		}
+	}
+	return cell.NullValue(), AffinityNone, nil
+}
+
+// nullif returns NULL when its arguments are equal, and the first one otherwise
+func nullif(args []cell.Value, c Comparator) (cell.Value, error) {
+	if c.Compare(args[0], args[1]) == 0 {
+		return cell.NullValue(), nil
+	}
+	return args[0], nil
+}
+
+// iif evaluates args[1] when args[0] is true and args[2] otherwise, NULL when it is missing
+func iif(e *evaluator, args []sql.Expr) (cell.Value, Affinity, error) {
+	cond, _, err := e.eval(args[0])
+	if err != nil {
+		return cell.Value{}, AffinityNone, err
+	}
+	switch {
+	case IsTrue(cond):
+		v, _, err := e.eval(args[1])
+		return v, AffinityNone, err
+	case len(args) == 3:
+		v, _, err := e.eval(args[2])
+		return v, AffinityNone, err
+	default:
+		return cell.NullValue(), AffinityNone, nil
+	}
+}
+
+func typeOf(args []cell.Value) (cell.Value, error) {
+	return cell.TextValue(args[0].Type.String()), nil
+}
+
+// hexFunction renders the bytes of a blob, or of the text of any other value, in upper case hexadecimal digits.
+// Text is rendered in the encoding of the database, numbers in UTF-8.
+func hexFunction(args []cell.Value, enc header.TextEncoding) (cell.Value, error) {
+	var b []byte
+	switch args[0].Type {
+	case cell.ValueTypeBlob:
+		b = args[0].Bytes
+	case cell.ValueTypeText:
+		b = cell.EncodeText(enc, args[0].Bytes)
+	default:
+		b = []byte(args[0].String())
+	}
+	return cell.TextValue(strings.ToUpper(hex.EncodeToString(b))), nil
+}
+
+// quote renders its argument as an SQL literal, reals with enough digits to read back as the same value
+func quote(args []cell.Value) (cell.Value, error) {
+	v := args[0]
+	if v.Type != cell.ValueTypeReal {
+		return cell.TextValue(v.Quote()), nil
+	}
+
+	switch {
+	case math.IsInf(v.Real, 1):
+		return cell.TextValue("9.0e+999"), nil
+	case math.IsInf(v.Real, -1):
+		return cell.TextValue("-9.0e+999"), nil
+	}
+	s := cell.FormatReal(v.Real)
+	if f, err := strconv.ParseFloat(s, 64); err != nil || f != v.Real {
+		s = strconv.FormatFloat(v.Real, 'e', 18, 64)
+	}
+	return cell.TextValue(s), nil
+}
+
+// unicodeFunction returns the code point of the first character of the text of its argument
+func unicodeFunction(args []cell.Value) (cell.Value, error) {
+	if args[0].IsNull() {
+		return args[0], nil
+	}
+	r, size := utf8.DecodeRuneInString(args[0].String())
+	if size == 0 {
+		return cell.NullValue(), nil
+	}
+	return cell.IntegerValue(int64(r)), nil
+}
+
+// char returns the text made of the characters whose code points are args, invalid ones turning into U+FFFD
+func char(args []cell.Value) (cell.Value, error) {
+	var b strings.Builder
+	for _, v := range args {
+		c := toInteger(v)
+		if c < 0 || c > unicode.MaxRune {
+			c = utf8.RuneError
+		}
+		b.WriteRune(rune(c))
+	}
+	return cell.TextValue(b.String()), nil
+}
+
+func random([]cell.Value) (cell.Value, error) {
+	return cell.IntegerValue(int64(rand.Uint64())), nil
+}
+
+// minMax returns the scalar min() when sign is -1 and max() when it is 1, which are NULL when an argument is
+func minMax(sign int) func(args []cell.Value, c Comparator) (cell.Value, error) {
+	return func(args []cell.Value, c Comparator) (cell.Value, error) {
+		if anyNull(args) {
+			return cell.NullValue(), nil
+		}
+		v := args[0]
+		for _, arg := range args[1:] {
+			if c.Compare(arg, v)*sign > 0 {
+				v = arg
+			}
+		}
+		return v, nil
+	}
+}
@@ -0,0 +1,185 @@
+package eval
+
+import (
+	"unicode/utf8"
+)
+
+// noEscape is the escape character of a LIKE pattern without ESCAPE clause, which matches no character
+const noEscape = -1
+
+// Like matches s against a LIKE pattern, where % matches any sequence and _ any single character.
+// Like SQLite's default, only ASCII letters are compared case-insensitively.
+func Like(pattern, s string) bool {
+	return like(pattern, s, noEscape)
+}
+
+// like matches s against a LIKE pattern in which the character following escape matches itself, even when it
+// is % or _. A pattern ending with escape matches nothing.
+func like(pattern, s string, escape rune) bool {
+	for len(pattern) > 0 {
+		p, size := utf8.DecodeRuneInString(pattern)
+		if p == escape {
+			if len(pattern) == size {
+				return false
+			}
+			pattern = pattern[size:]
+			p, size = utf8.DecodeRuneInString(pattern)
+			if !matchLikeRune(p, &s) {
+				return false
+			}
+			pattern = pattern[size:]
+			continue
+		}
+
+		switch p {
+		case '%':
+			rest := pattern[size:]
+			for len(rest) > 0 && rest[0] == '%' {
+				rest = rest[1:]
+			}
+			if rest == "" {
+				return true
+			}
+			for i := 0; i <= len(s); {
+				if like(rest, s[i:], escape) {
+					return true
+				}
+				if i == len(s) {
+					break
+				}
+				_, n := utf8.DecodeRuneInString(s[i:])
+				i += n
+			}
+			return false
+		case '_':
+			if s == "" {
+				return false
+			}
+			_, n := utf8.DecodeRuneInString(s)
+			s = s[n:]
+		default:
+			if !matchLikeRune(p, &s) {
+				return false
+			}
+		}
+		pattern = pattern[size:]
+	}
+	return s == ""
+}
+
+// matchLikeRune reports whether *s starts with p, ignoring the case of ASCII letters, and moves *s past it
+func matchLikeRune(p rune, s *string) bool {
+	if *s == "" {
+		return false
+	}
+	c, n := utf8.DecodeRuneInString(*s)
+	if foldASCII(c) != foldASCII(p) {
+		return false
+	}
+	*s = (*s)[n:]
+	return true
+}
+
+func foldASCII(r rune) rune {
+	if 'A' <= r && r <= 'Z' {
+		return r + 'a' - 'A'
+	}
+	return r
+}
+
+// Glob matches s against a GLOB pattern, where * matches any sequence, ? any single character
+// and [...] a character class. Matching is case sensitive.
+func Glob(pattern, s string) bool {
+	for len(pattern) > 0 {
+		p, size := utf8.DecodeRuneInString(pattern)
+		switch p {
+		case '*':
+			rest := pattern[size:]
+			for len(rest) > 0 && rest[0] == '*' {
+				rest = rest[1:]
+			}
+			if rest == "" {
+				return true
+			}
+			for i := 0; i <= len(s); {
+				if Glob(rest, s[i:]) {
+					return true
+				}
+				if i == len(s) {
+					break
+				}
+				_, n := utf8.DecodeRuneInString(s[i:])
+				i += n
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+			_, n := utf8.DecodeRuneInString(s)
+			s = s[n:]
+		case '[':
+			if s == "" {
+				return false
+			}
+			c, n := utf8.DecodeRuneInString(s)
+			matched, classSize, ok := matchClass(pattern[size:], c)
+			if !ok {
+				// an unterminated class matches nothing
+				return false
+			}
+			if !matched {
+				return false
+			}
+			s = s[n:]
+			pattern = pattern[size+classSize:]
+			continue
+		default:
+			if s == "" {
+				return false
+			}
+			c, n := utf8.DecodeRuneInString(s)
+			if c != p {
+				return false
+			}
+			s = s[n:]
+		}
+		pattern = pattern[size:]
+	}
+	return s == ""
+}
+
+// matchClass matches c against the body of a [...] class, returning whether it matched and the size of the body
+// including the closing bracket
+func matchClass(class string, c rune) (bool, int, bool) {
+	i := 0
+	negate := false
+	if i < len(class) && class[i] == '^' {
+		negate = true
+		i++
+	}
+
+	matched := false
+	first := true
+	for i < len(class) {
+		r, n := utf8.DecodeRuneInString(class[i:])
+		if r == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+		first = false
+		i += n
+
+		if i+1 < len(class) && class[i] == '-' && class[i+1] != ']' {
+			hi, m := utf8.DecodeRuneInString(class[i+1:])
+			if r <= c && c <= hi {
+				matched = true
+			}
+			i += 1 + m
+			continue
+		}
+		if r == c {
+			matched = true
+		}
+	}
+	return false, 0, false
+}
@@ -0,0 +1,442 @@
+package eval
+
+import (
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSignificantDigits is the number of significant digits reals are printed with, further digits being zeros,
+// and maxAltSignificantDigits the number printed with the ! flag
+const (
+	maxSignificantDigits    = 16
+	maxAltSignificantDigits = 19
+)
+
+// printfSpec is a conversion specification of a printf format, e.g. %-10.3f
+type printfSpec struct {
+	leftAlign, plus, space, alt, alt2, zeroPad, comma bool
+	width                                             int
+	// precision is -1 when it is not given
+	precision int
+	verb      byte
+}
+
+// printf formats its arguments like SQLite's printf(): the arguments are converted to what each conversion
+// takes, e.g. text to an integer for %d, missing ones reading as NULL. Width and precision count bytes for
+// text unless the ! flag is given.
+func printf(args []cell.Value) (cell.Value, error) {
+	if len(args) == 0 || args[0].IsNull() {
+		return cell.NullValue(), nil
+	}
+	format, args := args[0].String(), args[1:]
+	next := func() cell.Value {
+		if len(args) == 0 {
+			return cell.NullValue()
+		}
+		v := args[0]
+		args = args[1:]
+		return v
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i == len(format)-1 {
+			b.WriteByte(format[i])
+			continue
+		}
+
+		spec, size, ok := parsePrintfSpec(format[i+1:], func() int64 { return toInteger(next()) })
+		if !ok {
+			// like SQLite, the output stops at an unknown conversion
+			break
+		}
+		i += size
+		if spec.verb == '%' {
+			b.WriteString(spec.pad("%", false))
+			continue
+		}
+		b.WriteString(spec.format(next()))
+	}
+	return cell.TextValue(b.String()), nil
+}
+
+// parsePrintfSpec reads the specification following a %, returning the number of bytes it spans. Widths and
+// precisions given as * are read from the arguments with arg.
+func parsePrintfSpec(s string, arg func() int64) (*printfSpec, int, bool) {
+	spec := &printfSpec{precision: -1}
+	i := 0
+flags:
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '-':
+			spec.leftAlign = true
+		case '+':
+			spec.plus = true
+		case ' ':
+			spec.space = true
+		case '#':
+			spec.alt = true
+		case '!':
+			spec.alt2 = true
+		case '0':
+			spec.zeroPad = true
+		case ',':
+			spec.comma = true
+		default:
+			break flags
+		}
+	}
+
+	if i < len(s) && s[i] == '*' {
+		w := arg()
+		if w < 0 {
+			spec.leftAlign, w = true, -w
+		}
+		spec.width = int(min(w, math.MaxInt32))
+		i++
+	} else {
+		for ; i < len(s) && isDigit(s[i]); i++ {
+			spec.width = min(spec.width*10+int(s[i]-'0'), math.MaxInt32)
+		}
+	}
+
+	if i < len(s) && s[i] == '.' {
+		i++
+		spec.precision = 0
+		if i < len(s) && s[i] == '*' {
+			p := arg()
+			spec.precision = int(min(max(p, -1), math.MaxInt32))
+			i++
+		} else {
+			for ; i < len(s) && isDigit(s[i]); i++ {
+				spec.precision = min(spec.precision*10+int(s[i]-'0'), math.MaxInt32)
+			}
+		}
+	}
+
+	// the length modifiers of C make no difference
+	for i < len(s) && s[i] == 'l' {
+		i++
+	}
+	if i >= len(s) || !strings.ContainsRune("diuxXofeEgGcszqQw%", rune(s[i])) {
+		return nil, 0, false
+	}
+	spec.verb = s[i]
+	return spec, i + 1, true
+}
+
+// format renders v with the conversion of spec
+func (spec *printfSpec) format(v cell.Value) string {
+	switch spec.verb {
+	case 'd', 'i':
+		return spec.formatInteger(toInteger(v))
+	case 'u', 'x', 'X', 'o':
+		return spec.formatUnsigned(uint64(toInteger(v)))
+	case 'f', 'e', 'E', 'g', 'G':
+		return spec.formatReal(toReal(v))
+	case 'c':
+		r, _ := utf8.DecodeRuneInString(v.String())
+		if r == utf8.RuneError {
+			return spec.pad("", false)
+		}
+		return spec.pad(strings.Repeat(string(r), max(spec.precision, 1)), false)
+	case 'q', 'Q', 'w':
+		return spec.pad(spec.formatQuoted(v), false)
+	default:
+		return spec.pad(spec.truncate(v.String()), false)
+	}
+}
+
+func (spec *printfSpec) formatInteger(n int64) string {
+	digits := strconv.FormatUint(absUint(n), 10)
+	if spec.precision > len(digits) {
+		digits = strings.Repeat("0", spec.precision-len(digits)) + digits
+	}
+	if spec.comma {
+		digits = groupThousands(digits)
+	}
+	return spec.pad(spec.sign(n < 0)+digits, true)
+}
+
+func (spec *printfSpec) formatUnsigned(n uint64) string {
+	var digits, prefix string
+	switch spec.verb {
+	case 'x':
+		digits, prefix = strconv.FormatUint(n, 16), "0x"
+	case 'X':
+		digits, prefix = strings.ToUpper(strconv.FormatUint(n, 16)), "0X"
+	case 'o':
+		digits, prefix = strconv.FormatUint(n, 8), "0"
+	default:
+		digits = strconv.FormatUint(n, 10)
+	}
+	if spec.precision > len(digits) {
+		digits = strings.Repeat("0", spec.precision-len(digits)) + digits
+	}
+	if spec.comma && spec.verb == 'u' {
+		digits = groupThousands(digits)
+	}
+	if spec.alt && n != 0 && prefix != "" {
+		digits = prefix + digits
+	}
+	return spec.pad(digits, true)
+}
+
+func (spec *printfSpec) formatReal(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return spec.pad("NaN", false)
+	case math.IsInf(f, 0):
+		return spec.pad(spec.sign(f < 0)+"Inf", false)
+	}
+
+	precision := spec.precision
+	if precision < 0 {
+		precision = 6
+	}
+	maxDigits := maxSignificantDigits
+	if spec.alt2 {
+		maxDigits = maxAltSignificantDigits
+	}
+
+	var s string
+	switch spec.verb {
+	case 'f':
+		digits, point := decimalDigits(math.Abs(f), -precision, maxDigits)
+		s = formatDigits(digits, point, precision, spec.alt)
+	case 'e', 'E':
+		digits, point := decimalDigits(math.Abs(f), precision+1, maxDigits)
+		s = formatExponent(digits, point, precision, spec.alt, spec.verb)
+	default:
+		precision = max(precision, 1)
+		digits, point := decimalDigits(math.Abs(f), precision, maxDigits)
+		exponent := point - 1
+		if digits == "0" {
+			exponent = 0
+		}
+		verb := byte('e')
+		if spec.verb == 'G' {
+			verb = 'E'
+		}
+		if exponent < -4 || exponent >= precision {
+			s = formatExponent(digits, point, precision-1, spec.alt, verb)
+		} else {
+			s = formatDigits(digits, point, precision-1-exponent, spec.alt)
+		}
+	}
+	// %g drops the trailing zeros of the fraction unless # is given, and ! drops them for %f and %e too, keeping
+	// one zero after the decimal point
+	if !spec.alt && (spec.alt2 || spec.verb == 'g' || spec.verb == 'G') {
+		s = trimFraction(s, spec.alt2)
+	}
+	return spec.pad(spec.sign(f < 0)+s, true)
+}
+
+// formatQuoted renders %q, doubling single quotes, %Q, also quoting the text and rendering NULL as NULL, and %w,
+// doubling double quotes
+func (spec *printfSpec) formatQuoted(v cell.Value) string {
+	if v.IsNull() {
+		switch spec.verb {
+		case 'Q':
+			return "NULL"
+		default:
+			return "(NULL)"
+		}
+	}
+
+	s := spec.truncate(v.String())
+	switch spec.verb {
+	case 'q':
+		return strings.ReplaceAll(s, "'", "''")
+	case 'Q':
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	default:
+		return strings.ReplaceAll(s, `"`, `""`)
+	}
+}
+
+// truncate cuts s to the precision, in bytes, or in characters with the ! flag
+func (spec *printfSpec) truncate(s string) string {
+	if spec.precision < 0 {
+		return s
+	}
+	if !spec.alt2 {
+		return s[:min(spec.precision, len(s))]
+	}
+	runes := []rune(s)
+	return string(runes[:min(spec.precision, len(runes))])
+}
+
+// sign returns the sign a number is printed with
+func (spec *printfSpec) sign(negative bool) string {
+	switch {
+	case negative:
+		return "-"
+	case spec.plus:
+		return "+"
+	case spec.space:
+		return " "
+	default:
+		return ""
+	}
+}
+
+// pad pads s to the width, with spaces on the left, or on the right when left-aligned, or with zeros following
+// the sign when numeric is set and zero padding is asked for. Width counts bytes unless the ! flag is given.
+func (spec *printfSpec) pad(s string, numeric bool) string {
+	size := len(s)
+	if spec.alt2 {
+		size = utf8.RuneCountInString(s)
+	}
+	n := spec.width - size
+	switch {
+	case n <= 0:
+		return s
+	case spec.leftAlign:
+		return s + strings.Repeat(" ", n)
+	case numeric && spec.zeroPad:
+		sign := ""
+		if s != "" && strings.ContainsRune("+- ", rune(s[0])) {
+			sign, s = s[:1], s[1:]
+		}
+		return sign + strings.Repeat("0", n) + s
+	default:
+		return strings.Repeat(" ", n) + s
+	}
+}
+
+func absUint(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+// groupThousands separates groups of three digits with commas
+func groupThousands(digits string) string {
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// decimalDigits returns the significant digits of f, which is not negative, and the position of the decimal point
+// among them, e.g. "267" and 1 for 2.67, or "0" and 1 for zero. The digits are rounded half up to round
+// significant digits when round is positive and to -round digits after the decimal point otherwise, and to
+// maxDigits significant digits at most. As in SQLite, rounding starts from 19 significant digits, so that 2.675,
+// which is stored as 2.67499999..., rounds to 2.67 with two decimals.
+func decimalDigits(f float64, round, maxDigits int) (string, int) {
+	mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(f, 'e', 18, 64), "e")
+	digits := []byte(mantissa[:1] + mantissa[2:])
+	e, _ := strconv.Atoi(exponent)
+	point := e + 1
+	if f == 0 {
+		return "0", 1
+	}
+
+	n := round
+	if round <= 0 {
+		n = point - round
+	}
+	n = min(n, maxDigits)
+	if n < 0 {
+		return "0", 1
+	}
+	if n < len(digits) {
+		up := digits[n] >= '5'
+		digits = digits[:n]
+		if up {
+			i := len(digits) - 1
+			for ; i >= 0 && digits[i] == '9'; i-- {
+				digits[i] = '0'
+			}
+			if i >= 0 {
+				digits[i]++
+			} else {
+				digits = append([]byte{'1'}, digits...)
+				point++
+			}
+		}
+	}
+
+	s := strings.TrimRight(string(digits), "0")
+	if s == "" {
+		return "0", 1
+	}
+	return s, point
+}
+
+// formatFixed renders f, which is not negative, like %!.<precision>f
+func formatFixed(f float64, precision int) string {
+	digits, point := decimalDigits(f, -precision, maxAltSignificantDigits)
+	return formatDigits(digits, point, precision, false)
+}
+
+// formatDigits renders digits with the decimal point at point and precision digits after it, padding with zeros.
+// The decimal point is left out without fraction unless alt is set.
+func formatDigits(digits string, point, precision int, alt bool) string {
+	digit := func(i int) byte {
+		if i < 0 || i >= len(digits) {
+			return '0'
+		}
+		return digits[i]
+	}
+
+	var b strings.Builder
+	if point <= 0 {
+		b.WriteByte('0')
+	}
+	for i := 0; i < point; i++ {
+		b.WriteByte(digit(i))
+	}
+	if precision > 0 || alt {
+		b.WriteByte('.')
+	}
+	for i := 0; i < precision; i++ {
+		b.WriteByte(digit(point + i))
+	}
+	return b.String()
+}
+
+// formatExponent renders digits in scientific notation with precision digits after the decimal point, e.g.
+// 1.235e+04, the exponent taking two digits at least
+func formatExponent(digits string, point, precision int, alt bool, verb byte) string {
+	exponent := point - 1
+	if digits == "0" {
+		exponent = 0
+	}
+	s := formatDigits(digits, 1, precision, alt)
+	sign := '+'
+	if exponent < 0 {
+		sign, exponent = '-', -exponent
+	}
+	e := strconv.Itoa(exponent)
+	if len(e) < 2 {
+		e = "0" + e
+	}
+	return s + string(verb) + string(sign) + e
+}
+
+// trimFraction drops the trailing zeros of the fraction of a number, and its decimal point when nothing is left
+// after it, unless keepPoint is set, which keeps ".0"
+func trimFraction(s string, keepPoint bool) string {
+	end := strings.IndexAny(s, "eE")
+	if end < 0 {
+		end = len(s)
+	}
+	mantissa, exponent := s[:end], s[end:]
+	if strings.Contains(mantissa, ".") {
+		mantissa = strings.TrimSuffix(strings.TrimRight(mantissa, "0"), ".")
+	}
+	if keepPoint && !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	return mantissa + exponent
+}
@@ -1,10 +1,8 @@
 package header
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
-	"os"
 )
 
 type PageType uint
@@ -37,49 +35,32 @@ func (t PageType) GetBTreeHeaderSize() (uint, error) {
 	}
 }
 
-func NewBTreeHeader(f *os.File, offset uint) (*BTreeHeader, uint, error) {
-	buf := make([]byte, 1)
-	if _, err := f.ReadAt(buf, int64(offset)); err != nil {
-		return nil, 0, err
-	}
-
-	var pageTypeNum uint8
-	if err := binary.Read(bytes.NewReader(buf), binary.BigEndian, &pageTypeNum); err != nil {
-		return nil, 0, err
+// NewBTreeHeader decodes the b-tree header starting at offset in the page buffer, returning its size
+func NewBTreeHeader(page []byte, offset uint) (*BTreeHeader, uint, error) {
+	if offset >= uint(len(page)) {
+		return nil, 0, fmt.Errorf("b-tree header offset %d is out of the page", offset)
 	}
-	pageType := PageType(pageTypeNum)
+	pageType := PageType(page[offset])
 
 	bTreeHeaderSize, err := pageType.GetBTreeHeaderSize()
 	if err != nil {
 		return nil, 0, err
 	}
 
-	buf = make([]byte, bTreeHeaderSize)
-	if _, err := f.ReadAt(buf, int64(offset)); err != nil {
-		return nil, 0, err
-	}
-
-	var cellCount uint16
-	if err := binary.Read(bytes.NewReader(buf[3:5]), binary.BigEndian, &cellCount); err != nil {
-		return nil, 0, err
-	}
-
-	var cellContentAreaStartsAt uint16
-	if err := binary.Read(bytes.NewReader(buf[5:7]), binary.BigEndian, &cellContentAreaStartsAt); err != nil {
-		return nil, 0, err
+	if offset+bTreeHeaderSize > uint(len(page)) {
+		return nil, 0, fmt.Errorf("b-tree header at offset %d is truncated", offset)
 	}
+	buf := page[offset : offset+bTreeHeaderSize]
 
 	var rightMostPointer uint32
 	if pageType == InteriorTableBTree || pageType == InteriorIndexBTree {
-		if err := binary.Read(bytes.NewReader(buf[8:12]), binary.BigEndian, &rightMostPointer); err != nil {
-			return nil, 0, err
-		}
+		rightMostPointer = binary.BigEndian.Uint32(buf[8:12])
 	}
 
 	return &BTreeHeader{
 		PageType:                pageType,
-		CellCount:               cellCount,
-		CellContentAreaStartsAt: cellContentAreaStartsAt,
+		CellCount:               binary.BigEndian.Uint16(buf[3:5]),
+		CellContentAreaStartsAt: binary.BigEndian.Uint16(buf[5:7]),
 		RightMostPointer:        uint(rightMostPointer),
 	}, bTreeHeaderSize, nil
 }
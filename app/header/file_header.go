@@ -1,11 +1,9 @@
 package header
 
 import (
-	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"os"
 )
 
 const (
@@ -14,14 +12,66 @@ const (
 	fileHeaderString         = "SQLite format 3\000"
 )
 
+// FileVersionWAL is the file format write and read version of a database in WAL mode
+const FileVersionWAL = 2
+
+// TextEncoding is the encoding of all the text of a database
+type TextEncoding uint32
+
+const (
+	TextEncodingUTF8    TextEncoding = 1
+	TextEncodingUTF16LE TextEncoding = 2
+	TextEncodingUTF16BE TextEncoding = 3
+)
+
+// String returns the name of the encoding as the sqlite3 shell prints it, empty for an unknown one
+func (e TextEncoding) String() string {
+	switch e {
+	case TextEncodingUTF8:
+		return "utf8"
+	case TextEncodingUTF16LE:
+		return "utf16le"
+	case TextEncodingUTF16BE:
+		return "utf16be"
+	default:
+		return ""
+	}
+}
+
+// FileHeader is the 100-byte header of a database file. See https://www.sqlite.org/fileformat.html#the_database_header
 type FileHeader struct {
-	PageSize uint16
+	// PageSize is in bytes, the value 1 of the header meaning 65536
+	PageSize uint32
+	// WriteVersion and ReadVersion are 1 for the legacy rollback journal and FileVersionWAL for WAL mode
+	WriteVersion uint8
+	ReadVersion  uint8
+	// ReservedBytes is the size of the space at the end of every page that extensions use
+	ReservedBytes       uint8
+	MaxPayloadFraction  uint8
+	MinPayloadFraction  uint8
+	LeafPayloadFraction uint8
+	FileChangeCounter   uint32
+	// DatabaseSize is the size of the database in pages, only valid when VersionValidFor equals FileChangeCounter
+	DatabaseSize           uint32
+	FirstFreelistTrunkPage uint32
+	FreelistPageCount      uint32
+	SchemaCookie           uint32
+	SchemaFormat           uint32
+	DefaultCacheSize       uint32
+	// LargestRootPage is the page number of the largest root b-tree page in auto-vacuum modes, 0 otherwise
+	LargestRootPage     uint32
+	TextEncoding        TextEncoding
+	UserVersion         uint32
+	IncrementalVacuum   uint32
+	ApplicationID       uint32
+	VersionValidFor     uint32
+	SQLiteVersionNumber uint32
 }
 
-func NewFileHeader(f *os.File) (*FileHeader, uint, error) {
-	buf := make([]byte, FileHeaderSize)
-	if _, err := f.Read(buf); err != nil {
-		return nil, 0, err
+// NewFileHeader decodes the file header at the start of buf, e.g. the first page
+func NewFileHeader(buf []byte) (*FileHeader, uint, error) {
+	if len(buf) < FileHeaderSize {
+		return nil, 0, errors.New("invalid file header")
 	}
 
 	headerStrBuf := string(buf[0:fileHeaderInitStringSize])
@@ -29,10 +79,38 @@ func NewFileHeader(f *os.File) (*FileHeader, uint, error) {
 		return nil, 0, errors.New("invalid file header")
 	}
 
-	var pageSize uint16
-	if err := binary.Read(bytes.NewReader(buf[16:18]), binary.BigEndian, &pageSize); err != nil {
-		return nil, 0, fmt.Errorf("failed to read integer: %v", err)
+	pageSize := uint32(binary.BigEndian.Uint16(buf[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, 0, fmt.Errorf("invalid page size: %d", pageSize)
 	}
 
-	return &FileHeader{pageSize}, FileHeaderSize, nil
+	u32 := func(offset int) uint32 {
+		return binary.BigEndian.Uint32(buf[offset : offset+4])
+	}
+	return &FileHeader{
+		PageSize:               pageSize,
+		WriteVersion:           buf[18],
+		ReadVersion:            buf[19],
+		ReservedBytes:          buf[20],
+		MaxPayloadFraction:     buf[21],
+		MinPayloadFraction:     buf[22],
+		LeafPayloadFraction:    buf[23],
+		FileChangeCounter:      u32(24),
+		DatabaseSize:           u32(28),
+		FirstFreelistTrunkPage: u32(32),
+		FreelistPageCount:      u32(36),
+		SchemaCookie:           u32(40),
+		SchemaFormat:           u32(44),
+		DefaultCacheSize:       u32(48),
+		LargestRootPage:        u32(52),
+		TextEncoding:           TextEncoding(u32(56)),
+		UserVersion:            u32(60),
+		IncrementalVacuum:      u32(64),
+		ApplicationID:          u32(68),
+		VersionValidFor:        u32(92),
+		SQLiteVersionNumber:    u32(96),
+	}, FileHeaderSize, nil
 }
@@ -1,23 +1,29 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"github/com/codecrafters-io/sqlite-starter-go/app/parser"
+	"github/com/codecrafters-io/sqlite-starter-go/app/output"
+	"github/com/codecrafters-io/sqlite-starter-go/app/shell"
 	"github/com/codecrafters-io/sqlite-starter-go/app/sqlite"
-	"github/com/codecrafters-io/sqlite-starter-go/app/utils"
 	"log"
 	"os"
 	"strings"
 	// Available if you need it!
 	// "github.com/xwb1989/sqlparser"
-
-	"github.com/rqlite/sql"
 )
 
-// Usage: your_sqlite3.sh sample.sqlite .dbinfo
+// Usage: your_sqlite3.sh [OPTIONS] sample.sqlite [.dbinfo | SQL]
+//
+// Without a command, statements and dot commands are read from the standard input, interactively when it is
+// a terminal. The options set how results are printed, e.g. -csv, -header or -nullvalue NULL.
 func main() {
-	databaseFilePath := os.Args[1]
-	command := os.Args[2]
+	opts := output.NewOptions()
+	databaseFilePath, command, err := parseArgs(os.Args[1:], opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\nUsage: your_sqlite3.sh [OPTIONS] FILENAME [COMMAND]\n", err)
+		os.Exit(1)
+	}
 
 	f, err := os.Open(databaseFilePath)
 	if err != nil {
@@ -33,44 +39,103 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
 
-	switch command {
-	case ".dbinfo":
-		fmt.Println("Logs from your program will appear here!")
-		fmt.Printf("database page size: %v\n", db.PageSize())
-
-		fmt.Printf("number of tables: %v\n", db.TableCount())
-	case ".tables":
-		fmt.Println(strings.Join(db.Tables(), " "))
-	default:
-		stmt, err := parser.NewStatement(command)
-		if err != nil {
+	sh := shell.New(db, os.Stdout, os.Stderr)
+	sh.SetOptions(opts)
+	if command != "" {
+		if err := sh.Execute(command); err != nil {
 			log.Fatal(err)
 		}
+		return
+	}
+
+	interactive := isTerminal(os.Stdin)
+	if interactive {
+		if history := openHistory(); history != nil {
+			defer history.Close()
+			sh.SetHistory(history)
+		}
+	}
+	if err := sh.Run(os.Stdin, interactive); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseArgs reads the options preceding the database file path and the command following it, which may be empty.
+// The options are named after the modes, e.g. -json, or take a value, e.g. -separator ";".
+func parseArgs(args []string, opts *output.Options) (string, string, error) {
+	positional := make([]string, 0, 2)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || len(positional) > 0 {
+			positional = append(positional, arg)
+			continue
+		}
 
-		switch stmt.(type) {
-		case *sql.SelectStatement:
-			isCountStmt, err := parser.IsCountStatement(command, stmt)
-			if err != nil {
-				log.Fatal(err)
+		name := strings.TrimLeft(arg, "-")
+		value := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("missing argument to %s", arg)
 			}
-			if isCountStmt {
-				count, err := db.Count(command)
-				if err != nil {
-					log.Fatal(err)
-				}
-				fmt.Println(count)
-			} else {
-				cells, err := db.Select(command)
-				if err != nil {
-					log.Fatal(err)
-				}
-				rows, err := cells.RowsInStrings()
-				if err != nil {
-					log.Fatal(err)
-				}
-				utils.PrintRows(rows)
+			i++
+			return args[i], nil
+		}
+		var err error
+		switch name {
+		case "header", "headers":
+			opts.SetHeaders(true)
+		case "noheader", "noheaders":
+			opts.SetHeaders(false)
+		case "separator":
+			opts.ColumnSeparator, err = value()
+		case "newline":
+			opts.RowSeparator, err = value()
+		case "nullvalue":
+			opts.NullValue, err = value()
+		default:
+			mode, modeErr := output.ParseMode(name)
+			if modeErr != nil {
+				return "", "", fmt.Errorf("unknown option: %s", arg)
 			}
+			opts.SetMode(mode)
 		}
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	switch len(positional) {
+	case 0:
+		return "", "", errors.New("missing database file")
+	case 1:
+		return positional[0], "", nil
+	case 2:
+		return positional[0], positional[1], nil
+	default:
+		return "", "", fmt.Errorf("too many arguments: %s", strings.Join(positional[2:], " "))
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// openHistory opens the history file for appending, returning nil when it cannot be, the session going on
+// without history
+func openHistory() *os.File {
+	path := shell.HistoryPath()
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil
 	}
+	return f
 }
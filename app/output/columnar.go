@@ -0,0 +1,260 @@
+package output
+
+import (
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// columnar prints the rows in columns as wide as their widest value, for column, markdown, table and box.
+// Values spanning several lines take as many lines, rows being separated then so that they can be told apart.
+type columnar struct {
+	w       io.Writer
+	opts    *Options
+	columns []string
+	// rows hold the lines of every value
+	rows [][][]string
+}
+
+func (f *columnar) Row(values []cell.Value) error {
+	row := make([][]string, len(values))
+	for i, v := range values {
+		row[i] = strings.Split(expandTabs(listField(v, false, f.opts)), "\n")
+	}
+	f.rows = append(f.rows, row)
+	return nil
+}
+
+// border is the characters a table is drawn with: the ones starting, crossing and ending the horizontal
+// lines above the header, below it, between rows and below the last row, and the vertical line
+type border struct {
+	top, header, between, bottom [3]string
+	horizontal, vertical         string
+}
+
+var (
+	tableBorder = &border{
+		top:        [3]string{"+", "+", "+"},
+		header:     [3]string{"+", "+", "+"},
+		between:    [3]string{"+", "+", "+"},
+		bottom:     [3]string{"+", "+", "+"},
+		horizontal: "-",
+		vertical:   "|",
+	}
+	boxBorder = &border{
+		top:        [3]string{"┌", "┬", "┐"},
+		header:     [3]string{"├", "┼", "┤"},
+		between:    [3]string{"├", "┼", "┤"},
+		bottom:     [3]string{"└", "┴", "┘"},
+		horizontal: "─",
+		vertical:   "│",
+	}
+	markdownBorder = &border{
+		header:     [3]string{"|", "|", "|"},
+		horizontal: "-",
+		vertical:   "|",
+	}
+)
+
+func (f *columnar) Flush() error {
+	if len(f.rows) == 0 {
+		return nil
+	}
+
+	widths := make([]int, len(f.columns))
+	multiLine := false
+	for i, c := range f.columns {
+		widths[i] = displayWidth(c)
+	}
+	for _, row := range f.rows {
+		for i, lines := range row {
+			multiLine = multiLine || len(lines) > 1
+			for _, line := range lines {
+				widths[i] = max(widths[i], displayWidth(line))
+			}
+		}
+	}
+
+	var b strings.Builder
+	if f.opts.Mode == ModeColumn {
+		f.writeColumns(&b, widths, multiLine)
+	} else {
+		bd := tableBorder
+		switch f.opts.Mode {
+		case ModeBox:
+			bd = boxBorder
+		case ModeMarkdown:
+			bd, multiLine = markdownBorder, false
+		}
+		f.writeTable(&b, bd, widths, multiLine)
+	}
+	f.rows = nil
+	_, err := io.WriteString(f.w, b.String())
+	return err
+}
+
+// writeColumns writes the rows of column, the names and a line of dashes heading them when Headers is set
+func (f *columnar) writeColumns(b *strings.Builder, widths []int, multiLine bool) {
+	cells := func(values []string) {
+		for i, v := range values {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			b.WriteString(pad(v, widths[i]))
+		}
+		b.WriteString("\n")
+	}
+
+	if f.opts.Headers {
+		cells(f.columns)
+		dashes := make([]string, len(widths))
+		for i, w := range widths {
+			dashes[i] = strings.Repeat("-", w)
+		}
+		cells(dashes)
+	}
+	for i, row := range f.rows {
+		if i > 0 && multiLine {
+			b.WriteString("\n")
+		}
+		for _, line := range rowLines(row) {
+			cells(line)
+		}
+	}
+}
+
+// writeTable writes the rows of markdown, table and box, headed by the names centered in their columns
+func (f *columnar) writeTable(b *strings.Builder, bd *border, widths []int, multiLine bool) {
+	rule := func(corners [3]string) {
+		if corners[0] == "" {
+			return
+		}
+		for i, w := range widths {
+			if i == 0 {
+				b.WriteString(corners[0])
+			} else {
+				b.WriteString(corners[1])
+			}
+			b.WriteString(strings.Repeat(bd.horizontal, w+2))
+		}
+		b.WriteString(corners[2] + "\n")
+	}
+	cells := func(values []string, center bool) {
+		for i, v := range values {
+			b.WriteString(bd.vertical + " ")
+			if center {
+				left := (widths[i] - displayWidth(v)) / 2
+				v = strings.Repeat(" ", left) + v
+			}
+			b.WriteString(pad(v, widths[i]) + " ")
+		}
+		b.WriteString(bd.vertical + "\n")
+	}
+
+	rule(bd.top)
+	cells(f.columns, true)
+	rule(bd.header)
+	for i, row := range f.rows {
+		if i > 0 && multiLine {
+			rule(bd.between)
+		}
+		for _, line := range rowLines(row) {
+			cells(line, false)
+		}
+	}
+	rule(bd.bottom)
+}
+
+// rowLines returns the lines a row spans, a value with fewer lines than the others being empty below its last one
+func rowLines(row [][]string) [][]string {
+	height := 1
+	for _, lines := range row {
+		height = max(height, len(lines))
+	}
+
+	lines := make([][]string, height)
+	for i := range lines {
+		lines[i] = make([]string, len(row))
+		for j, valueLines := range row {
+			if i < len(valueLines) {
+				lines[i][j] = valueLines[i]
+			}
+		}
+	}
+	return lines
+}
+
+// pad appends spaces to s up to width columns of the terminal
+func pad(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-displayWidth(s), 0))
+}
+
+// expandTabs replaces tabs with the spaces up to the next multiple of 8 columns, as a terminal shows them
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+
+	var b strings.Builder
+	col := 0
+	for _, r := range s {
+		switch r {
+		case '\t':
+			n := 8 - col%8
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+		case '\n':
+			b.WriteRune(r)
+			col = 0
+		default:
+			b.WriteRune(r)
+			col += runeWidth(r)
+		}
+	}
+	return b.String()
+}
+
+// displayWidth returns the number of terminal columns s takes, wide East Asian characters taking two and
+// combining marks none
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || r == '\u200b':
+		return 0
+	case unicode.Is(wide, r):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// wide are the East Asian wide and fullwidth characters, and the emoji a terminal shows two columns wide
+var wide = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x1100, Hi: 0x115f, Stride: 1},
+		{Lo: 0x2e80, Hi: 0x303e, Stride: 1},
+		{Lo: 0x3041, Hi: 0x33ff, Stride: 1},
+		{Lo: 0x3400, Hi: 0x4dbf, Stride: 1},
+		{Lo: 0x4e00, Hi: 0x9fff, Stride: 1},
+		{Lo: 0xa000, Hi: 0xa4cf, Stride: 1},
+		{Lo: 0xac00, Hi: 0xd7a3, Stride: 1},
+		{Lo: 0xf900, Hi: 0xfaff, Stride: 1},
+		{Lo: 0xfe30, Hi: 0xfe4f, Stride: 1},
+		{Lo: 0xff00, Hi: 0xff60, Stride: 1},
+		{Lo: 0xffe0, Hi: 0xffe6, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f300, Hi: 0x1f64f, Stride: 1},
+		{Lo: 0x1f900, Hi: 0x1f9ff, Stride: 1},
+		{Lo: 0x20000, Hi: 0x2fffd, Stride: 1},
+		{Lo: 0x30000, Hi: 0x3fffd, Stride: 1},
+	},
+}
@@ -0,0 +1,120 @@
+package output
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonFormatter prints the rows as an array of objects keyed by column name. Integers and reals are numbers,
+// text is a string, a blob a string of its base64 encoding and NULL is null.
+type jsonFormatter struct {
+	w       io.Writer
+	columns []string
+	started bool
+}
+
+func (f *jsonFormatter) Row(values []cell.Value) error {
+	var b strings.Builder
+	if f.started {
+		b.WriteString(",\n{")
+	} else {
+		b.WriteString("[{")
+	}
+	f.started = true
+
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		writeJSONString(&b, f.columns[i])
+		b.WriteByte(':')
+		writeJSONValue(&b, v)
+	}
+	b.WriteByte('}')
+	_, err := io.WriteString(f.w, b.String())
+	return err
+}
+
+func (f *jsonFormatter) Flush() error {
+	if !f.started {
+		return nil
+	}
+	_, err := io.WriteString(f.w, "]\n")
+	return err
+}
+
+func writeJSONValue(b *strings.Builder, v cell.Value) {
+	switch v.Type {
+	case cell.ValueTypeNull:
+		b.WriteString("null")
+	case cell.ValueTypeInteger:
+		b.WriteString(strconv.FormatInt(v.Integer, 10))
+	case cell.ValueTypeReal:
+		if math.IsNaN(v.Real) {
+			b.WriteString("null")
+		} else {
+			b.WriteString(formatExactReal(v.Real))
+		}
+	case cell.ValueTypeText:
+		writeJSONString(b, string(v.Bytes))
+	default:
+		writeJSONString(b, base64.StdEncoding.EncodeToString(v.Bytes))
+	}
+}
+
+// writeJSONString writes s as a JSON string, invalid UTF-8 being replaced by U+FFFD
+func writeJSONString(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else if r == utf8.RuneError {
+				b.WriteString("�")
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+// formatExactReal formats f with the fewest digits reading back as f, always keeping a decimal point or an
+// exponent so that it reads back as a real. Infinity, which neither JSON nor SQL can spell, is written as a
+// number too large for a double, as SQLite does.
+func formatExactReal(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "9.0e+999"
+	case math.IsInf(f, -1):
+		return "-9.0e+999"
+	case math.IsNaN(f):
+		return cell.FormatReal(f)
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,294 @@
+package output
+
+import (
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/rqlite/sql"
+)
+
+// Mode is how query results are printed, named after the modes of the sqlite3 shell
+type Mode int
+
+const (
+	ModeList Mode = iota
+	ModeCSV
+	ModeTabs
+	ModeQuote
+	ModeJSON
+	ModeLine
+	ModeColumn
+	ModeMarkdown
+	ModeTable
+	ModeBox
+	ModeInsert
+)
+
+var modeNames = []string{"list", "csv", "tabs", "quote", "json", "line", "column", "markdown", "table", "box", "insert"}
+
+func (m Mode) String() string {
+	if int(m) < len(modeNames) {
+		return modeNames[m]
+	}
+	return fmt.Sprintf("mode(%d)", int(m))
+}
+
+// ParseMode returns the mode named name, "columns" being read as "column"
+func ParseMode(name string) (Mode, error) {
+	name = strings.ToLower(name)
+	if name == "columns" {
+		name = "column"
+	}
+	for i, n := range modeNames {
+		if n == name {
+			return Mode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown mode: %s, expected one of %s", name, strings.Join(modeNames, " "))
+}
+
+// Options tune how results are printed
+type Options struct {
+	Mode Mode
+	// Headers prints the column names before the rows. Markdown, table and box always print them, JSON and line
+	// print the names along every value.
+	Headers bool
+	// NullValue is printed for NULL, except in the modes printing SQL literals and JSON
+	NullValue string
+	// ColumnSeparator and RowSeparator separate the values of list, csv and tabs, quote using commas
+	ColumnSeparator string
+	RowSeparator    string
+	// Table is the table the statements of insert insert into
+	Table string
+	// headersSet tells whether Headers was switched explicitly, which column leaves as it is
+	headersSet bool
+}
+
+// NewOptions returns the options of mode list with the separators of the sqlite3 shell
+func NewOptions() *Options {
+	opts := &Options{}
+	opts.SetMode(ModeList)
+	return opts
+}
+
+// SetHeaders switches the column names on or off
+func (o *Options) SetHeaders(on bool) {
+	o.Headers, o.headersSet = on, true
+}
+
+// SetMode switches to mode, setting the separators it implies. Column turns Headers on unless they were
+// switched explicitly with SetHeaders.
+func (o *Options) SetMode(mode Mode) {
+	o.Mode = mode
+	switch mode {
+	case ModeCSV:
+		o.ColumnSeparator, o.RowSeparator = ",", "\r\n"
+	case ModeTabs:
+		o.ColumnSeparator, o.RowSeparator = "\t", "\n"
+	case ModeList:
+		o.ColumnSeparator, o.RowSeparator = "|", "\n"
+	case ModeColumn:
+		if !o.headersSet {
+			o.Headers = true
+		}
+	}
+	if o.Table == "" {
+		o.Table = "table"
+	}
+}
+
+// Formatter prints the rows of a result as they are read. Modes aligning columns hold the rows until Flush.
+type Formatter interface {
+	Row(values []cell.Value) error
+	// Flush prints what is held back and ends the result, e.g. closing a JSON array, once every row is read
+	Flush() error
+}
+
+// New returns the formatter of opts.Mode printing to w the rows of a result with columns. Nothing is printed for
+// a result without rows, not even the column names.
+func New(w io.Writer, opts *Options, columns []string) Formatter {
+	switch opts.Mode {
+	case ModeCSV:
+		return &separated{w: w, opts: opts, columns: columns, format: csvField}
+	case ModeQuote:
+		quoteOpts := *opts
+		quoteOpts.ColumnSeparator, quoteOpts.RowSeparator = ",", "\n"
+		return &separated{w: w, opts: &quoteOpts, columns: columns, format: quoteField}
+	case ModeJSON:
+		return &jsonFormatter{w: w, columns: columns}
+	case ModeLine:
+		return newLineFormatter(w, opts, columns)
+	case ModeColumn, ModeMarkdown, ModeTable, ModeBox:
+		return &columnar{w: w, opts: opts, columns: columns}
+	case ModeInsert:
+		return &insertFormatter{w: w, opts: opts, columns: columns}
+	default:
+		return &separated{w: w, opts: opts, columns: columns, format: listField}
+	}
+}
+
+// field renders a value, or a column name when header is set
+type field func(v cell.Value, header bool, opts *Options) string
+
+// separated prints the values of every row separated by the column separator, for list, csv, tabs and quote
+type separated struct {
+	w       io.Writer
+	opts    *Options
+	columns []string
+	format  field
+	started bool
+}
+
+func (f *separated) Row(values []cell.Value) error {
+	if !f.started && f.opts.Headers {
+		names := make([]cell.Value, len(f.columns))
+		for i, c := range f.columns {
+			names[i] = cell.TextValue(c)
+		}
+		if err := f.print(names, true); err != nil {
+			return err
+		}
+	}
+	f.started = true
+	return f.print(values, false)
+}
+
+func (f *separated) print(values []cell.Value, header bool) error {
+	fields := make([]string, len(values))
+	for i, v := range values {
+		fields[i] = f.format(v, header, f.opts)
+	}
+	_, err := io.WriteString(f.w, strings.Join(fields, f.opts.ColumnSeparator)+f.opts.RowSeparator)
+	return err
+}
+
+func (f *separated) Flush() error {
+	return nil
+}
+
+func listField(v cell.Value, _ bool, opts *Options) string {
+	if v.IsNull() {
+		return opts.NullValue
+	}
+	return v.String()
+}
+
+func quoteField(v cell.Value, header bool, _ *Options) string {
+	if header {
+		return v.Quote()
+	}
+	return literal(v)
+}
+
+// csvField quotes text holding a separator, a quote, a space or a character that is not printable ASCII,
+// and empty text, as the sqlite3 shell does. Numbers are never quoted.
+func csvField(v cell.Value, _ bool, opts *Options) string {
+	switch v.Type {
+	case cell.ValueTypeNull:
+		return opts.NullValue
+	case cell.ValueTypeInteger, cell.ValueTypeReal:
+		return v.String()
+	}
+
+	s := string(v.Bytes)
+	quote := s == "" || strings.Contains(s, opts.ColumnSeparator)
+	for i := 0; i < len(s) && !quote; i++ {
+		c := s[i]
+		quote = c <= ' ' || c == '"' || c == '\'' || c >= 0x7f
+	}
+	if !quote {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// insertFormatter prints every row as an INSERT statement, the column names included when Headers is set
+type insertFormatter struct {
+	w       io.Writer
+	opts    *Options
+	columns []string
+}
+
+func (f *insertFormatter) Row(values []cell.Value) error {
+	var b strings.Builder
+	b.WriteString("INSERT INTO ")
+	b.WriteString(QuoteIdent(f.opts.Table))
+	if f.opts.Headers {
+		names := make([]string, len(f.columns))
+		for i, c := range f.columns {
+			names[i] = QuoteIdent(c)
+		}
+		b.WriteString("(" + strings.Join(names, ",") + ")")
+	}
+	b.WriteString(" VALUES(")
+	for i, v := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(literal(v))
+	}
+	b.WriteString(");\n")
+	_, err := io.WriteString(f.w, b.String())
+	return err
+}
+
+func (f *insertFormatter) Flush() error {
+	return nil
+}
+
+// literal renders v as an SQL literal reading back as the same value, reals included
+func literal(v cell.Value) string {
+	if v.Type == cell.ValueTypeReal {
+		return formatExactReal(v.Real)
+	}
+	return v.Quote()
+}
+
+// lineFormatter prints every value on its own line after the name of its column, a blank line separating rows
+type lineFormatter struct {
+	w       io.Writer
+	opts    *Options
+	columns []string
+	width   int
+	started bool
+}
+
+func newLineFormatter(w io.Writer, opts *Options, columns []string) *lineFormatter {
+	// the names are right-aligned to at least five characters, as in sqlite3
+	f := &lineFormatter{w: w, opts: opts, columns: columns, width: 5}
+	for _, c := range columns {
+		f.width = max(f.width, displayWidth(c))
+	}
+	return f
+}
+
+func (f *lineFormatter) Row(values []cell.Value) error {
+	var b strings.Builder
+	if f.started {
+		b.WriteString("\n")
+	}
+	f.started = true
+	for i, v := range values {
+		b.WriteString(strings.Repeat(" ", f.width-displayWidth(f.columns[i])))
+		b.WriteString(f.columns[i] + " = " + listField(v, false, f.opts) + "\n")
+	}
+	_, err := io.WriteString(f.w, b.String())
+	return err
+}
+
+func (f *lineFormatter) Flush() error {
+	return nil
+}
+
+var bareIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// QuoteIdent double-quotes name unless it is an identifier that is not a keyword
+func QuoteIdent(name string) string {
+	if bareIdent.MatchString(name) && sql.Lookup(name) == sql.IDENT {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
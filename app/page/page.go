@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
 	"github/com/codecrafters-io/sqlite-starter-go/app/header"
+	"github/com/codecrafters-io/sqlite-starter-go/app/pager"
 	"github/com/codecrafters-io/sqlite-starter-go/app/schema"
-	"os"
 )
 
 type FirstPage struct {
@@ -15,33 +15,39 @@ type FirstPage struct {
 	SQLiteMasterRows schema.SQLiteMasterRows
 }
 
+// LeafPage, InteriorTable, InteriorIndex and LeafIndex hold the usable part of a page, its reserved bytes excluded
 type LeafPage struct {
-	Offset uint
+	Data []byte
 	*header.BTreeHeader
 }
 
 type InteriorTable struct {
-	Offset uint
+	Data []byte
 	*header.BTreeHeader
 }
 
 type InteriorIndex struct {
-	Offset uint
+	Data []byte
 	*header.BTreeHeader
 }
 
 type LeafIndex struct {
-	Offset uint
+	Data []byte
 	*header.BTreeHeader
 }
 
-func NewDBFirstPage(f *os.File) (*FirstPage, error) {
-	fh, read, err := header.NewFileHeader(f)
+func NewDBFirstPage(p *pager.Pager) (*FirstPage, error) {
+	data, err := p.Page(1)
 	if err != nil {
 		return nil, err
 	}
 
-	bh, read, err := header.NewBTreeHeader(f, read)
+	fh, read, err := header.NewFileHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	bh, _, err := header.NewBTreeHeader(data, read)
 	if err != nil {
 		return nil, err
 	}
@@ -51,11 +57,13 @@ func NewDBFirstPage(f *os.File) (*FirstPage, error) {
 		return nil, err
 	}
 
-	cells, err := cell.NewLeafTablePageCells(f, &cell.NewLeafTablePageCellRequest{
+	cells, err := cell.NewLeafTablePageCells(p, &cell.NewLeafTablePageCellRequest{
 		PageType:           bh.PageType,
-		PageOffset:         0,
+		Page:               data[:p.UsableSize()],
 		HeaderOffset:       uint64(header.FileHeaderSize + bhSize),
 		CellCount:          uint64(bh.CellCount),
+		UsableSize:         uint64(p.UsableSize()),
+		TextEncoding:       fh.TextEncoding,
 		ColumnPosList:      nil,
 		AutoIncrKeyPosList: nil,
 		Where:              nil,
@@ -76,27 +84,28 @@ func NewDBFirstPage(f *os.File) (*FirstPage, error) {
 	}, nil
 }
 
-func GetPageType(f *os.File, pageSize, pageNum uint) (header.PageType, error) {
+func GetPageType(p *pager.Pager, pageNum uint) (header.PageType, error) {
 	if pageNum <= 0 {
 		return 0, fmt.Errorf("invalid pageNum: %d, should be greater than 1", pageNum)
 	}
 
-	if pageNum == 1 {
-		bh, _, err := header.NewBTreeHeader(f, header.FileHeaderSize)
-		if err != nil {
-			return 0, err
-		}
-		return bh.PageType, nil
+	data, err := p.Page(pageNum)
+	if err != nil {
+		return 0, err
 	}
 
-	bh, _, err := header.NewBTreeHeader(f, (pageNum-1)*pageSize)
+	offset := uint(0)
+	if pageNum == 1 {
+		offset = header.FileHeaderSize
+	}
+	bh, _, err := header.NewBTreeHeader(data, offset)
 	if err != nil {
 		return 0, err
 	}
 	return bh.PageType, nil
 }
 
-func NewLeafTablePage(f *os.File, pageSize, pageNum uint) (*LeafPage, error) {
+func NewLeafTablePage(p *pager.Pager, pageNum uint) (*LeafPage, error) {
 	if pageNum <= 0 {
 		return nil, fmt.Errorf("invalid pageNum: %d, should be greater than or equal to 1", pageNum)
 	}
@@ -105,18 +114,23 @@ func NewLeafTablePage(f *os.File, pageSize, pageNum uint) (*LeafPage, error) {
 		return nil, errors.New("call NewDBFirstPage when pageNum == 1")
 	}
 
-	bh, _, err := header.NewBTreeHeader(f, (pageNum-1)*pageSize)
+	data, err := p.Page(pageNum)
+	if err != nil {
+		return nil, err
+	}
+
+	bh, _, err := header.NewBTreeHeader(data, 0)
 	if err != nil {
 		return nil, err
 	}
 
 	return &LeafPage{
-		Offset:      (pageNum - 1) * pageSize,
+		Data:        data[:p.UsableSize()],
 		BTreeHeader: bh,
 	}, nil
 }
 
-func NewInteriorTable(f *os.File, pageSize, pageNum uint) (*InteriorTable, error) {
+func NewInteriorTable(p *pager.Pager, pageNum uint) (*InteriorTable, error) {
 	if pageNum <= 0 {
 		return nil, fmt.Errorf("invalid pageNum: %d, should be greater than or equal to 1", pageNum)
 	}
@@ -125,18 +139,23 @@ func NewInteriorTable(f *os.File, pageSize, pageNum uint) (*InteriorTable, error
 		return nil, errors.New("call NewDBFirstPage when pageNum == 1")
 	}
 
-	bh, _, err := header.NewBTreeHeader(f, (pageNum-1)*pageSize)
+	data, err := p.Page(pageNum)
+	if err != nil {
+		return nil, err
+	}
+
+	bh, _, err := header.NewBTreeHeader(data, 0)
 	if err != nil {
 		return nil, err
 	}
 
 	return &InteriorTable{
-		Offset:      (pageNum - 1) * pageSize,
+		Data:        data[:p.UsableSize()],
 		BTreeHeader: bh,
 	}, nil
 }
 
-func NewInteriorIndex(f *os.File, pageSize, pageNum uint) (*InteriorIndex, error) {
+func NewInteriorIndex(p *pager.Pager, pageNum uint) (*InteriorIndex, error) {
 	if pageNum <= 0 {
 		return nil, fmt.Errorf("invalid pageNum: %d, should be greater than or equal to 1", pageNum)
 	}
@@ -145,18 +164,23 @@ func NewInteriorIndex(f *os.File, pageSize, pageNum uint) (*InteriorIndex, error
 		return nil, errors.New("call NewDBFirstPage when pageNum == 1")
 	}
 
-	bh, _, err := header.NewBTreeHeader(f, (pageNum-1)*pageSize)
+	data, err := p.Page(pageNum)
+	if err != nil {
+		return nil, err
+	}
+
+	bh, _, err := header.NewBTreeHeader(data, 0)
 	if err != nil {
 		return nil, err
 	}
 
 	return &InteriorIndex{
-		Offset:      (pageNum - 1) * pageSize,
+		Data:        data[:p.UsableSize()],
 		BTreeHeader: bh,
 	}, nil
 }
 
-func NewLeafIndex(f *os.File, pageSize, pageNum uint) (*LeafIndex, error) {
+func NewLeafIndex(p *pager.Pager, pageNum uint) (*LeafIndex, error) {
 	if pageNum <= 0 {
 		return nil, fmt.Errorf("invalid pageNum: %d, should be greater than or equal to 1", pageNum)
 	}
@@ -165,13 +189,18 @@ func NewLeafIndex(f *os.File, pageSize, pageNum uint) (*LeafIndex, error) {
 		return nil, errors.New("call NewDBFirstPage when pageNum == 1")
 	}
 
-	bh, _, err := header.NewBTreeHeader(f, (pageNum-1)*pageSize)
+	data, err := p.Page(pageNum)
+	if err != nil {
+		return nil, err
+	}
+
+	bh, _, err := header.NewBTreeHeader(data, 0)
 	if err != nil {
 		return nil, err
 	}
 
 	return &LeafIndex{
-		Offset:      (pageNum - 1) * pageSize,
+		Data:        data[:p.UsableSize()],
 		BTreeHeader: bh,
 	}, nil
 }
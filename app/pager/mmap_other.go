@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package pager
+
+import (
+	"errors"
+	"os"
+)
+
+func mmap(f *os.File, size int64) ([]byte, error) {
+	return nil, errors.New("mmap is not supported on this platform")
+}
+
+func munmap(b []byte) error {
+	return nil
+}
@@ -0,0 +1,244 @@
+package pager
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/wal"
+	"io"
+	"os"
+	"sync"
+)
+
+// DefaultCacheSize is the number of pages a Pager keeps by default
+const DefaultCacheSize = 2000
+
+// Mode is how a Pager reads pages
+type Mode int
+
+const (
+	// ModeRead reads pages with pread into buffers kept in an LRU cache
+	ModeRead Mode = iota
+	// ModeMmap slices pages out of a read-only memory mapping of the database file
+	ModeMmap
+)
+
+func (m Mode) String() string {
+	if m == ModeMmap {
+		return "mmap"
+	}
+	return "pread"
+}
+
+// Pager reads whole pages of a database, keeping the most recently used ones in a bounded LRU cache.
+// The database may be a file or any other io.ReaderAt, e.g. a byte slice or an object storage mirror.
+// The page buffers it returns are shared and must not be modified.
+type Pager struct {
+	r        io.ReaderAt
+	size     int64
+	pageSize uint
+	// reserved is the number of bytes at the end of every page set aside for extensions, e.g. checksums
+	reserved uint
+	capacity int
+	// mapped is the memory mapping of the database in ModeMmap, pages being sliced out of it without copy
+	mapped []byte
+	// wal holds the committed pages newer than the ones of the database file, nil when there is no WAL
+	wal *wal.WAL
+
+	mu sync.Mutex
+	// pages holds the element of lru of every cached page, the front of lru being the most recently used
+	pages map[uint]*list.Element
+	lru   *list.List
+	stats Stats
+}
+
+type cachedPage struct {
+	num  uint
+	data []byte
+}
+
+// Stats counts the page reads served from the cache and from the file
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Cached is the number of pages in the cache
+	Cached int
+}
+
+// New returns a Pager reading pages of pageSize bytes from the size bytes of r, caching up to cacheSize of them
+func New(r io.ReaderAt, size int64, pageSize uint, cacheSize int) *Pager {
+	return &Pager{
+		r:        r,
+		size:     size,
+		pageSize: pageSize,
+		capacity: max(cacheSize, 1),
+		pages:    make(map[uint]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// NewMmap returns a Pager slicing pages of pageSize bytes out of a read-only memory mapping of the size bytes of f.
+// The pages, and the values decoded from them, must not be used after the Pager is closed.
+func NewMmap(f *os.File, size int64, pageSize uint) (*Pager, error) {
+	mapped, err := mmap(f, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map the database file: %w", err)
+	}
+	return &Pager{
+		size:     size,
+		pageSize: pageSize,
+		capacity: DefaultCacheSize,
+		pages:    make(map[uint]*list.Element),
+		lru:      list.New(),
+		mapped:   mapped,
+	}, nil
+}
+
+// Mode returns how the Pager reads pages
+func (p *Pager) Mode() Mode {
+	if p.mapped != nil {
+		return ModeMmap
+	}
+	return ModeRead
+}
+
+// Close releases the memory mapping of a Pager in ModeMmap. The underlying reader is left open.
+func (p *Pager) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.mapped == nil {
+		return nil
+	}
+	err := munmap(p.mapped)
+	p.mapped = nil
+	p.size = 0
+	return err
+}
+
+func (p *Pager) PageSize() uint {
+	return p.pageSize
+}
+
+// minUsableSize is the smallest usable size SQLite accepts
+const minUsableSize = 480
+
+// SetReservedBytes sets the number of bytes at the end of every page that b-tree content cannot use
+func (p *Pager) SetReservedBytes(n uint) error {
+	if p.pageSize < n+minUsableSize {
+		return fmt.Errorf("%d reserved bytes leave less than %d usable bytes in pages of %d bytes", n, minUsableSize, p.pageSize)
+	}
+	p.reserved = n
+	return nil
+}
+
+// UsableSize returns the number of bytes of a page b-tree content can use, the page size minus the reserved bytes
+func (p *Pager) UsableSize() uint {
+	return p.pageSize - p.reserved
+}
+
+// ReservedSpace returns the reserved bytes at the end of page pageNum, for extensions like checksum verification
+func (p *Pager) ReservedSpace(pageNum uint) ([]byte, error) {
+	data, err := p.Page(pageNum)
+	if err != nil {
+		return nil, err
+	}
+	return data[p.UsableSize():], nil
+}
+
+// SetWAL makes pages be read from the frames of w committed last, the database file holding the other ones
+func (p *Pager) SetWAL(w *wal.WAL) error {
+	if uint(w.PageSize) != p.pageSize {
+		return fmt.Errorf("write-ahead log page size %d differs from the database page size %d", w.PageSize, p.pageSize)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.wal = w
+	p.pages = make(map[uint]*list.Element)
+	p.lru.Init()
+	return nil
+}
+
+// PageCount returns the number of pages of the database, a trailing partial page not counting
+func (p *Pager) PageCount() uint {
+	if p.wal != nil && p.wal.DBSize() > 0 {
+		return p.wal.DBSize()
+	}
+	return uint(p.size / int64(p.pageSize))
+}
+
+// Page returns the content of page pageNum, pages being numbered from 1
+func (p *Pager) Page(pageNum uint) ([]byte, error) {
+	if pageNum == 0 {
+		return nil, errors.New("invalid page number: 0")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pageNum > p.PageCount() {
+		return nil, fmt.Errorf("page %d is out of the database of %d pages", pageNum, p.PageCount())
+	}
+
+	if e, ok := p.pages[pageNum]; ok {
+		p.stats.Hits++
+		p.lru.MoveToFront(e)
+		return e.Value.(*cachedPage).data, nil
+	}
+
+	data, cache, err := p.read(pageNum)
+	if err != nil || !cache {
+		return data, err
+	}
+	p.stats.Misses++
+
+	if p.lru.Len() >= p.capacity {
+		oldest := p.lru.Back()
+		p.lru.Remove(oldest)
+		delete(p.pages, oldest.Value.(*cachedPage).num)
+		p.stats.Evictions++
+	}
+	p.pages[pageNum] = p.lru.PushFront(&cachedPage{num: pageNum, data: data})
+	return data, nil
+}
+
+// read reads page pageNum from the WAL or the database file, telling whether the page is to be cached,
+// which a page sliced out of the memory mapping is not
+func (p *Pager) read(pageNum uint) ([]byte, bool, error) {
+	if p.wal != nil && p.wal.HasPage(pageNum) {
+		data := make([]byte, p.pageSize)
+		if err := p.wal.ReadPage(pageNum, data); err != nil {
+			return nil, false, err
+		}
+		return data, true, nil
+	}
+
+	offset := int64(pageNum-1) * int64(p.pageSize)
+	if p.mapped != nil {
+		end := offset + int64(p.pageSize)
+		if end > int64(len(p.mapped)) {
+			return nil, false, fmt.Errorf("page %d is out of the database file", pageNum)
+		}
+		return p.mapped[offset:end:end], false, nil
+	}
+
+	data := make([]byte, p.pageSize)
+	if _, err := p.r.ReadAt(data, offset); err != nil {
+		return nil, false, fmt.Errorf("failed to read page %d: %w", pageNum, err)
+	}
+	return data, true, nil
+}
+
+// Stats returns the cache statistics since the Pager was created. In ModeMmap only the pages read from the WAL
+// are cached.
+func (p *Pager) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.Cached = p.lru.Len()
+	return stats
+}
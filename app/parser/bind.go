@@ -0,0 +1,356 @@
+package parser
+
+import (
+	dbsql "database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rqlite/sql"
+)
+
+// Parameter is a bound parameter of a statement, numbered the way SQLite numbers them: ? takes the number
+// following the largest one so far, ?NNN takes NNN, and a named parameter takes a number at its first occurrence
+type Parameter struct {
+	Index int
+	// Name is the parameter as written, e.g. ":id", empty for ? and ?NNN
+	Name string
+}
+
+// Params are the parameters of a statement, whose occurrences are replaced by slots the bound values are set in,
+// so that the statement can be run again with other arguments
+type Params struct {
+	list  []*Parameter
+	slots []*paramSlot
+}
+
+// paramSlot is an occurrence of the parameter numbered index
+type paramSlot struct {
+	index int
+	expr  *sql.ParenExpr
+}
+
+// NewParams replaces the parameters of ss with slots binding NULL until Bind is called
+func NewParams(ss *sql.SelectStatement) (*Params, error) {
+	p := &Params{list: make([]*Parameter, 0)}
+	err := rewriteStatement(ss, func(b *sql.BindExpr) (sql.Expr, error) {
+		param, err := parameter(&p.list, b)
+		if err != nil {
+			return nil, err
+		}
+		// the parentheses keep an integer from being taken for a column number in ORDER BY and GROUP BY
+		slot := &paramSlot{index: param.Index, expr: &sql.ParenExpr{X: &sql.NullLit{}}}
+		p.slots = append(p.slots, slot)
+		return slot.expr, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// List returns the parameters ordered by number, the numbers skipped by ?NNN included without name
+func (p *Params) List() []*Parameter {
+	return p.list
+}
+
+// parameter returns the parameter b refers to, adding it to params at its first occurrence. params is indexed
+// by number minus one, the numbers skipped by ?NNN being filled with nameless parameters.
+func parameter(params *[]*Parameter, b *sql.BindExpr) (*Parameter, error) {
+	index := 0
+	switch {
+	case b.Name == "?":
+		index = len(*params) + 1
+	case strings.HasPrefix(b.Name, "?"):
+		n, err := strconv.Atoi(b.Name[1:])
+		if err != nil || n < 1 || n > math.MaxInt16 {
+			return nil, fmt.Errorf("variable number must be between ?1 and ?%d", math.MaxInt16)
+		}
+		index = n
+	default:
+		for _, p := range *params {
+			if p.Name == b.Name {
+				return p, nil
+			}
+		}
+		index = len(*params) + 1
+	}
+
+	for len(*params) < index {
+		*params = append(*params, &Parameter{Index: len(*params) + 1})
+	}
+	p := (*params)[index-1]
+	if b.Name != "?" && !strings.HasPrefix(b.Name, "?") {
+		p.Name = b.Name
+	}
+	return p, nil
+}
+
+// Bind sets the parameters to the values of args. An argument binds the parameter numbered after its position
+// in args, unless it is a database/sql NamedArg, which binds every parameter of its name whichever of :, @ or $
+// prefixes it, so that $x and @x are both bound by the argument named x. Every parameter must be bound exactly
+// once.
+func (p *Params) Bind(args []any) error {
+	values := make([]sql.Expr, len(p.list))
+	for i, arg := range args {
+		indexes, v := []int{i}, arg
+		if named, ok := arg.(dbsql.NamedArg); ok {
+			if indexes = namedParameters(p.list, named.Name); len(indexes) == 0 {
+				return fmt.Errorf("no such parameter: %s", named.Name)
+			}
+			v = named.Value
+		} else if i >= len(p.list) {
+			return fmt.Errorf("expected %d arguments, got %d", len(p.list), len(args))
+		}
+
+		for _, i := range indexes {
+			name := p.list[i].Name
+			if name == "" {
+				name = strconv.Itoa(i + 1)
+			}
+			if values[i] != nil {
+				return fmt.Errorf("parameter %s is bound more than once", name)
+			}
+			var err error
+			if values[i], err = literal(v); err != nil {
+				return fmt.Errorf("parameter %s: %w", name, err)
+			}
+		}
+	}
+	for i, v := range values {
+		if v != nil {
+			continue
+		}
+		if p.list[i].Name != "" {
+			return fmt.Errorf("missing argument for parameter %s", p.list[i].Name)
+		}
+		return fmt.Errorf("expected %d arguments, got %d", len(p.list), len(args))
+	}
+
+	for _, slot := range p.slots {
+		slot.expr.X = values[slot.index-1]
+	}
+	return nil
+}
+
+// namedParameters returns the indexes in params of the parameters named name, which may be written without
+// prefix to match any of them
+func namedParameters(params []*Parameter, name string) []int {
+	indexes := make([]int, 0, 1)
+	for i, p := range params {
+		if p.Name == "" {
+			continue
+		}
+		if p.Name == name || p.Name[1:] == name {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// literal converts a Go value to the literal expression of the SQLite value it binds
+func literal(v any) (sql.Expr, error) {
+	var err error
+	if valuer, ok := v.(driver.Valuer); ok {
+		if v, err = valuer.Value(); err != nil {
+			return nil, err
+		}
+	}
+
+	var lit sql.Expr
+	switch x := v.(type) {
+	case nil:
+		lit = &sql.NullLit{}
+	case int:
+		lit = integerLiteral(int64(x))
+	case int8:
+		lit = integerLiteral(int64(x))
+	case int16:
+		lit = integerLiteral(int64(x))
+	case int32:
+		lit = integerLiteral(int64(x))
+	case int64:
+		lit = integerLiteral(x)
+	case uint:
+		lit, err = uintLiteral(uint64(x))
+	case uint8:
+		lit = integerLiteral(int64(x))
+	case uint16:
+		lit = integerLiteral(int64(x))
+	case uint32:
+		lit = integerLiteral(int64(x))
+	case uint64:
+		lit, err = uintLiteral(x)
+	case float32:
+		lit = realLiteral(float64(x))
+	case float64:
+		lit = realLiteral(x)
+	case bool:
+		if x {
+			lit = integerLiteral(1)
+		} else {
+			lit = integerLiteral(0)
+		}
+	case string:
+		lit = &sql.StringLit{Value: x}
+	case []byte:
+		if x == nil {
+			lit = &sql.NullLit{}
+		} else {
+			lit = &sql.BlobLit{Value: hex.EncodeToString(x)}
+		}
+	case time.Time:
+		lit = &sql.StringLit{Value: x.Format("2006-01-02 15:04:05.999999999-07:00")}
+	default:
+		return nil, fmt.Errorf("unsupported type %T", v)
+	}
+	return lit, err
+}
+
+func integerLiteral(n int64) sql.Expr {
+	return &sql.NumberLit{Value: strconv.FormatInt(n, 10)}
+}
+
+func uintLiteral(n uint64) (sql.Expr, error) {
+	if n > math.MaxInt64 {
+		return nil, fmt.Errorf("uint64 values with the high bit set are not supported: %d", n)
+	}
+	return integerLiteral(int64(n)), nil
+}
+
+// realLiteral writes f so that it reads back as the same REAL, NaN binding NULL as in SQLite
+func realLiteral(f float64) sql.Expr {
+	switch {
+	case math.IsNaN(f):
+		return &sql.NullLit{}
+	case math.IsInf(f, 1):
+		return &sql.NumberLit{Value: "1e999"}
+	case math.IsInf(f, -1):
+		return &sql.NumberLit{Value: "-1e999"}
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return &sql.NumberLit{Value: s}
+}
+
+// rewriteStatement replaces the expressions of ss that bind evaluates to, in the order they are written
+func rewriteStatement(ss *sql.SelectStatement, bind func(*sql.BindExpr) (sql.Expr, error)) error {
+	var err error
+	for _, c := range ss.Columns {
+		if c.Expr, err = rewriteExpr(c.Expr, bind); err != nil {
+			return err
+		}
+	}
+	if err := rewriteSource(ss.Source, bind); err != nil {
+		return err
+	}
+	if ss.WhereExpr, err = rewriteExpr(ss.WhereExpr, bind); err != nil {
+		return err
+	}
+	for i, e := range ss.GroupByExprs {
+		if ss.GroupByExprs[i], err = rewriteExpr(e, bind); err != nil {
+			return err
+		}
+	}
+	if ss.HavingExpr, err = rewriteExpr(ss.HavingExpr, bind); err != nil {
+		return err
+	}
+	for _, t := range ss.OrderingTerms {
+		if t.X, err = rewriteExpr(t.X, bind); err != nil {
+			return err
+		}
+	}
+
+	if ss.LimitExpr, err = rewriteExpr(ss.LimitExpr, bind); err != nil {
+		return err
+	}
+	if ss.OffsetExpr, err = rewriteExpr(ss.OffsetExpr, bind); err != nil {
+		return err
+	}
+	return nil
+}
+
+func rewriteSource(source sql.Source, bind func(*sql.BindExpr) (sql.Expr, error)) error {
+	switch s := source.(type) {
+	case *sql.JoinClause:
+		if err := rewriteSource(s.X, bind); err != nil {
+			return err
+		}
+		if err := rewriteSource(s.Y, bind); err != nil {
+			return err
+		}
+		if on, ok := s.Constraint.(*sql.OnConstraint); ok {
+			var err error
+			if on.X, err = rewriteExpr(on.X, bind); err != nil {
+				return err
+			}
+		}
+	case *sql.ParenSource:
+		return rewriteSource(s.X, bind)
+	case *sql.SelectStatement:
+		return rewriteStatement(s, bind)
+	}
+	return nil
+}
+
+func rewriteExpr(expr sql.Expr, bind func(*sql.BindExpr) (sql.Expr, error)) (sql.Expr, error) {
+	var err error
+	switch e := expr.(type) {
+	case *sql.BindExpr:
+		return bind(e)
+	case *sql.ParenExpr:
+		e.X, err = rewriteExpr(e.X, bind)
+	case *sql.UnaryExpr:
+		e.X, err = rewriteExpr(e.X, bind)
+	case *sql.BinaryExpr:
+		if e.X, err = rewriteExpr(e.X, bind); err == nil {
+			e.Y, err = rewriteExpr(e.Y, bind)
+		}
+	case *sql.Range:
+		if e.X, err = rewriteExpr(e.X, bind); err == nil {
+			e.Y, err = rewriteExpr(e.Y, bind)
+		}
+	case *sql.CastExpr:
+		e.X, err = rewriteExpr(e.X, bind)
+	case *sql.CaseExpr:
+		if e.Operand, err = rewriteExpr(e.Operand, bind); err != nil {
+			return nil, err
+		}
+		for _, b := range e.Blocks {
+			if b.Condition, err = rewriteExpr(b.Condition, bind); err != nil {
+				return nil, err
+			}
+			if b.Body, err = rewriteExpr(b.Body, bind); err != nil {
+				return nil, err
+			}
+		}
+		e.ElseExpr, err = rewriteExpr(e.ElseExpr, bind)
+	case *sql.Call:
+		for i, arg := range e.Args {
+			if e.Args[i], err = rewriteExpr(arg, bind); err != nil {
+				return nil, err
+			}
+		}
+		if e.Filter != nil {
+			e.Filter.X, err = rewriteExpr(e.Filter.X, bind)
+		}
+	case *sql.ExprList:
+		for i, x := range e.Exprs {
+			if e.Exprs[i], err = rewriteExpr(x, bind); err != nil {
+				return nil, err
+			}
+		}
+	case *sql.Exists:
+		err = rewriteStatement(e.Select, bind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	dbsql "database/sql"
+	"slices"
+	"testing"
+
+	"github.com/rqlite/sql"
+)
+
+// newTestParams returns the parameters of the SELECT statement q along with the statement
+func newTestParams(t *testing.T, q string) (*Params, *sql.SelectStatement) {
+	t.Helper()
+	stmt, err := NewStatement(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err := NewSelectStatement(stmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewParams(ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, ss
+}
+
+func TestParamsList(t *testing.T) {
+	tests := []struct {
+		query string
+		// want holds the name of every parameter, "?" for a nameless one
+		want []string
+	}{
+		{query: "SELECT ?, ?", want: []string{"?", "?"}},
+		{query: "SELECT ?2, ?", want: []string{"?", "?", "?"}},
+		{query: "SELECT ?, ?1, ?", want: []string{"?", "?"}},
+		{query: "SELECT :a, ?, :a, @b", want: []string{":a", "?", "@b"}},
+		{query: "SELECT $x, ?3, @x", want: []string{"$x", "?", "?", "@x"}},
+	}
+	for _, tt := range tests {
+		p, _ := newTestParams(t, tt.query)
+		got := make([]string, len(p.List()))
+		for i, param := range p.List() {
+			if param.Index != i+1 {
+				t.Errorf("%s: parameter %d is numbered %d", tt.query, i+1, param.Index)
+			}
+			got[i] = param.Name
+			if got[i] == "" {
+				got[i] = "?"
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: parameters %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParamsBind(t *testing.T) {
+	tests := []struct {
+		query string
+		args  []any
+		want  string
+		err   string
+	}{
+		{query: "SELECT ?, ?", args: []any{1, "a"}, want: "SELECT (1), ('a')"},
+		{query: "SELECT ?2, ?1", args: []any{1, 2.5}, want: "SELECT (2.5), (1)"},
+		{query: "SELECT ?, ?1", args: []any{nil}, want: "SELECT (NULL), (NULL)"},
+		{query: "SELECT :a, :a", args: []any{dbsql.Named("a", 1)}, want: "SELECT (1), (1)"},
+		{query: "SELECT :a, :b", args: []any{dbsql.Named("b", 2), dbsql.Named(":a", 1)}, want: "SELECT (1), (2)"},
+		{query: "SELECT $x || @x", args: []any{dbsql.Named("x", "a")}, want: "SELECT ('a') || ('a')"},
+		{query: "SELECT $x || @x", args: []any{dbsql.Named("$x", "a")}, err: "missing argument for parameter @x"},
+		{query: "SELECT :a", args: []any{dbsql.Named("b", 1)}, err: "no such parameter: b"},
+		{query: "SELECT :a", args: []any{1, dbsql.Named("a", 1)}, err: "parameter :a is bound more than once"},
+		{query: "SELECT ?", args: []any{1, 2}, err: "expected 1 arguments, got 2"},
+		{query: "SELECT ?, ?", args: []any{1}, err: "expected 2 arguments, got 1"},
+		{query: "SELECT ?", args: []any{struct{}{}}, err: "parameter 1: unsupported type struct {}"},
+	}
+	for _, tt := range tests {
+		p, ss := newTestParams(t, tt.query)
+		err := p.Bind(tt.args)
+		switch {
+		case tt.err != "":
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: Bind(%v) = %v, want %s", tt.query, tt.args, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%s: Bind(%v) = %v", tt.query, tt.args, err)
+		case ss.String() != tt.want:
+			t.Errorf("%s: bound as %s, want %s", tt.query, ss.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/rqlite/sql"
+)
+
+// The rqlite/sql parser reads the right hand side of IS NOT, NOT LIKE, NOT GLOB, NOT REGEXP, NOT MATCH and
+// [NOT] BETWEEN at the lowest precedence, so `a IS NOT NULL AND b = 1` is parsed as `a IS NOT (NULL AND b = 1)`,
+// and it makes a unary NOT bind tighter than comparisons. normalize parenthesizes those expressions so that
+// the parser reads them the way SQLite does. It also quotes ROWID, which the parser only takes as a keyword, and
+// the name of replace(), which it only takes as the keyword starting a REPLACE statement.
+
+type token struct {
+	// offset is the position of the token in runes
+	offset int
+	tok    sql.Token
+	lit    string
+}
+
+type operatorKind int
+
+const (
+	operatorBinary operatorKind = iota
+	// operatorMisparsed is a binary operator whose right hand side the parser does not bound
+	operatorMisparsed
+	operatorBetween
+	operatorIn
+	operatorPostfix
+)
+
+type operator struct {
+	prec int
+	size int
+	kind operatorKind
+}
+
+type normalizer struct {
+	tokens []token
+	// wraps holds the token ranges [start, end) to be parenthesized
+	wraps map[[2]int]struct{}
+}
+
+func normalize(q string) string {
+	n := &normalizer{tokens: tokenize(q), wraps: make(map[[2]int]struct{})}
+	for i := 0; i < len(n.tokens)-1; i++ {
+		if isExpressionIntroducer(n.tokens[i].tok) {
+			n.skipExpr(i+1, sql.LowestPrec+1)
+		}
+	}
+
+	src := []rune(q)
+	// at a given offset closing quotes and parentheses go before opening ones
+	closes := make(map[int]string)
+	opens := make(map[int]string)
+	for w := range n.wraps {
+		opens[n.tokens[w[0]].offset] += "("
+		end := n.tokens[w[1]].offset
+		for end > 0 && unicode.IsSpace(src[end-1]) {
+			end--
+		}
+		closes[end] += ")"
+	}
+	for i, t := range n.tokens {
+		if t.tok == sql.ROWID || t.tok == sql.REPLACE && n.tok(i+1) == sql.LP {
+			opens[t.offset] += `"`
+			closes[t.offset+len([]rune(t.lit))] = `"` + closes[t.offset+len([]rune(t.lit))]
+		}
+	}
+	if len(opens) == 0 {
+		return q
+	}
+
+	offsets := make([]int, 0, len(opens)+len(closes))
+	for o := range opens {
+		offsets = append(offsets, o)
+	}
+	for o := range closes {
+		if _, ok := opens[o]; !ok {
+			offsets = append(offsets, o)
+		}
+	}
+	sort.Ints(offsets)
+
+	var b strings.Builder
+	prev := 0
+	for _, o := range offsets {
+		b.WriteString(string(src[prev:o]))
+		b.WriteString(closes[o])
+		b.WriteString(opens[o])
+		prev = o
+	}
+	b.WriteString(string(src[prev:]))
+	return b.String()
+}
+
+// tokenize scans q without comments, ending with an EOF token
+func tokenize(q string) []token {
+	s := sql.NewScanner(strings.NewReader(q))
+	tokens := make([]token, 0)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == sql.COMMENT {
+			continue
+		}
+		if tok == sql.EOF {
+			tokens = append(tokens, token{offset: len([]rune(q)), tok: tok})
+			return tokens
+		}
+		tokens = append(tokens, token{offset: pos.Offset, tok: tok, lit: lit})
+	}
+}
+
+// isExpressionIntroducer reports whether an expression can follow tok
+func isExpressionIntroducer(tok sql.Token) bool {
+	switch tok {
+	case sql.SELECT, sql.DISTINCT, sql.ALL, sql.COMMA, sql.WHERE, sql.ON, sql.HAVING, sql.BY,
+		sql.WHEN, sql.THEN, sql.ELSE, sql.LP, sql.LIMIT, sql.OFFSET:
+		return true
+	default:
+		return false
+	}
+}
+
+func (n *normalizer) tok(i int) sql.Token {
+	if i >= len(n.tokens) {
+		return sql.EOF
+	}
+	return n.tokens[i].tok
+}
+
+// closing returns the index of the token closing the group opened at i
+func (n *normalizer) closing(i int, open, close sql.Token) int {
+	depth := 0
+	for ; i < len(n.tokens)-1; i++ {
+		switch n.tokens[i].tok {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(n.tokens) - 1
+}
+
+// skipOperand returns the index of the token following the operand starting at i
+func (n *normalizer) skipOperand(i int) int {
+	switch n.tok(i) {
+	case sql.EOF:
+		return i
+	case sql.IDENT, sql.QIDENT, sql.ROWID:
+		j := i + 1
+		for n.tok(j) == sql.DOT {
+			j += 2
+		}
+		if n.tok(j) == sql.LP {
+			return n.closing(j, sql.LP, sql.RP) + 1
+		}
+		return j
+	case sql.PLUS, sql.MINUS, sql.BITNOT:
+		return n.skipOperand(i + 1)
+	case sql.NOT:
+		if n.tok(i+1) == sql.EXISTS {
+			return n.skipOperand(i + 1)
+		}
+		end := n.skipExpr(i+1, sql.NOT.Precedence()+1)
+		if end != n.skipOperand(i+1) {
+			n.wraps[[2]int{i + 1, end}] = struct{}{}
+		}
+		return end
+	case sql.LP:
+		return n.closing(i, sql.LP, sql.RP) + 1
+	case sql.CASE:
+		return n.closing(i, sql.CASE, sql.END) + 1
+	default:
+		// CAST(...), EXISTS(...) and functions named by keywords
+		if n.tok(i+1) == sql.LP {
+			return n.closing(i+1, sql.LP, sql.RP) + 1
+		}
+		return i + 1
+	}
+}
+
+// operatorAt returns the binary or postfix operator at i, whose precedence is zero if there is none
+func (n *normalizer) operatorAt(i int) operator {
+	const comparisonPrec = 4
+
+	switch n.tok(i) {
+	case sql.IS:
+		if n.tok(i+1) == sql.NOT {
+			return operator{prec: comparisonPrec, size: 2, kind: operatorMisparsed}
+		}
+		return operator{prec: comparisonPrec, size: 1}
+	case sql.NOT:
+		switch n.tok(i + 1) {
+		case sql.LIKE, sql.GLOB, sql.REGEXP, sql.MATCH:
+			return operator{prec: comparisonPrec, size: 2, kind: operatorMisparsed}
+		case sql.BETWEEN:
+			return operator{prec: comparisonPrec, size: 2, kind: operatorBetween}
+		case sql.IN:
+			return operator{prec: comparisonPrec, size: 2, kind: operatorIn}
+		case sql.NULL:
+			return operator{prec: comparisonPrec, size: 2, kind: operatorPostfix}
+		default:
+			return operator{}
+		}
+	case sql.BETWEEN:
+		return operator{prec: comparisonPrec, size: 1, kind: operatorBetween}
+	case sql.IN:
+		return operator{prec: comparisonPrec, size: 1, kind: operatorIn}
+	case sql.ISNULL, sql.NOTNULL:
+		return operator{prec: comparisonPrec, size: 1, kind: operatorPostfix}
+	case sql.COLLATE:
+		return operator{prec: sql.HighestPrec, size: 2, kind: operatorPostfix}
+	case sql.SEMI, sql.LP, sql.RP, sql.COMMA, sql.DOT:
+		return operator{}
+	default:
+		return operator{prec: n.tok(i).Precedence(), size: 1}
+	}
+}
+
+// skipExpr returns the index of the token following the expression starting at i, made of operators binding
+// at least as tightly as minPrec, and records the subexpressions the parser needs parenthesized
+func (n *normalizer) skipExpr(i, minPrec int) int {
+	j := n.skipOperand(i)
+	for {
+		op := n.operatorAt(j)
+		if op.prec == sql.LowestPrec || op.prec < minPrec {
+			return j
+		}
+
+		k := j + op.size
+		switch op.kind {
+		case operatorPostfix:
+			j = k
+			continue
+		case operatorIn:
+			if n.tok(k) == sql.LP {
+				j = n.closing(k, sql.LP, sql.RP) + 1
+			} else {
+				j = n.skipOperand(k)
+			}
+			continue
+		case operatorBetween:
+			k = n.skipExpr(k, op.prec+1)
+			if n.tok(k) == sql.AND {
+				k = n.skipExpr(k+1, op.prec+1)
+			}
+			j = k
+		default:
+			j = n.skipExpr(k, op.prec+1)
+		}
+
+		if op.kind != operatorBinary && n.operatorAt(j).prec != sql.LowestPrec {
+			n.wraps[[2]int{i, j}] = struct{}{}
+		}
+	}
+}
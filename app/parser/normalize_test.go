@@ -0,0 +1,37 @@
+package parser
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "SELECT a FROM t WHERE a = 1", want: "SELECT a FROM t WHERE a = 1"},
+		{
+			query: "SELECT a FROM t WHERE a IS NOT NULL AND b = 1",
+			want:  "SELECT a FROM t WHERE (a IS NOT NULL) AND b = 1",
+		},
+		{query: "SELECT a FROM t WHERE NOT a = b", want: "SELECT a FROM t WHERE NOT (a = b)"},
+		{query: "SELECT a FROM t WHERE NOT a", want: "SELECT a FROM t WHERE NOT a"},
+		{
+			query: "SELECT a FROM t WHERE a BETWEEN 1 AND 2 AND b = 1",
+			want:  "SELECT a FROM t WHERE (a BETWEEN 1 AND 2) AND b = 1",
+		},
+		{
+			query: "SELECT a FROM t WHERE a NOT LIKE 'x!%' ESCAPE '!' AND b = 1",
+			want:  "SELECT a FROM t WHERE (a NOT LIKE 'x!%' ESCAPE '!') AND b = 1",
+		},
+		{
+			query: "SELECT a FROM t WHERE a = 'IS NOT NULL AND NOT a = b BETWEEN 1 AND 2'",
+			want:  "SELECT a FROM t WHERE a = 'IS NOT NULL AND NOT a = b BETWEEN 1 AND 2'",
+		},
+		{query: "SELECT rowid FROM t", want: `SELECT "rowid" FROM t`},
+		{query: "SELECT replace(a, 'x', 'y') FROM t", want: `SELECT "replace"(a, 'x', 'y') FROM t`},
+	}
+	for _, tt := range tests {
+		if got := normalize(tt.query); got != tt.want {
+			t.Errorf("normalize(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
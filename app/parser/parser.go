@@ -9,7 +9,7 @@ import (
 )
 
 func NewStatement(q string) (sql.Statement, error) {
-	return sql.NewParser(strings.NewReader(q)).ParseStatement()
+	return sql.NewParser(strings.NewReader(normalize(q))).ParseStatement()
 }
 
 func NewSelectStatement(stmt sql.Statement) (*sql.SelectStatement, error) {
@@ -35,25 +35,199 @@ func IsCountStatement(q string, stmt sql.Statement) (bool, error) {
 	return len(ss.Columns) == 1 && r.MatchString(q), nil
 }
 
-// WhereClause only considers WHERE key = val so far
-type WhereClause struct {
-	Key   string
-	Value string
+// EqualityTerm is a `column = constant` term of a WHERE expression, which rows can be looked up by through an index
+type EqualityTerm struct {
+	// Table is the qualifier of the column, empty when the reference is unqualified
+	Table  string
+	Column string
+	Value  sql.Expr
 }
 
-func NewWhereClause(expr sql.Expr) (*WhereClause, error) {
+// NewEqualityTerms returns the `column = constant` terms ANDed together at the top level of expr
+func NewEqualityTerms(expr sql.Expr) []*EqualityTerm {
+	terms := make([]*EqualityTerm, 0)
+	for _, e := range SplitConjunction(expr) {
+		be, ok := e.(*sql.BinaryExpr)
+		if !ok || be.Op != sql.EQ {
+			continue
+		}
+		if term := newEqualityTerm(be.X, be.Y); term != nil {
+			terms = append(terms, term)
+		} else if term := newEqualityTerm(be.Y, be.X); term != nil {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+func newEqualityTerm(column, value sql.Expr) *EqualityTerm {
+	if !IsConstant(value) {
+		return nil
+	}
+
+	switch c := column.(type) {
+	case *sql.Ident:
+		return &EqualityTerm{Column: c.Name, Value: value}
+	case *sql.QualifiedRef:
+		if c.Star.IsValid() {
+			return nil
+		}
+		return &EqualityTerm{Table: c.Table.Name, Column: c.Column.Name, Value: value}
+	default:
+		return nil
+	}
+}
+
+// SplitConjunction returns the operands of the top level ANDs of expr
+func SplitConjunction(expr sql.Expr) []sql.Expr {
+	switch e := expr.(type) {
+	case nil:
+		return nil
+	case *sql.ParenExpr:
+		return SplitConjunction(e.X)
+	case *sql.BinaryExpr:
+		if e.Op == sql.AND {
+			return append(SplitConjunction(e.X), SplitConjunction(e.Y)...)
+		}
+	}
+	return []sql.Expr{expr}
+}
+
+// IsConstant reports whether expr evaluates to the same value for every row
+func IsConstant(expr sql.Expr) bool {
+	switch e := expr.(type) {
+	case *sql.NullLit, *sql.NumberLit, *sql.StringLit, *sql.BlobLit, *sql.BoolLit:
+		return true
+	case *sql.ParenExpr:
+		return IsConstant(e.X)
+	case *sql.UnaryExpr:
+		return IsConstant(e.X)
+	case *sql.BinaryExpr:
+		return IsConstant(e.X) && IsConstant(e.Y)
+	case *sql.CastExpr:
+		return IsConstant(e.X)
+	default:
+		return false
+	}
+}
+
+// ColumnRef is a reference to a column in an expression
+type ColumnRef struct {
+	// Table is the qualifier of the column, empty when the reference is unqualified
+	Table  string
+	Column string
+	Quoted bool
+}
+
+// ColumnRefs returns the column references of expr, function names and type names left out
+func ColumnRefs(expr sql.Expr) ([]*ColumnRef, error) {
+	c := &columnRefCollector{refs: make([]*ColumnRef, 0)}
 	if expr == nil {
+		return c.refs, nil
+	}
+	if err := sql.Walk(c, expr); err != nil {
+		return nil, err
+	}
+	return c.refs, nil
+}
+
+type columnRefCollector struct {
+	refs []*ColumnRef
+}
+
+func (c *columnRefCollector) Visit(node sql.Node) (sql.Visitor, error) {
+	switch n := node.(type) {
+	case *sql.Ident:
+		c.refs = append(c.refs, &ColumnRef{Column: n.Name, Quoted: n.Quoted})
+	case *sql.QualifiedRef:
+		if !n.Star.IsValid() {
+			c.refs = append(c.refs, &ColumnRef{Table: n.Table.Name, Column: n.Column.Name, Quoted: n.Column.Quoted})
+		}
+		return nil, nil
+	case *sql.Call:
+		for _, arg := range n.Args {
+			if err := sql.Walk(c, arg); err != nil {
+				return nil, err
+			}
+		}
+		if n.Filter != nil {
+			if err := sql.Walk(c, n.Filter.X); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case *sql.Type:
 		return nil, nil
 	}
+	return c, nil
+}
+
+func (c *columnRefCollector) VisitEnd(sql.Node) error {
+	return nil
+}
 
-	whereClause := strings.SplitN(expr.String(), "=", 2)
+// ResultColumnTexts returns the expressions of the result columns of ss as written in q, which SQLite names
+// result columns without an alias after. It returns nil when the result columns cannot be told apart in q.
+func ResultColumnTexts(q string, ss *sql.SelectStatement) []string {
+	tokens := tokenize(q)
+	src := []rune(q)
 
-	if len(whereClause) != 2 {
-		return nil, fmt.Errorf("invalid WHERE clause expression: %s", expr.String())
+	start := 0
+	for start < len(tokens) && tokens[start].tok != sql.SELECT {
+		start++
+	}
+	start++
+	if start < len(tokens) && (tokens[start].tok == sql.DISTINCT || tokens[start].tok == sql.ALL) {
+		start++
 	}
 
-	return &WhereClause{
-		Key:   strings.Trim(whereClause[0], "\" "),
-		Value: strings.Trim(whereClause[1], "' "),
-	}, nil
+	// the result columns are the operands of the commas outside of parentheses up to the clause following them
+	bounds := [][2]int{}
+	depth := 0
+	i := start
+	for ; i < len(tokens); i++ {
+		tok := tokens[i].tok
+		if depth == 0 && (tok == sql.COMMA || endsResultColumns(tok)) {
+			bounds = append(bounds, [2]int{start, i})
+			start = i + 1
+			if tok != sql.COMMA {
+				break
+			}
+		}
+		switch tok {
+		case sql.LP:
+			depth++
+		case sql.RP:
+			depth--
+		}
+	}
+	if len(bounds) != len(ss.Columns) {
+		return nil
+	}
+
+	texts := make([]string, len(bounds))
+	for i, b := range bounds {
+		end := b[1]
+		if ss.Columns[i].Alias != nil {
+			end--
+			if end > b[0] && tokens[end-1].tok == sql.AS {
+				end--
+			}
+		}
+		if end <= b[0] {
+			return nil
+		}
+		texts[i] = strings.TrimSpace(string(src[tokens[b[0]].offset:tokens[end].offset]))
+	}
+	return texts
+}
+
+func endsResultColumns(tok sql.Token) bool {
+	switch tok {
+	case sql.FROM, sql.WHERE, sql.GROUP, sql.HAVING, sql.WINDOW, sql.ORDER, sql.LIMIT,
+		sql.UNION, sql.INTERSECT, sql.EXCEPT, sql.SEMI, sql.EOF:
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Pragma is a PRAGMA statement, which the rqlite/sql parser does not read
+type Pragma struct {
+	// Schema is the database the pragma applies to, empty when it is not given
+	Schema string
+	Name   string
+	// Arg is the argument of PRAGMA name(arg) or the value of PRAGMA name = value, unquoted
+	Arg string
+	// Assign tells whether the pragma is written as PRAGMA name = value, which sets the value
+	Assign bool
+}
+
+var (
+	pragmaPrefix = regexp.MustCompile(`(?i)^\s*PRAGMA\b`)
+	pragmaRegexp = regexp.MustCompile(
+		`(?is)^\s*PRAGMA\s+(?:(\w+|"[^"]*")\s*\.\s*)?(\w+)\s*(?:(=)\s*(.*?)|\(\s*(.*?)\s*\))?\s*;?\s*$`)
+)
+
+// IsPragma reports whether q is a PRAGMA statement
+func IsPragma(q string) bool {
+	return pragmaPrefix.MatchString(q)
+}
+
+// NewPragma parses the PRAGMA statement q
+func NewPragma(q string) (*Pragma, error) {
+	m := pragmaRegexp.FindStringSubmatch(q)
+	if m == nil {
+		return nil, fmt.Errorf("invalid PRAGMA statement: %s", q)
+	}
+
+	p := &Pragma{
+		Schema: unquote(m[1]),
+		Name:   strings.ToLower(m[2]),
+		Assign: m[3] != "",
+		Arg:    unquote(m[4] + m[5]),
+	}
+	if p.Assign && p.Arg == "" {
+		return nil, fmt.Errorf("invalid PRAGMA statement: %s", q)
+	}
+	return p, nil
+}
+
+// unquote strips the quotes of a string literal or a quoted identifier
+func unquote(s string) string {
+	if len(s) < 2 {
+		return s
+	}
+	switch q := s[0]; {
+	case (q == '\'' || q == '"' || q == '`') && s[len(s)-1] == q:
+		return strings.ReplaceAll(s[1:len(s)-1], string(q)+string(q), string(q))
+	case q == '[' && s[len(s)-1] == ']':
+		return s[1 : len(s)-1]
+	}
+	return s
+}
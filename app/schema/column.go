@@ -0,0 +1,508 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Column is a column definition of a CREATE TABLE statement.
+//
+// The rqlite/sql parser only recognizes upper case type names and reads "id integer" as two columns,
+// so column definitions are split out of the statement text here the way SQLite itself reads them.
+type Column struct {
+	Name string
+	// Type is the declared type as written, empty when the column has none
+	Type          string
+	PrimaryKey    bool
+	AutoIncrement bool
+	NotNull       bool
+	// Default is the text of the DEFAULT expression without enclosing parentheses, empty when there is none
+	Default string
+	// Collation is the name given by COLLATE, empty for the default BINARY collation
+	Collation string
+	// Generated is VIRTUAL or STORED for a generated column, empty for an ordinary one
+	Generated string
+	// KeyPos is the 1-based position of the column in the PRIMARY KEY, 0 when it is not part of it
+	KeyPos int
+}
+
+// IsRowIDAlias reports whether the column is an INTEGER PRIMARY KEY, whose value is stored as the row id
+func (c *Column) IsRowIDAlias() bool {
+	return c.PrimaryKey && strings.EqualFold(c.Type, "INTEGER")
+}
+
+// Table is the definition of a table read out of its CREATE TABLE statement
+type Table struct {
+	Columns []*Column
+	// PrimaryKey holds the columns of the PRIMARY KEY, declared on a column or as a table constraint
+	PrimaryKey []*IndexedColumn
+	// Keys are the PRIMARY KEY and UNIQUE constraints in the order they are declared
+	Keys []*KeyConstraint
+	// ForeignKeys are in the order they are declared
+	ForeignKeys  []*ForeignKey
+	WithoutRowID bool
+}
+
+// IndexedColumn is a column of a key or an index
+type IndexedColumn struct {
+	Name      string
+	Desc      bool
+	Collation string
+}
+
+// KeyConstraint is a PRIMARY KEY or UNIQUE constraint
+type KeyConstraint struct {
+	PrimaryKey bool
+	Columns    []*IndexedColumn
+}
+
+// ForeignKey is a REFERENCES clause of a column or a FOREIGN KEY table constraint
+type ForeignKey struct {
+	Table string
+	From  []string
+	// To is empty when the parent key is the primary key of Table
+	To []string
+	// OnUpdate and OnDelete are the actions as SQLite names them, e.g. "SET NULL", "NO ACTION" by default
+	OnUpdate string
+	OnDelete string
+}
+
+// AutoIndexKeys returns the keys SQLite creates an index for, the N-th one being sqlite_autoindex_<table>_N.
+// The primary key of a rowid table is not indexed when it is an INTEGER PRIMARY KEY, which is the row id itself.
+func (t *Table) AutoIndexKeys() []*KeyConstraint {
+	keys := make([]*KeyConstraint, 0, len(t.Keys))
+	for _, k := range t.Keys {
+		if k.PrimaryKey && !t.WithoutRowID && len(k.Columns) == 1 {
+			if c := t.Column(k.Columns[0].Name); c != nil && strings.EqualFold(c.Type, "INTEGER") {
+				continue
+			}
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Column returns the column named name, nil when there is none
+func (t *Table) Column(name string) *Column {
+	for _, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+var (
+	columnConstraintKeywords = map[string]bool{
+		"CONSTRAINT": true, "PRIMARY": true, "NOT": true, "NULL": true, "UNIQUE": true, "CHECK": true,
+		"DEFAULT": true, "COLLATE": true, "REFERENCES": true, "GENERATED": true, "AS": true,
+	}
+	tableConstraintKeywords = map[string]bool{
+		"CONSTRAINT": true, "PRIMARY": true, "UNIQUE": true, "CHECK": true, "FOREIGN": true,
+	}
+)
+
+// ParseColumns reads the column definitions of a CREATE TABLE statement
+func ParseColumns(q string) ([]*Column, error) {
+	t, err := ParseTable(q)
+	if err != nil {
+		return nil, err
+	}
+	return t.Columns, nil
+}
+
+// ParseTable reads the columns and constraints of a CREATE TABLE statement
+func ParseTable(q string) (*Table, error) {
+	tokens, err := tokenize(q)
+	if err != nil {
+		return nil, err
+	}
+
+	group := -1
+	for i, tok := range tokens {
+		if strings.HasPrefix(tok, "(") {
+			group = i
+			break
+		}
+	}
+	if group < 0 {
+		return nil, fmt.Errorf("no column definitions found in %q", q)
+	}
+
+	defs, err := splitList(tokens[group])
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Table{Columns: make([]*Column, 0, len(defs))}
+	for _, def := range defs {
+		if len(def) == 0 {
+			return nil, fmt.Errorf("empty column definition in %q", q)
+		}
+
+		if tableConstraintKeywords[strings.ToUpper(def[0])] {
+			if err := t.addTableConstraint(def); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		t.addColumn(def)
+	}
+
+	for _, option := range splitTokens(tokens[group+1:], ",") {
+		if len(option) == 2 && strings.EqualFold(option[0], "WITHOUT") && strings.EqualFold(option[1], "ROWID") {
+			t.WithoutRowID = true
+		}
+	}
+
+	for i, k := range t.PrimaryKey {
+		c := t.Column(k.Name)
+		if c == nil {
+			continue
+		}
+		c.KeyPos = i + 1
+		// PRIMARY KEY (a) as a table constraint declares the same key as the column constraint does
+		if len(t.PrimaryKey) == 1 {
+			c.PrimaryKey = true
+		}
+		// the primary key of a WITHOUT ROWID table cannot hold NULL
+		if t.WithoutRowID {
+			c.NotNull = true
+		}
+	}
+	return t, nil
+}
+
+// addColumn reads a column definition, its name, its declared type and its constraints
+func (t *Table) addColumn(def []string) {
+	c := &Column{Name: unquoteIdent(def[0])}
+	i := 1
+	typeTokens := make([]string, 0)
+	for ; i < len(def) && !columnConstraintKeywords[strings.ToUpper(def[i])]; i++ {
+		typeTokens = append(typeTokens, def[i])
+	}
+	c.Type = joinTypeTokens(typeTokens)
+
+	next := func() string {
+		if i+1 < len(def) {
+			i++
+			return def[i]
+		}
+		return ""
+	}
+	for ; i < len(def); i++ {
+		switch strings.ToUpper(def[i]) {
+		case "CONSTRAINT":
+			next()
+		case "PRIMARY":
+			c.PrimaryKey = true
+			k := &IndexedColumn{Name: c.Name}
+			if i+2 < len(def) && strings.EqualFold(def[i+2], "DESC") {
+				k.Desc = true
+			}
+			t.PrimaryKey = []*IndexedColumn{k}
+			t.Keys = append(t.Keys, &KeyConstraint{PrimaryKey: true, Columns: t.PrimaryKey})
+		case "AUTOINCREMENT":
+			c.AutoIncrement = true
+		case "NOT":
+			if i+1 < len(def) && strings.EqualFold(def[i+1], "NULL") {
+				c.NotNull = true
+				i++
+			}
+		case "UNIQUE":
+			t.Keys = append(t.Keys, &KeyConstraint{Columns: []*IndexedColumn{{Name: c.Name}}})
+		case "DEFAULT":
+			c.Default = unparenthesize(next())
+		case "COLLATE":
+			c.Collation = unquoteIdent(next())
+		case "REFERENCES":
+			fk, n := parseForeignKeyClause(def[i+1:])
+			fk.From = []string{c.Name}
+			t.ForeignKeys = append(t.ForeignKeys, fk)
+			i += n
+		case "AS":
+			// GENERATED ALWAYS AS (expr) [VIRTUAL | STORED]
+			next()
+			c.Generated = "VIRTUAL"
+			if i+1 < len(def) && strings.EqualFold(def[i+1], "STORED") {
+				c.Generated = "STORED"
+			}
+		}
+	}
+	t.Columns = append(t.Columns, c)
+}
+
+// addTableConstraint reads a PRIMARY KEY, UNIQUE or FOREIGN KEY table constraint, ignoring CHECK
+func (t *Table) addTableConstraint(def []string) error {
+	if strings.EqualFold(def[0], "CONSTRAINT") && len(def) > 2 {
+		def = def[2:]
+	}
+
+	i := 0
+	for ; i < len(def) && !strings.HasPrefix(def[i], "("); i++ {
+	}
+	if i == len(def) {
+		return nil
+	}
+
+	switch strings.ToUpper(def[0]) {
+	case "PRIMARY":
+		columns, err := parseIndexedColumns(def[i])
+		if err != nil {
+			return err
+		}
+		t.PrimaryKey = columns
+		t.Keys = append(t.Keys, &KeyConstraint{PrimaryKey: true, Columns: columns})
+	case "UNIQUE":
+		columns, err := parseIndexedColumns(def[i])
+		if err != nil {
+			return err
+		}
+		t.Keys = append(t.Keys, &KeyConstraint{Columns: columns})
+	case "FOREIGN":
+		columns, err := parseIndexedColumns(def[i])
+		if err != nil {
+			return err
+		}
+		if i+1 >= len(def) || !strings.EqualFold(def[i+1], "REFERENCES") {
+			return fmt.Errorf("FOREIGN KEY without REFERENCES: %s", strings.Join(def, " "))
+		}
+		fk, _ := parseForeignKeyClause(def[i+2:])
+		for _, c := range columns {
+			fk.From = append(fk.From, c.Name)
+		}
+		t.ForeignKeys = append(t.ForeignKeys, fk)
+	}
+	return nil
+}
+
+// parseForeignKeyClause reads the clause following REFERENCES, returning the number of tokens it is made of
+func parseForeignKeyClause(tokens []string) (*ForeignKey, int) {
+	fk := &ForeignKey{OnUpdate: "NO ACTION", OnDelete: "NO ACTION"}
+	if len(tokens) == 0 {
+		return fk, 0
+	}
+	fk.Table = unquoteIdent(tokens[0])
+
+	i := 1
+	if i < len(tokens) && strings.HasPrefix(tokens[i], "(") {
+		if columns, err := parseIndexedColumns(tokens[i]); err == nil {
+			for _, c := range columns {
+				fk.To = append(fk.To, c.Name)
+			}
+		}
+		i++
+	}
+
+	for i < len(tokens) {
+		switch strings.ToUpper(tokens[i]) {
+		case "ON":
+			if i+2 >= len(tokens) {
+				return fk, i
+			}
+			action := strings.ToUpper(tokens[i+2])
+			n := 3
+			if (action == "SET" || action == "NO") && i+3 < len(tokens) {
+				action += " " + strings.ToUpper(tokens[i+3])
+				n++
+			}
+			if strings.EqualFold(tokens[i+1], "DELETE") {
+				fk.OnDelete = action
+			} else {
+				fk.OnUpdate = action
+			}
+			i += n
+		case "MATCH":
+			i += 2
+		case "NOT":
+			if i+1 >= len(tokens) || !strings.EqualFold(tokens[i+1], "DEFERRABLE") {
+				return fk, i
+			}
+			i++
+		case "DEFERRABLE":
+			i++
+			if i+1 < len(tokens) && strings.EqualFold(tokens[i], "INITIALLY") {
+				i += 2
+			}
+		default:
+			return fk, i
+		}
+	}
+	return fk, min(i, len(tokens))
+}
+
+// parseIndexedColumns reads a parenthesized list of columns, each optionally followed by COLLATE and ASC or DESC
+func parseIndexedColumns(group string) ([]*IndexedColumn, error) {
+	defs, err := splitList(group)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]*IndexedColumn, 0, len(defs))
+	for _, def := range defs {
+		if len(def) == 0 {
+			continue
+		}
+		c := &IndexedColumn{Name: unquoteIdent(def[0])}
+		for i := 1; i < len(def); i++ {
+			switch strings.ToUpper(def[i]) {
+			case "COLLATE":
+				if i+1 < len(def) {
+					c.Collation = unquoteIdent(def[i+1])
+					i++
+				}
+			case "DESC":
+				c.Desc = true
+			}
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// unparenthesize strips the parentheses enclosing a whole expression
+func unparenthesize(expr string) string {
+	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+		return strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+	return expr
+}
+
+// joinTypeTokens rebuilds a declared type such as "VARCHAR(10)" or "UNSIGNED BIG INT" out of its tokens
+func joinTypeTokens(tokens []string) string {
+	var b strings.Builder
+	for i, tok := range tokens {
+		if i > 0 && !strings.HasPrefix(tok, "(") {
+			b.WriteString(" ")
+		}
+		b.WriteString(tok)
+	}
+	return b.String()
+}
+
+// splitList splits the content of a parenthesized group into the tokens of its comma separated items
+func splitList(group string) ([][]string, error) {
+	inner, err := tokenize(group[1 : len(group)-1])
+	if err != nil {
+		return nil, err
+	}
+	return splitTokens(inner, ","), nil
+}
+
+// splitTokens splits tokens at every separator
+func splitTokens(tokens []string, separator string) [][]string {
+	items := make([][]string, 0)
+	item := make([]string, 0)
+	for _, t := range tokens {
+		if t == separator {
+			items = append(items, item)
+			item = make([]string, 0)
+			continue
+		}
+		item = append(item, t)
+	}
+	return append(items, item)
+}
+
+// tokenize splits SQL text into identifiers, literals, commas and whole parenthesized groups
+func tokenize(s string) ([]string, error) {
+	tokens := make([]string, 0)
+	for i := 0; i < len(s); {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '-' && strings.HasPrefix(s[i:], "--"):
+			end := strings.IndexByte(s[i:], '\n')
+			if end < 0 {
+				return tokens, nil
+			}
+			i += end + 1
+		case ch == '/' && strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return tokens, nil
+			}
+			i += end + 4
+		case ch == ',':
+			tokens = append(tokens, ",")
+			i++
+		case ch == '(':
+			depth := 0
+			start := i
+			for ; i < len(s); i++ {
+				switch s[i] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				case '\'', '"', '`', '[':
+					end, err := closingQuote(s, i)
+					if err != nil {
+						return nil, err
+					}
+					i = end
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			if depth != 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in %q", s)
+			}
+			i++
+			tokens = append(tokens, s[start:i])
+		case ch == '\'' || ch == '"' || ch == '`' || ch == '[':
+			end, err := closingQuote(s, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, s[i:end+1])
+			i = end + 1
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r,()'\"`[", rune(s[i])) {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected character %q in %q", ch, s)
+			}
+			tokens = append(tokens, s[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+// closingQuote returns the index of the quote closing the one opened at s[start], skipping doubled quotes
+func closingQuote(s string, start int) (int, error) {
+	closing := s[start]
+	if closing == '[' {
+		closing = ']'
+	}
+	for i := start + 1; i < len(s); i++ {
+		if s[i] != closing {
+			continue
+		}
+		if closing != ']' && i+1 < len(s) && s[i+1] == closing {
+			i++
+			continue
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("unterminated quote in %q", s)
+}
+
+func unquoteIdent(s string) string {
+	if len(s) < 2 {
+		return s
+	}
+	switch s[0] {
+	case '"', '`', '\'':
+		q := string(s[0])
+		return strings.ReplaceAll(s[1:len(s)-1], q+q, q)
+	case '[':
+		return s[1 : len(s)-1]
+	default:
+		return s
+	}
+}
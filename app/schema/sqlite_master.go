@@ -5,6 +5,7 @@ import (
 	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
 	"github/com/codecrafters-io/sqlite-starter-go/app/parser"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/rqlite/sql"
 )
@@ -34,87 +35,63 @@ type SQLiteMasterRow struct {
 	SQL        string
 }
 
-func (r *SQLiteMasterRow) GetColumn(column string) (*sql.ColumnDefinition, error) {
-	stmt, err := parser.NewStatement(r.SQL)
+func (r *SQLiteMasterRow) GetColumn(column string) (*Column, error) {
+	columns, err := r.GetColumns()
 	if err != nil {
 		return nil, err
 	}
 
-	switch s := stmt.(type) {
-	case *sql.CreateTableStatement:
-		for _, c := range s.Columns {
-			if c.Name.String() == column {
-				return c, nil
-			}
+	for _, c := range columns {
+		if strings.EqualFold(c.Name, column) {
+			return c, nil
 		}
-		return nil, fmt.Errorf("column %s not found", column)
-	default:
-		return nil, fmt.Errorf("GetColumnNames() is not implemented for statement type %T", stmt)
 	}
+	return nil, fmt.Errorf("column %s not found", column)
 }
 
-func (r *SQLiteMasterRow) AutoIncrIntegerPrimaryKeys() ([]string, error) {
-	stmt, err := parser.NewStatement(r.SQL)
+// RowIDAliasColumns returns the INTEGER PRIMARY KEY columns, which are stored as NULL in the record and read from the row id
+func (r *SQLiteMasterRow) RowIDAliasColumns() ([]string, error) {
+	columns, err := r.GetColumns()
 	if err != nil {
 		return nil, err
 	}
 
 	keys := make([]string, 0)
-	switch s := stmt.(type) {
-	case *sql.CreateTableStatement:
-		for i, c := range s.Columns {
-			if i%2 == 1 {
-				continue
-			}
-
-			typeInfo := s.Columns[i+1]
-			if typeInfo.Name.String() == `"integer"` {
-				for _, constraint := range typeInfo.Constraints {
-					if constraint.String() == "PRIMARY KEY AUTOINCREMENT" {
-						keys = append(keys, c.Name.String())
-					}
-				}
-			}
+	for _, c := range columns {
+		if c.IsRowIDAlias() {
+			keys = append(keys, c.Name)
 		}
-		return keys, nil
-	default:
-		return nil, fmt.Errorf("HasAutoIncrIntegerPrimaryKey() is not implemented for statement type %T", stmt)
 	}
+	return keys, nil
 }
 
-func (r *SQLiteMasterRow) GetColumns() ([]*sql.ColumnDefinition, error) {
-	stmt, err := parser.NewStatement(r.SQL)
-	if err != nil {
-		return nil, err
-	}
-
-	switch s := stmt.(type) {
-	case *sql.CreateTableStatement:
-		columns := make([]*sql.ColumnDefinition, 0)
-		for i, c := range s.Columns {
-			// odd index data of s.Columns are for column types
-			if i%2 == 1 {
-				continue
-			}
-			columns = append(columns, c)
-		}
-		return columns, nil
-	default:
-		return nil, fmt.Errorf("GetColumnNames() is not implemented for statement type %T", stmt)
+func (r *SQLiteMasterRow) GetColumns() ([]*Column, error) {
+	if r.ObjectType != ObjectTypeTable {
+		return nil, fmt.Errorf("GetColumns() is not implemented for object type %s", r.ObjectType)
 	}
+	return ParseColumns(r.SQL)
 }
 
 type SQLiteMasterRows []*SQLiteMasterRow
 
-func (rs SQLiteMasterRows) AutoIncrIntegerPrimaryKeys(table string) ([]string, error) {
+// GetTable returns the schema row of table, table names being case-insensitive
+func (rs SQLiteMasterRows) GetTable(table string) (*SQLiteMasterRow, error) {
 	for _, r := range rs {
-		if r.TableName == table {
-			return r.AutoIncrIntegerPrimaryKeys()
+		if r.ObjectType == ObjectTypeTable && strings.EqualFold(r.TableName, table) {
+			return r, nil
 		}
 	}
 	return nil, fmt.Errorf(`table "%s" not found`, table)
 }
 
+func (rs SQLiteMasterRows) RowIDAliasColumns(table string) ([]string, error) {
+	r, err := rs.GetTable(table)
+	if err != nil {
+		return nil, err
+	}
+	return r.RowIDAliasColumns()
+}
+
 func (rs SQLiteMasterRows) RootTablePageMapByTableNames() map[string]int {
 	m := make(map[string]int)
 	for _, row := range rs {
@@ -126,78 +103,119 @@ func (rs SQLiteMasterRows) RootTablePageMapByTableNames() map[string]int {
 }
 
 type IndexPageAndColumns struct {
+	Name    string
 	PageNum int
 	Columns []string
+	// Collations are the names given by COLLATE to the columns, empty for a column sorted by the collation of
+	// the table column
+	Collations []string
+	// Partial is true for indexes with a WHERE clause, which only hold the rows matching it
+	Partial bool
 }
 
-func (rs SQLiteMasterRows) RootIndexPageAndColumnMapByTableNames() (map[string]*IndexPageAndColumns, error) {
-	m := make(map[string]*IndexPageAndColumns)
+// RootIndexPageAndColumnMapByTableNames maps table names to their indexes. Indexes created for UNIQUE and
+// PRIMARY KEY constraints have no SQL and are left out.
+func (rs SQLiteMasterRows) RootIndexPageAndColumnMapByTableNames() (map[string][]*IndexPageAndColumns, error) {
+	m := make(map[string][]*IndexPageAndColumns)
 	for _, row := range rs {
-		if strings.Contains(row.SQL, "CREATE INDEX") {
-			stmt, err := parser.NewStatement(row.SQL)
-			if err != nil {
-				return nil, err
-			}
-			switch s := stmt.(type) {
-			case *sql.CreateIndexStatement:
-				columns := s.Columns
-				columnNames := make([]string, 0)
-				for _, column := range columns {
-					columnNames = append(columnNames, column.String())
+		if row.ObjectType != ObjectTypeIndex || row.SQL == "" {
+			continue
+		}
+
+		stmt, err := parser.NewStatement(row.SQL)
+		if err != nil {
+			return nil, err
+		}
+		switch s := stmt.(type) {
+		case *sql.CreateIndexStatement:
+			columnNames := make([]string, 0)
+			collations := make([]string, 0)
+			for _, column := range s.Columns {
+				switch x := column.X.(type) {
+				case *sql.Ident:
+					columnNames = append(columnNames, x.Name)
+				default:
+					columnNames = append(columnNames, x.String())
 				}
-				m[row.TableName] = &IndexPageAndColumns{
-					PageNum: row.RootPage,
-					Columns: columnNames,
+				collation := ""
+				if column.Collation != nil {
+					collation = column.Collation.Name
 				}
-			default:
-				return nil, fmt.Errorf("RootIndexPageAndColumnMapByTableNames() is not implemented for statement type %T", stmt)
+				collations = append(collations, collation)
 			}
+			m[row.TableName] = append(m[row.TableName], &IndexPageAndColumns{
+				Name:       row.Name,
+				PageNum:    row.RootPage,
+				Columns:    columnNames,
+				Collations: collations,
+				Partial:    s.WhereExpr != nil,
+			})
+		default:
+			return nil, fmt.Errorf("RootIndexPageAndColumnMapByTableNames() is not implemented for statement type %T", stmt)
 		}
 	}
 	return m, nil
 }
 
-func (rs SQLiteMasterRows) GetTableNames() []string {
-	tableNames := make([]string, len(rs))
-	for i, r := range rs {
-		tableNames[i] = r.TableName
+// Count returns the number of objects of type t
+func (rs SQLiteMasterRows) Count(t ObjectType) int {
+	count := 0
+	for _, r := range rs {
+		if r.ObjectType == t {
+			count++
+		}
 	}
-	return tableNames
+	return count
 }
 
-func (rs SQLiteMasterRows) GetColumn(table, column string) (*sql.ColumnDefinition, error) {
+// SQLSize returns the total length in characters of the SQL the objects are created by
+func (rs SQLiteMasterRows) SQLSize() int {
+	size := 0
 	for _, r := range rs {
-		if r.TableName == table {
-			return r.GetColumn(column)
-		}
+		size += utf8.RuneCountInString(r.SQL)
 	}
-	return nil, fmt.Errorf(`table "%s" not found`, table)
+	return size
 }
 
-func (rs SQLiteMasterRows) GetColumns(table string) ([]*sql.ColumnDefinition, error) {
+// GetTableNames returns the names of the tables, the internal sqlite_ ones included
+func (rs SQLiteMasterRows) GetTableNames() []string {
+	tableNames := make([]string, 0, len(rs))
 	for _, r := range rs {
-		if r.TableName == table {
-			return r.GetColumns()
+		if r.ObjectType == ObjectTypeTable {
+			tableNames = append(tableNames, r.Name)
 		}
 	}
-	return nil, fmt.Errorf(`table "%s" not found`, table)
+	return tableNames
+}
+
+func (rs SQLiteMasterRows) GetColumn(table, column string) (*Column, error) {
+	r, err := rs.GetTable(table)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetColumn(column)
 }
 
+func (rs SQLiteMasterRows) GetColumns(table string) ([]*Column, error) {
+	r, err := rs.GetTable(table)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetColumns()
+}
+
+// ColumnPosMapByName maps lower-cased column names to their positions in the record
 func (rs SQLiteMasterRows) ColumnPosMapByName(table string) (map[string]int, error) {
-	for _, r := range rs {
-		if r.TableName == table {
-			columns, err := r.GetColumns()
-			if err != nil {
-				return nil, err
-			}
-			m := make(map[string]int)
-			for i, c := range columns {
-				m[c.Name.String()] = i
-			}
-			return m, nil
-		}
+	columns, err := rs.GetColumns(table)
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf(`table "%s" not found`, table)
+
+	m := make(map[string]int)
+	for i, c := range columns {
+		m[strings.ToLower(c.Name)] = i
+	}
+	return m, nil
 }
 
 func (rs SQLiteMasterRows) GetColumnPos(table, column string) (int, error) {
@@ -207,7 +225,7 @@ func (rs SQLiteMasterRows) GetColumnPos(table, column string) (int, error) {
 	}
 
 	for i, c := range cs {
-		if strings.ReplaceAll(c.Name.String(), `"`, "") == column {
+		if strings.EqualFold(c.Name, column) {
 			return i, nil
 		}
 	}
@@ -222,7 +240,7 @@ func (rs SQLiteMasterRows) GetColumnPosList(table string, columns []string) ([]i
 
 	posList := make([]int, 0)
 	for _, c := range columns {
-		pos, exists := columnToPos[c]
+		pos, exists := columnToPos[strings.ToLower(c)]
 		if !exists {
 			return nil, fmt.Errorf(`column "%s" not found`, c)
 		}
@@ -0,0 +1,145 @@
+package schema
+
+import (
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/parser"
+	"strconv"
+	"strings"
+
+	"github.com/rqlite/sql"
+)
+
+// GetView returns the schema row of view, view names being case-insensitive
+func (rs SQLiteMasterRows) GetView(view string) (*SQLiteMasterRow, error) {
+	for _, r := range rs {
+		if r.ObjectType == ObjectTypeView && strings.EqualFold(r.Name, view) {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("view %s not found", view)
+}
+
+// ViewColumns returns the names of the columns of view as SQLite names them: the column list of the view if it
+// has one, otherwise the aliases, the column names and the text of the expressions of its result columns,
+// a name taken already being suffixed with ":N". Views selecting from subqueries are not supported.
+func (rs SQLiteMasterRows) ViewColumns(view string) ([]string, error) {
+	r, err := rs.GetView(view)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := parser.NewStatement(r.SQL)
+	if err != nil {
+		return nil, err
+	}
+	cv, ok := stmt.(*sql.CreateViewStatement)
+	if !ok {
+		return nil, fmt.Errorf("invalid view statement type: %T", stmt)
+	}
+
+	if len(cv.Columns) > 0 {
+		names := make([]string, len(cv.Columns))
+		for i, c := range cv.Columns {
+			names[i] = c.Name
+		}
+		return names, nil
+	}
+
+	ss := cv.Select
+	if ss.Source == nil {
+		return nil, fmt.Errorf("view %s selects no table", view)
+	}
+	sources, err := rs.viewSources(ss.Source, make([]*viewSource, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	texts := parser.ResultColumnTexts(r.SQL, ss)
+	names := make([]string, 0, len(ss.Columns))
+	for i, c := range ss.Columns {
+		switch e := c.Expr.(type) {
+		case nil:
+			for _, s := range sources {
+				names = append(names, s.columns...)
+			}
+		case *sql.QualifiedRef:
+			if !e.Star.IsValid() {
+				names = append(names, e.Column.Name)
+				break
+			}
+			found := false
+			for _, s := range sources {
+				if strings.EqualFold(s.name, e.Table.Name) {
+					names, found = append(names, s.columns...), true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("no such table: %s", e.Table.Name)
+			}
+		case *sql.Ident:
+			names = append(names, e.Name)
+		default:
+			if texts != nil {
+				names = append(names, texts[i])
+			} else {
+				names = append(names, e.String())
+			}
+		}
+		if c.Alias != nil {
+			names[len(names)-1] = c.Alias.Name
+		}
+	}
+	return uniqueNames(names), nil
+}
+
+// viewSource is a table or a view a view selects from, name being its alias if it has one
+type viewSource struct {
+	name    string
+	columns []string
+}
+
+func (rs SQLiteMasterRows) viewSources(source sql.Source, sources []*viewSource) ([]*viewSource, error) {
+	switch s := source.(type) {
+	case *sql.QualifiedTableName:
+		name := s.Name.Name
+		columns := make([]string, 0)
+		if t, err := rs.GetTable(name); err == nil {
+			tableColumns, err := t.GetColumns()
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range tableColumns {
+				columns = append(columns, c.Name)
+			}
+		} else if columns, err = rs.ViewColumns(name); err != nil {
+			return nil, err
+		}
+
+		if s.Alias != nil {
+			name = s.Alias.Name
+		}
+		return append(sources, &viewSource{name: name, columns: columns}), nil
+	case *sql.JoinClause:
+		sources, err := rs.viewSources(s.X, sources)
+		if err != nil {
+			return nil, err
+		}
+		return rs.viewSources(s.Y, sources)
+	default:
+		return nil, fmt.Errorf("source is not supported: %s", source.String())
+	}
+}
+
+// uniqueNames suffixes the names taken already with ":N", N counting the names renamed so
+func uniqueNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	n := 0
+	for i, name := range names {
+		for seen[strings.ToLower(names[i])] {
+			n++
+			names[i] = name + ":" + strconv.Itoa(n)
+		}
+		seen[strings.ToLower(names[i])] = true
+	}
+	return names
+}
@@ -0,0 +1,161 @@
+package shell
+
+import (
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/eval"
+	"github/com/codecrafters-io/sqlite-starter-go/app/output"
+	"github/com/codecrafters-io/sqlite-starter-go/app/schema"
+	"github/com/codecrafters-io/sqlite-starter-go/app/sqlite"
+	"io"
+	"slices"
+	"strings"
+)
+
+// internalName matches the names of the tables and indexes SQLite creates itself, like LIKE 'sqlite_%' does
+func internalName(name string) bool {
+	return eval.Like("sqlite_%", name)
+}
+
+// printTables prints the tables and views whose names match the LIKE pattern, every one when it is empty,
+// leaving out the internal ones
+func printTables(w io.Writer, rows schema.SQLiteMasterRows, pattern string) {
+	names := make([]string, 0)
+	for _, r := range rows {
+		if r.ObjectType != schema.ObjectTypeTable && r.ObjectType != schema.ObjectTypeView || internalName(r.Name) {
+			continue
+		}
+		if pattern == "" || eval.Like(pattern, r.Name) {
+			names = append(names, r.Name)
+		}
+	}
+	slices.Sort(names)
+	printColumns(w, names)
+}
+
+// printIndexes prints the indexes of the tables whose names match the LIKE pattern, every one when it is empty
+func printIndexes(w io.Writer, rows schema.SQLiteMasterRows, pattern string) {
+	names := make([]string, 0)
+	for _, r := range rows {
+		if r.ObjectType != schema.ObjectTypeIndex {
+			continue
+		}
+		if pattern == "" || eval.Like(pattern, r.TableName) {
+			names = append(names, r.Name)
+		}
+	}
+	slices.Sort(names)
+	printColumns(w, names)
+}
+
+// printColumns prints names down columns as wide as the longest name, fitting as many as possible in 80 characters
+func printColumns(w io.Writer, names []string) {
+	if len(names) == 0 {
+		return
+	}
+
+	width := 0
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+	columns := max(80/(width+2), 1)
+	lines := (len(names) + columns - 1) / columns
+
+	for i := 0; i < lines; i++ {
+		for j := i; j < len(names); j += lines {
+			if j >= lines {
+				fmt.Fprint(w, "  ")
+			}
+			fmt.Fprintf(w, "%-*s", width, names[j])
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// printSchema prints the SQL of the objects of the tables whose names match pattern, every object when it is
+// empty. The pattern is a GLOB pattern the lower-cased table names are matched against when it has a wildcard
+// of GLOB, and a LIKE pattern otherwise. The columns of a view follow its SQL in a comment.
+func printSchema(w io.Writer, rows schema.SQLiteMasterRows, pattern string) {
+	glob := strings.ContainsAny(pattern, "*?[")
+	for _, r := range rows {
+		if r.SQL == "" {
+			continue
+		}
+		switch {
+		case pattern == "":
+		case glob && !eval.Glob(pattern, strings.ToLower(r.TableName)):
+			continue
+		case !glob && !eval.Like(pattern, r.TableName):
+			continue
+		}
+
+		fmt.Fprint(w, schemaSQL(r))
+		if r.ObjectType == schema.ObjectTypeView {
+			if columns, err := rows.ViewColumns(r.Name); err == nil {
+				for i, c := range columns {
+					columns[i] = output.QuoteIdent(c)
+				}
+				fmt.Fprintf(w, "\n/* %s(%s) */", output.QuoteIdent(r.Name), strings.Join(columns, ","))
+			}
+		}
+		fmt.Fprintln(w, ";")
+	}
+}
+
+// printFullSchema prints the SQL of every object but the internal ones, followed by the statistics ANALYZE
+// gathered as the statements restoring them
+func printFullSchema(w io.Writer, db sqlite.DB) error {
+	rows := db.Schema()
+	for _, r := range rows {
+		if r.SQL != "" && !internalName(r.Name) {
+			fmt.Fprintf(w, "%s;\n", schemaSQL(r))
+		}
+	}
+
+	stats := map[string]string{
+		"sqlite_stat1": "SELECT tbl, idx, stat FROM sqlite_stat1",
+		"sqlite_stat4": "SELECT tbl, idx, neq, nlt, ndlt, sample FROM sqlite_stat4",
+	}
+	found := false
+	for _, table := range []string{"sqlite_stat1", "sqlite_stat4"} {
+		if _, err := rows.GetTable(table); err != nil {
+			continue
+		}
+		if !found {
+			fmt.Fprintln(w, "ANALYZE sqlite_schema;")
+			found = true
+		}
+
+		stat, err := db.Query(stats[table])
+		if err != nil {
+			return err
+		}
+		for stat.Next() {
+			values := stat.Values()
+			literals := make([]string, len(values))
+			for i, v := range values {
+				literals[i] = v.Quote()
+			}
+			fmt.Fprintf(w, "INSERT INTO %s VALUES(%s);\n", table, strings.Join(literals, ","))
+		}
+		if err := stat.Err(); err != nil {
+			return err
+		}
+	}
+
+	if found {
+		fmt.Fprintln(w, "ANALYZE sqlite_schema;")
+	} else {
+		fmt.Fprintln(w, "/* No STAT tables available */")
+	}
+	return nil
+}
+
+// schemaSQL returns the SQL of r as the sqlite3 shell prints it, a table whose name is quoted, like the ones
+// ALTER TABLE renames, being created IF NOT EXISTS
+func schemaSQL(r *schema.SQLiteMasterRow) string {
+	if rest, ok := strings.CutPrefix(r.SQL, "CREATE TABLE "); ok && (strings.HasPrefix(rest, `"`) ||
+		strings.HasPrefix(rest, "'")) {
+		return "CREATE TABLE IF NOT EXISTS " + rest
+	}
+	return r.SQL
+}
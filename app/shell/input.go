@@ -0,0 +1,112 @@
+package shell
+
+import "strings"
+
+// splitStatements returns the statements of input terminated by a semicolon outside of string literals,
+// quoted identifiers and comments, each with its semicolon, and the rest of input following the last one
+func splitStatements(input string) ([]string, string) {
+	statements := make([]string, 0)
+	start := 0
+	for i := 0; i < len(input); i++ {
+		switch c := input[i]; c {
+		case '\'', '"', '`':
+			i = skipPast(input, i+1, string(c))
+		case '[':
+			i = skipPast(input, i+1, "]")
+		case '-':
+			if strings.HasPrefix(input[i:], "--") {
+				i = skipPast(input, i+2, "\n")
+			}
+		case '/':
+			if strings.HasPrefix(input[i:], "/*") {
+				i = skipPast(input, i+2, "*/")
+			}
+		case ';':
+			statements = append(statements, strings.TrimSpace(input[start:i+1]))
+			start = i + 1
+		}
+	}
+	return statements, input[start:]
+}
+
+// skipPast returns the index of the last byte of the first end following from, the end of s when there is none.
+// A doubled quote within a string literal or a quoted identifier is read as the quote character itself.
+func skipPast(s string, from int, end string) int {
+	for {
+		i := strings.Index(s[min(from, len(s)):], end)
+		if i < 0 {
+			return len(s)
+		}
+		last := from + i + len(end) - 1
+		if len(end) == 1 && end != "\n" && end != "]" && last+1 < len(s) && s[last+1] == end[0] {
+			from = last + 2
+			continue
+		}
+		return last
+	}
+}
+
+// isBlank reports whether input holds nothing but spaces, comments and semicolons
+func isBlank(input string) bool {
+	for input = strings.TrimSpace(input); input != ""; input = strings.TrimSpace(input) {
+		switch {
+		case input[0] == ';':
+			input = input[1:]
+		case strings.HasPrefix(input, "--"):
+			_, input, _ = strings.Cut(input, "\n")
+		case strings.HasPrefix(input, "/*"):
+			// an unterminated comment goes on in the lines following it
+			var found bool
+			if _, input, found = strings.Cut(input[2:], "*/"); !found {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// splitCommandArgs splits a dot command into its name and arguments, which may be quoted to hold spaces.
+// Backslash escapes like \t are read in double-quoted arguments, as in sqlite3.
+func splitCommandArgs(command string) []string {
+	args := make([]string, 0)
+	for rest := strings.TrimSpace(command); rest != ""; rest = strings.TrimSpace(rest) {
+		if rest[0] == '\'' {
+			arg, after, _ := strings.Cut(rest[1:], "'")
+			args, rest = append(args, arg), after
+			continue
+		}
+		if rest[0] == '"' {
+			// an unterminated argument runs to the end of the line
+			end := skipPast(rest, 1, `"`)
+			if end == len(rest) {
+				args, rest = append(args, unescape(rest[1:])), ""
+				continue
+			}
+			args, rest = append(args, unescape(rest[1:end])), rest[end+1:]
+			continue
+		}
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			end = len(rest)
+		}
+		args, rest = append(args, rest[:end]), rest[end:]
+	}
+	return args
+}
+
+// commandArg returns the first argument of a dot command, empty when it has none
+func commandArg(args []string) string {
+	if len(args) < 2 {
+		return ""
+	}
+	return args[1]
+}
+
+var escapes = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r", `\"`, `"`, `\'`, "'")
+
+// unescape reads the backslash escapes of a double-quoted argument
+func unescape(arg string) string {
+	return escapes.Replace(arg)
+}
@@ -0,0 +1,28 @@
+package shell
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitCommandArgs(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{command: ".tables", want: []string{".tables"}},
+		{command: "  .mode   csv  ", want: []string{".mode", "csv"}},
+		{command: ".separator ' ' '\\n'", want: []string{".separator", " ", "\\n"}},
+		{command: `.separator "\t" "\n"`, want: []string{".separator", "\t", "\n"}},
+		{command: `.nullvalue "a b"x`, want: []string{".nullvalue", "a b", "x"}},
+		{command: `.nullvalue ""`, want: []string{".nullvalue", ""}},
+		{command: `.nullvalue "abc`, want: []string{".nullvalue", "abc"}},
+		{command: `.nullvalue "`, want: []string{".nullvalue", ""}},
+		{command: `.nullvalue 'abc`, want: []string{".nullvalue", "abc"}},
+	}
+	for _, tt := range tests {
+		if got := splitCommandArgs(tt.command); !slices.Equal(got, tt.want) {
+			t.Errorf("splitCommandArgs(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
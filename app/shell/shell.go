@@ -0,0 +1,332 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/output"
+	"github/com/codecrafters-io/sqlite-starter-go/app/parser"
+	"github/com/codecrafters-io/sqlite-starter-go/app/schema"
+	"github/com/codecrafters-io/sqlite-starter-go/app/sqlite"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rqlite/sql"
+)
+
+const (
+	prompt             = "sqlite> "
+	continuationPrompt = "   ...> "
+)
+
+// errQuit is returned by .quit and .exit to end the session
+var errQuit = errors.New("quit")
+
+// Shell runs dot commands and SQL statements against a database like the sqlite3 command-line shell
+type Shell struct {
+	db     sqlite.DB
+	out    io.Writer
+	errOut io.Writer
+	opts   *output.Options
+	// history records the lines of an interactive session, nil when they are not kept
+	history io.Writer
+}
+
+// New returns a Shell printing results to out and errors to errOut
+func New(db sqlite.DB, out, errOut io.Writer) *Shell {
+	return &Shell{db: db, out: out, errOut: errOut, opts: output.NewOptions()}
+}
+
+// SetOptions sets the options results are printed with, which the dot commands change
+func (s *Shell) SetOptions(opts *output.Options) {
+	s.opts = opts
+}
+
+// SetHistory makes the lines read by Run be recorded in w
+func (s *Shell) SetHistory(w io.Writer) {
+	s.history = w
+}
+
+// HistoryPath returns the path of the history file: $SQLITE_HISTORY as in sqlite3, ~/.sqlite_history by default.
+// It returns an empty path when there is no home directory to keep the history in.
+func HistoryPath() string {
+	if path := os.Getenv("SQLITE_HISTORY"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".sqlite_history")
+}
+
+// Execute runs a dot command, or the SQL statements of input one after the other, stopping at the first error
+func (s *Shell) Execute(input string) error {
+	if isCommand(input) {
+		if err := s.runCommand(input); !errors.Is(err, errQuit) {
+			return err
+		}
+		return nil
+	}
+
+	statements, rest := splitStatements(input)
+	if !isBlank(rest) {
+		statements = append(statements, strings.TrimSpace(rest))
+	}
+	for _, q := range statements {
+		if err := s.runSQL(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run reads lines from in until it ends or .quit is entered, running every dot command as soon as it is read and
+// every SQL statement once its semicolon is. The prompts are printed when interactive is set. An error ends
+// the statement or the command it comes from, not the session.
+func (s *Shell) Run(in io.Reader, interactive bool) error {
+	if interactive {
+		fmt.Fprintln(s.out, `Enter ".help" for usage hints.`)
+	}
+
+	r := bufio.NewReader(in)
+	pending := ""
+	for {
+		if interactive {
+			if pending == "" {
+				fmt.Fprint(s.out, prompt)
+			} else {
+				fmt.Fprint(s.out, continuationPrompt)
+			}
+		}
+
+		line, err := r.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if line == "" && err != nil {
+			break
+		}
+		s.record(line)
+
+		switch {
+		// a dot command is only read at the start of a statement
+		case pending == "" && isCommand(line):
+			if err := s.runCommand(line); errors.Is(err, errQuit) {
+				return nil
+			} else if err != nil {
+				s.report(err)
+			}
+		case pending == "" && strings.HasPrefix(strings.TrimSpace(line), "#"):
+		default:
+			statements, rest := splitStatements(pending + line)
+			for _, q := range statements {
+				if isBlank(q) {
+					continue
+				}
+				if err := s.runSQL(q); err != nil {
+					s.report(err)
+				}
+			}
+			pending = ""
+			if !isBlank(rest) {
+				pending = rest
+			}
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	// the last statement is run even without its semicolon
+	if pending != "" {
+		if err := s.runSQL(strings.TrimSpace(pending)); err != nil {
+			s.report(err)
+		}
+	}
+	return nil
+}
+
+func isCommand(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), ".")
+}
+
+// record appends a line to the history, blank lines left out
+func (s *Shell) record(line string) {
+	if s.history == nil || strings.TrimSpace(line) == "" {
+		return
+	}
+	// failing to keep the history is not worth interrupting the session for
+	_, _ = fmt.Fprintln(s.history, strings.TrimRight(line, "\r\n"))
+}
+
+func (s *Shell) report(err error) {
+	fmt.Fprintf(s.errOut, "Error: %v\n", err)
+}
+
+// runCommand runs a dot command, e.g. ".schema books"
+func (s *Shell) runCommand(command string) error {
+	args := splitCommandArgs(command)
+	switch args[0] {
+	case ".dbinfo":
+		printDBInfo(s.out, s.db)
+	case ".tables":
+		printTables(s.out, s.db.Schema(), commandArg(args))
+	case ".indexes", ".indices":
+		printIndexes(s.out, s.db.Schema(), commandArg(args))
+	case ".schema":
+		printSchema(s.out, s.db.Schema(), commandArg(args))
+	case ".fullschema":
+		return printFullSchema(s.out, s.db)
+	case ".mode":
+		return s.setMode(args)
+	case ".headers", ".header":
+		if len(args) != 2 {
+			return errors.New("usage: .headers on|off")
+		}
+		on, err := parseBool(args[1])
+		if err != nil {
+			return err
+		}
+		s.opts.SetHeaders(on)
+	case ".nullvalue":
+		if len(args) != 2 {
+			return errors.New("usage: .nullvalue STRING")
+		}
+		s.opts.NullValue = args[1]
+	case ".separator":
+		if len(args) < 2 || len(args) > 3 {
+			return errors.New("usage: .separator COL ?ROW?")
+		}
+		s.opts.ColumnSeparator = args[1]
+		if len(args) == 3 {
+			s.opts.RowSeparator = args[2]
+		}
+	case ".help":
+		fmt.Fprint(s.out, help)
+	case ".quit", ".exit":
+		return errQuit
+	default:
+		return fmt.Errorf(`unknown command or invalid arguments:  "%s". Enter ".help" for help`, args[0][1:])
+	}
+	return nil
+}
+
+const help = `.dbinfo                  Show status information about the database
+.exit                    Exit this program
+.fullschema              Show the schema and the content of sqlite_stat tables
+.headers on|off          Turn display of headers on or off
+.help                    Show this message
+.indexes ?TABLE?         Show names of indexes
+.mode MODE ?TABLE?       Set output mode, one of box, column, csv, insert, json, line, list, markdown, quote,
+                         table and tabs, TABLE being the table insert writes to
+.nullvalue STRING        Use STRING in place of NULL values
+.quit                    Exit this program
+.schema ?PATTERN?        Show the CREATE statements matching PATTERN
+.separator COL ?ROW?     Change the column and row separators of list, csv and tabs
+.tables ?TABLE?          List names of tables matching LIKE pattern TABLE
+`
+
+// runSQL runs a SELECT or PRAGMA statement, printing its rows as they are read
+func (s *Shell) runSQL(q string) error {
+	// the SQL parser does not read PRAGMA statements, which the database parses itself
+	if !parser.IsPragma(q) {
+		stmt, err := parser.NewStatement(q)
+		if err != nil {
+			return err
+		}
+		if _, ok := stmt.(*sql.SelectStatement); !ok {
+			return errors.New("only SELECT and PRAGMA statements are supported")
+		}
+	}
+
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns := make([]string, len(rows.Columns()))
+	for i, c := range rows.Columns() {
+		columns[i] = c.Name
+	}
+	f := output.New(s.out, s.opts, columns)
+	for rows.Next() {
+		if err := f.Row(rows.Values()); err != nil {
+			return err
+		}
+	}
+	// the rows read before an error are printed all the same
+	if err := f.Flush(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// setMode runs .mode, printing the mode without argument
+func (s *Shell) setMode(args []string) error {
+	if len(args) == 1 {
+		fmt.Fprintf(s.out, "current output mode: %s\n", s.opts.Mode)
+		return nil
+	}
+
+	mode, err := output.ParseMode(args[1])
+	if err != nil {
+		return err
+	}
+	s.opts.SetMode(mode)
+	if mode == output.ModeInsert && len(args) > 2 {
+		s.opts.Table = args[2]
+	}
+	return nil
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "on", "yes", "true", "1":
+		return true, nil
+	case "off", "no", "false", "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("not a boolean value: %s", s)
+	}
+}
+
+// printDBInfo prints the fields of the database header and the number of schema objects like the sqlite3 shell
+func printDBInfo(w io.Writer, db sqlite.DB) {
+	h := db.FileHeader()
+	field := func(name string, v any) {
+		fmt.Fprintf(w, "%-20s %v\n", name, v)
+	}
+
+	field("database page size:", h.PageSize)
+	field("write format:", h.WriteVersion)
+	field("read format:", h.ReadVersion)
+	field("reserved bytes:", h.ReservedBytes)
+	field("file change counter:", h.FileChangeCounter)
+	field("database page count:", h.DatabaseSize)
+	field("freelist page count:", h.FreelistPageCount)
+	field("schema cookie:", h.SchemaCookie)
+	field("schema format:", h.SchemaFormat)
+	field("default cache size:", h.DefaultCacheSize)
+	field("autovacuum top root:", h.LargestRootPage)
+	field("incremental vacuum:", h.IncrementalVacuum)
+	if name := h.TextEncoding.String(); name != "" {
+		field("text encoding:", fmt.Sprintf("%d (%s)", h.TextEncoding, name))
+	} else {
+		field("text encoding:", uint32(h.TextEncoding))
+	}
+	field("user version:", h.UserVersion)
+	field("application id:", h.ApplicationID)
+	field("software version:", h.SQLiteVersionNumber)
+
+	rows := db.Schema()
+	field("number of tables:", rows.Count(schema.ObjectTypeTable))
+	field("number of indexes:", rows.Count(schema.ObjectTypeIndex))
+	field("number of triggers:", rows.Count(schema.ObjectTypeTrigger))
+	field("number of views:", rows.Count(schema.ObjectTypeView))
+	field("schema size:", rows.SQLSize())
+}
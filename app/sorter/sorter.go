@@ -0,0 +1,280 @@
+package sorter
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/utils"
+	"io"
+	"os"
+	"sort"
+)
+
+// DefaultMemoryLimit is the number of bytes of rows a Sorter holds in memory before spilling them to a temporary file
+const DefaultMemoryLimit = 8 << 20
+
+// Sorter sorts rows of values, spilling sorted runs to temporary files once they no longer fit in memory
+// and merging the runs back when the rows are read
+type Sorter struct {
+	compare     func(x, y []cell.Value) int
+	memoryLimit int
+	rows        [][]cell.Value
+	rowsSize    int
+	runs        []*os.File
+}
+
+type NewSorterRequest struct {
+	Compare func(x, y []cell.Value) int
+	// MemoryLimit defaults to DefaultMemoryLimit when zero
+	MemoryLimit int
+}
+
+func NewSorter(r *NewSorterRequest) *Sorter {
+	memoryLimit := r.MemoryLimit
+	if memoryLimit <= 0 {
+		memoryLimit = DefaultMemoryLimit
+	}
+	return &Sorter{
+		compare:     r.Compare,
+		memoryLimit: memoryLimit,
+		rows:        make([][]cell.Value, 0),
+	}
+}
+
+func (s *Sorter) Add(row []cell.Value) error {
+	s.rows = append(s.rows, row)
+	s.rowsSize += rowSize(row)
+	if s.rowsSize < s.memoryLimit {
+		return nil
+	}
+	return s.spill()
+}
+
+// rowSize estimates the memory held by row
+func rowSize(row []cell.Value) int {
+	size := 24
+	for _, v := range row {
+		size += 48 + len(v.Bytes)
+	}
+	return size
+}
+
+func (s *Sorter) sortRows() {
+	sort.SliceStable(s.rows, func(i, j int) bool {
+		return s.compare(s.rows[i], s.rows[j]) < 0
+	})
+}
+
+// spill writes the rows held in memory to a temporary file as a sorted run
+func (s *Sorter) spill() error {
+	s.sortRows()
+
+	f, err := os.CreateTemp("", "sqlite-sort-*")
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, f)
+
+	w := bufio.NewWriter(f)
+	for _, row := range s.rows {
+		record := cell.EncodeRecord(row)
+		if _, err := w.Write(utils.AppendUvarint(nil, uint64(len(record)))); err != nil {
+			return err
+		}
+		if _, err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	s.rows = s.rows[:0]
+	s.rowsSize = 0
+	return nil
+}
+
+// Sort finishes adding rows and returns an iterator over them in sorted order
+func (s *Sorter) Sort() (*Iterator, error) {
+	if len(s.runs) == 0 {
+		s.sortRows()
+		return &Iterator{rows: s.rows}, nil
+	}
+
+	if len(s.rows) > 0 {
+		if err := s.spill(); err != nil {
+			return nil, err
+		}
+	}
+
+	m := &merger{compare: s.compare}
+	for i, f := range s.runs {
+		r := &run{index: i, r: bufio.NewReader(f)}
+		ok, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m.runs = append(m.runs, r)
+		}
+	}
+	heap.Init(m)
+	return &Iterator{merger: m}, nil
+}
+
+// Close removes the temporary files of the spilled runs
+func (s *Sorter) Close() error {
+	errs := make([]error, 0)
+	for _, f := range s.runs {
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		if err := os.Remove(f.Name()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.runs = nil
+	return errors.Join(errs...)
+}
+
+// Iterator reads the rows of a Sorter in sorted order
+type Iterator struct {
+	rows   [][]cell.Value
+	merger *merger
+	row    []cell.Value
+	err    error
+}
+
+// Next advances to the next row, returning false once the rows are exhausted or reading them failed
+func (it *Iterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if it.merger == nil {
+		if len(it.rows) == 0 {
+			it.row = nil
+			return false
+		}
+		it.row, it.rows = it.rows[0], it.rows[1:]
+		return true
+	}
+
+	if it.merger.Len() == 0 {
+		it.row = nil
+		return false
+	}
+	r := it.merger.runs[0]
+	it.row = r.row
+	ok, err := r.next()
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if ok {
+		heap.Fix(it.merger, 0)
+	} else {
+		heap.Pop(it.merger)
+	}
+	return true
+}
+
+func (it *Iterator) Row() []cell.Value {
+	return it.row
+}
+
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// run is a sorted run spilled to a temporary file, row being its current row
+type run struct {
+	index int
+	r     *bufio.Reader
+	row   []cell.Value
+}
+
+// next reads the following row of the run, returning false at its end
+func (r *run) next() (bool, error) {
+	size, err := readUvarint(r.r)
+	if errors.Is(err, io.EOF) {
+		r.row = nil
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	record := make([]byte, size)
+	if _, err := io.ReadFull(r.r, record); err != nil {
+		return false, fmt.Errorf("sorted run is truncated: %w", err)
+	}
+
+	srs, err := cell.NewSerialTypeAndRecords(record, nil)
+	if err != nil {
+		return false, err
+	}
+	row := make([]cell.Value, len(srs))
+	for i, sr := range srs {
+		if row[i], err = sr.Value(); err != nil {
+			return false, err
+		}
+	}
+	r.row = row
+	return true, nil
+}
+
+func readUvarint(r io.ByteReader) (uint64, error) {
+	buf := make([]byte, 0, 9)
+	for len(buf) < 9 {
+		b, err := r.ReadByte()
+		if err != nil {
+			if len(buf) > 0 && errors.Is(err, io.EOF) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		buf = append(buf, b)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	v, _ := utils.Uvarint(buf)
+	return v, nil
+}
+
+// merger is a heap of runs ordered by their current rows, ties going to the earlier run to keep the sort stable
+type merger struct {
+	compare func(x, y []cell.Value) int
+	runs    []*run
+}
+
+func (m *merger) Len() int {
+	return len(m.runs)
+}
+
+func (m *merger) Less(i, j int) bool {
+	if c := m.compare(m.runs[i].row, m.runs[j].row); c != 0 {
+		return c < 0
+	}
+	return m.runs[i].index < m.runs[j].index
+}
+
+func (m *merger) Swap(i, j int) {
+	m.runs[i], m.runs[j] = m.runs[j], m.runs[i]
+}
+
+func (m *merger) Push(x any) {
+	m.runs = append(m.runs, x.(*run))
+}
+
+func (m *merger) Pop() any {
+	r := m.runs[len(m.runs)-1]
+	m.runs = m.runs[:len(m.runs)-1]
+	return r
+}
@@ -0,0 +1,84 @@
+package sorter
+
+import (
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"os"
+	"testing"
+)
+
+func TestSorter(t *testing.T) {
+	tests := []struct {
+		name        string
+		memoryLimit int
+		spills      bool
+	}{
+		{name: "in memory", memoryLimit: 0},
+		{name: "every row spilled", memoryLimit: 1, spills: true},
+		{name: "runs and rows in memory", memoryLimit: 1000, spills: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSorter(&NewSorterRequest{
+				Compare: func(x, y []cell.Value) int {
+					return int(x[0].Integer - y[0].Integer)
+				},
+				MemoryLimit: tt.memoryLimit,
+			})
+
+			// keys repeat so that the order of equal rows, given by their sequence number, checks the sort is stable
+			const count = 100
+			for i := 0; i < count; i++ {
+				row := []cell.Value{
+					cell.IntegerValue(int64(i * 7 % 10)),
+					cell.IntegerValue(int64(i)),
+					cell.TextValue(fmt.Sprintf("row %d", i)),
+				}
+				if err := s.Add(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if spills := len(s.runs) > 0; spills != tt.spills {
+				t.Fatalf("spilled = %v, want %v", spills, tt.spills)
+			}
+			runs := make([]string, len(s.runs))
+			for i, f := range s.runs {
+				runs[i] = f.Name()
+			}
+
+			it, err := s.Sort()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var prev []cell.Value
+			n := 0
+			for ; it.Next(); n++ {
+				row := it.Row()
+				if want := fmt.Sprintf("row %d", row[1].Integer); row[2].String() != want {
+					t.Errorf("row %d holds %q, want %q", n, row[2].String(), want)
+				}
+				if prev != nil && (prev[0].Integer > row[0].Integer ||
+					prev[0].Integer == row[0].Integer && prev[1].Integer > row[1].Integer) {
+					t.Errorf("row %v follows row %v", row[:2], prev[:2])
+				}
+				prev = row
+			}
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if n != count {
+				t.Errorf("read %d rows, want %d", n, count)
+			}
+
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range runs {
+				if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("run %s is left after Close: %v", name, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/eval"
+	"github/com/codecrafters-io/sqlite-starter-go/app/header"
+	"github/com/codecrafters-io/sqlite-starter-go/app/sorter"
+	"sort"
+	"strings"
+
+	"github.com/rqlite/sql"
+)
+
+// aggregateRow is a group of an aggregate query. Its bare columns are read from a row of the group.
+type aggregateRow struct {
+	row     eval.Row
+	results map[*sql.Call]cell.Value
+}
+
+var _ eval.AggregateRow = (*aggregateRow)(nil)
+
+func (r *aggregateRow) Column(table, column string) (cell.Value, eval.Affinity, eval.Collation, error) {
+	return r.row.Column(table, column)
+}
+
+func (r *aggregateRow) Encoding() header.TextEncoding {
+	return r.row.Encoding()
+}
+
+func (r *aggregateRow) Aggregate(call *sql.Call) (cell.Value, error) {
+	v, ok := r.results[call]
+	if !ok {
+		return cell.Value{}, fmt.Errorf("misuse of aggregate: %s()", call.Name.Name)
+	}
+	return v, nil
+}
+
+// group holds the aggregators of the rows sharing a GROUP BY key
+type group struct {
+	key         []cell.Value
+	aggregators []eval.Aggregator
+	row         eval.Row
+}
+
+// aggregateQuery is a SELECT computing aggregates, or grouping its rows by GROUP BY
+type aggregateQuery struct {
+	columns []*sql.ResultColumn
+	groupBy []sql.Expr
+	having  sql.Expr
+	calls   []*sql.Call
+	terms   []*orderingTerm
+	lo      *limitOffset
+	// groupCompare compare the GROUP BY keys and callCompare the arguments of the calls, with the collation of
+	// the columns they refer to
+	groupCompare []eval.Comparator
+	callCompare  []eval.Comparator
+}
+
+// newAggregateQuery returns nil when ss neither calls an aggregate function nor has a GROUP BY clause
+func newAggregateQuery(ss *sql.SelectStatement, terms []*orderingTerm, lo *limitOffset, compare keyComparator) (*aggregateQuery, error) {
+	exprs := make([]sql.Expr, 0, len(ss.Columns)+len(terms)+1)
+	for _, c := range ss.Columns {
+		exprs = append(exprs, c.Expr)
+	}
+	exprs = append(exprs, ss.HavingExpr)
+	for _, t := range terms {
+		exprs = append(exprs, t.expr)
+	}
+
+	calls, err := eval.AggregateCalls(exprs...)
+	if err != nil {
+		return nil, err
+	}
+	if len(calls) == 0 && len(ss.GroupByExprs) == 0 {
+		if ss.HavingExpr != nil {
+			return nil, errors.New("a GROUP BY clause is required before HAVING")
+		}
+		return nil, nil
+	}
+
+	groupBy := make([]sql.Expr, 0, len(ss.GroupByExprs))
+	groupCompare := make([]eval.Comparator, 0, len(ss.GroupByExprs))
+	for i, expr := range ss.GroupByExprs {
+		expr, err := resolveResultColumnRef(ss.Columns, expr, "GROUP BY", i)
+		if err != nil {
+			return nil, err
+		}
+		nested, err := eval.AggregateCalls(expr)
+		if err != nil {
+			return nil, err
+		}
+		if len(nested) > 0 {
+			return nil, errors.New("aggregate functions are not allowed in the GROUP BY clause")
+		}
+		c, err := compare(expr)
+		if err != nil {
+			return nil, err
+		}
+		groupBy, groupCompare = append(groupBy, expr), append(groupCompare, c)
+	}
+
+	callCompare := make([]eval.Comparator, len(calls))
+	for i, call := range calls {
+		if len(call.Args) == 0 {
+			continue
+		}
+		if callCompare[i], err = compare(call.Args[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range ss.Columns {
+		if c.Star.IsValid() || c.Expr == nil {
+			return nil, fmt.Errorf("result column is not supported: %s", c.String())
+		}
+	}
+
+	return &aggregateQuery{
+		columns:      ss.Columns,
+		groupBy:      groupBy,
+		having:       resolveAliases(ss.Columns, ss.HavingExpr),
+		calls:        calls,
+		terms:        terms,
+		lo:           lo,
+		groupCompare: groupCompare,
+		callCompare:  callCompare,
+	}, nil
+}
+
+// resolveAliases replaces the references to result column aliases in expr by the expressions they name, leaving
+// expr untouched when it has none. The arguments of aggregate calls are kept, as they are evaluated for every row.
+func resolveAliases(columns []*sql.ResultColumn, expr sql.Expr) sql.Expr {
+	resolve := func(expr sql.Expr) sql.Expr {
+		return resolveAliases(columns, expr)
+	}
+	resolveAll := func(exprs []sql.Expr) []sql.Expr {
+		resolved := make([]sql.Expr, len(exprs))
+		for i, expr := range exprs {
+			resolved[i] = resolve(expr)
+		}
+		return resolved
+	}
+
+	switch x := expr.(type) {
+	case *sql.Ident:
+		for _, c := range columns {
+			if c.Alias != nil && c.Expr != nil && strings.EqualFold(c.Alias.Name, x.Name) {
+				return c.Expr
+			}
+		}
+	case *sql.ParenExpr:
+		y := *x
+		y.X = resolve(x.X)
+		return &y
+	case *sql.UnaryExpr:
+		y := *x
+		y.X = resolve(x.X)
+		return &y
+	case *sql.BinaryExpr:
+		y := *x
+		y.X, y.Y = resolve(x.X), resolve(x.Y)
+		return &y
+	case *sql.ExprList:
+		y := *x
+		y.Exprs = resolveAll(x.Exprs)
+		return &y
+	case *sql.Range:
+		y := *x
+		y.X, y.Y = resolve(x.X), resolve(x.Y)
+		return &y
+	case *sql.CastExpr:
+		y := *x
+		y.X = resolve(x.X)
+		return &y
+	case *sql.CaseExpr:
+		y := *x
+		y.Operand, y.ElseExpr = resolve(x.Operand), resolve(x.ElseExpr)
+		y.Blocks = make([]*sql.CaseBlock, len(x.Blocks))
+		for i, b := range x.Blocks {
+			y.Blocks[i] = &sql.CaseBlock{When: b.When, Condition: resolve(b.Condition), Then: b.Then, Body: resolve(b.Body)}
+		}
+		return &y
+	case *sql.Call:
+		if eval.IsAggregateCall(x) {
+			return x
+		}
+		y := *x
+		y.Args = resolveAll(x.Args)
+		return &y
+	}
+	return expr
+}
+
+// selectAggregate reads the rows of src into groups, and returns a row per group passing HAVING
+func selectAggregate(src rowSource, aq *aggregateQuery, distinct []eval.Collation) (*Rows, error) {
+	// a query whose only aggregate is min() or max() reads its bare columns from the row holding the extreme value
+	_, selectsRow := newAggregatorOrNil(aq).(eval.RowSelector)
+
+	groups := make(map[string]*group)
+	err := scanRows(src, func(row eval.Row) (bool, error) {
+		var err error
+		// keys equal under their collation, like 'a' and 'A' with NOCASE, are the same group
+		key := make([]cell.Value, len(aq.groupBy))
+		folded := make([]cell.Value, len(aq.groupBy))
+		for i, expr := range aq.groupBy {
+			if key[i], err = eval.Eval(expr, row); err != nil {
+				return false, err
+			}
+			folded[i] = aq.groupCompare[i].Collation.Fold(key[i])
+		}
+
+		g, ok := groups[eval.DistinctKey(folded...)]
+		if !ok {
+			if g, err = newGroup(key, aq); err != nil {
+				return false, err
+			}
+			groups[eval.DistinctKey(folded...)] = g
+		}
+
+		for i, call := range aq.calls {
+			args := make([]cell.Value, len(call.Args))
+			for j, arg := range call.Args {
+				if args[j], err = eval.Eval(arg, row); err != nil {
+					return false, err
+				}
+			}
+			if err := g.aggregators[i].Step(args); err != nil {
+				return false, err
+			}
+		}
+
+		if !selectsRow || g.row == nil || g.aggregators[0].(eval.RowSelector).Selected() {
+			g.row = row
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// without GROUP BY the whole table is one group, even when it has no rows
+	if len(aq.groupBy) == 0 && len(groups) == 0 {
+		g, err := newGroup(nil, aq)
+		if err != nil {
+			return nil, err
+		}
+		g.row = src.emptyRow()
+		groups[""] = g
+	}
+
+	// groups come out in GROUP BY order
+	sortedGroups := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		sortedGroups = append(sortedGroups, g)
+	}
+	sort.Slice(sortedGroups, func(i, j int) bool {
+		for k := range sortedGroups[i].key {
+			if c := aq.groupCompare[k].Compare(sortedGroups[i].key[k], sortedGroups[j].key[k]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	s := sorter.NewSorter(&sorter.NewSorterRequest{
+		Compare: func(x, y []cell.Value) int {
+			return compareOrderingKeys(aq.terms, x, y)
+		},
+	})
+	if err := addGroups(s, sortedGroups, aq); err != nil {
+		return nil, errors.Join(err, s.Close())
+	}
+	return readSorted(s, len(aq.terms), distinct, aq.lo)
+}
+
+// addGroups adds the rows of the groups passing HAVING to s, made of their ORDER BY keys and result values
+func addGroups(s *sorter.Sorter, groups []*group, aq *aggregateQuery) error {
+	var err error
+	for _, g := range groups {
+		row := &aggregateRow{row: g.row, results: make(map[*sql.Call]cell.Value, len(aq.calls))}
+		for i, call := range aq.calls {
+			if row.results[call], err = g.aggregators[i].Final(); err != nil {
+				return err
+			}
+		}
+
+		if aq.having != nil {
+			v, err := eval.Eval(aq.having, row)
+			if err != nil {
+				return err
+			}
+			if !eval.IsTrue(v) {
+				continue
+			}
+		}
+
+		sortRow := make([]cell.Value, 0, len(aq.terms)+len(aq.columns))
+		for _, term := range aq.terms {
+			key, err := eval.Eval(term.expr, row)
+			if err != nil {
+				return err
+			}
+			sortRow = append(sortRow, key)
+		}
+		for _, c := range aq.columns {
+			v, err := eval.Eval(c.Expr, row)
+			if err != nil {
+				return err
+			}
+			sortRow = append(sortRow, v)
+		}
+		if err := s.Add(sortRow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newGroup(key []cell.Value, aq *aggregateQuery) (*group, error) {
+	aggregators := make([]eval.Aggregator, len(aq.calls))
+	for i, call := range aq.calls {
+		a, err := eval.NewAggregator(call, aq.callCompare[i])
+		if err != nil {
+			return nil, err
+		}
+		aggregators[i] = a
+	}
+	return &group{key: key, aggregators: aggregators}, nil
+}
+
+// newAggregatorOrNil returns the aggregator of the only call of aq, nil unless there is exactly one
+func newAggregatorOrNil(aq *aggregateQuery) eval.Aggregator {
+	if len(aq.calls) != 1 {
+		return nil
+	}
+	a, err := eval.NewAggregator(aq.calls[0], aq.callCompare[0])
+	if err != nil {
+		return nil
+	}
+	return a
+}
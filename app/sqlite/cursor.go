@@ -0,0 +1,210 @@
+package sqlite
+
+import (
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/header"
+	"github/com/codecrafters-io/sqlite-starter-go/app/page"
+	"math"
+)
+
+// tableCursor walks the cells of a table b-tree in row id order, holding a single leaf page of cells at a time
+type tableCursor struct {
+	db                 *sqlite
+	table              string
+	columnPosList      []int
+	autoIncrKeyPosList []int
+	where              *cell.Where
+	// byRowID restricts the cells to the row ids of the children being walked
+	byRowID bool
+	// stack holds the children left to walk of the interior pages on the path to the current leaf
+	stack [][]*cursorChild
+	cells cell.LeafTablePageCells
+}
+
+// cursorChild is a page to walk, rowIDs being the row ids looked up under it when the cursor walks by row id
+type cursorChild struct {
+	pageNum uint
+	rowIDs  []int
+}
+
+// tablePlan is how the rows of a ScanTable are read, worked out once for every cursor opened over them
+type tablePlan struct {
+	db    *sqlite
+	st    *ScanTable
+	where *cell.Where
+	// term is the WHERE term the rows are looked up by when st has no lookup, nil when every row is walked
+	term *lookupTerm
+}
+
+func (db *sqlite) newTablePlan(st *ScanTable) (*tablePlan, error) {
+	where, err := db.newWhere(st.Table, st.WhereExpr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &tablePlan{db: db, st: st, where: where}
+	if st.Lookup == nil {
+		if p.term, err = db.getLookupTerm(st.Table, st.WhereExpr); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// newTableCursor opens a cursor over the rows of st, looking them up through an index when one applies
+func (db *sqlite) newTableCursor(st *ScanTable) (*tableCursor, error) {
+	p, err := db.newTablePlan(st)
+	if err != nil {
+		return nil, err
+	}
+	return p.open()
+}
+
+// open opens a cursor over the rows, evaluating the key of the lookup term anew
+func (p *tablePlan) open() (*tableCursor, error) {
+	db, st := p.db, p.st
+	lookup := st.Lookup
+	if p.term != nil {
+		var err error
+		if lookup, err = p.term.lookup(); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &tableCursor{
+		db:                 db,
+		table:              st.Table,
+		columnPosList:      st.ColumnPosList,
+		autoIncrKeyPosList: st.AutoIncrKeyPosList,
+		where:              p.where,
+	}
+	if lookup == nil {
+		c.stack = [][]*cursorChild{{{pageNum: st.PageNum}}}
+		return c, nil
+	}
+
+	// the row ids of an index lookup are gathered first, the rows being read as the cursor moves
+	targetRowIDs := lookup.RowIDs
+	if lookup.Index != nil {
+		var err error
+		targetRowIDs, err = db.traverseInteriorIndexesToGetTargetRowIDs(&TraverseBTree{
+			PageNum:  uint(lookup.Index.PageNum),
+			Table:    st.Table,
+			IndexKey: lookup.Key,
+			Compare:  lookup.Compare,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.byRowID = true
+	if len(targetRowIDs) > 0 {
+		c.stack = [][]*cursorChild{{{pageNum: st.PageNum, rowIDs: targetRowIDs}}}
+	}
+	return c, nil
+}
+
+// next returns the following cell, nil once the b-tree is walked through
+func (c *tableCursor) next() (*cell.LeafTablePageCell, error) {
+	for len(c.cells) == 0 {
+		if len(c.stack) == 0 {
+			return nil, nil
+		}
+
+		top := len(c.stack) - 1
+		if len(c.stack[top]) == 0 {
+			c.stack = c.stack[:top]
+			continue
+		}
+		child := c.stack[top][0]
+		c.stack[top] = c.stack[top][1:]
+
+		if err := c.load(child); err != nil {
+			return nil, err
+		}
+	}
+
+	next := c.cells[0]
+	c.cells = c.cells[1:]
+	return next, nil
+}
+
+// load reads the matching cells of a leaf page, or pushes the children of an interior page
+func (c *tableCursor) load(child *cursorChild) error {
+	_, b, bhSize, err := c.db.bTreePage(child.pageNum)
+	if err != nil {
+		return err
+	}
+
+	if b.PageType == header.LeafTableBTree {
+		if c.byRowID {
+			c.cells, err = c.db.getLeafTablesToGetCellsByPK(&TraverseBTreeByPrimaryKey{
+				PageNum:            child.pageNum,
+				Table:              c.table,
+				ColumnPosList:      c.columnPosList,
+				AutoIncrKeyPosList: c.autoIncrKeyPosList,
+				PrimaryKeys:        child.rowIDs,
+				Where:              c.where,
+			})
+			return err
+		}
+
+		c.cells, err = c.db.getLeafTablePageCells(&TraverseBTree{
+			PageNum:            child.pageNum,
+			Table:              c.table,
+			ColumnPosList:      c.columnPosList,
+			AutoIncrKeyPosList: c.autoIncrKeyPosList,
+			Where:              c.where,
+		})
+		return err
+	}
+
+	ip, err := page.NewInteriorTable(c.db.pager, child.pageNum)
+	if err != nil {
+		return err
+	}
+
+	cells, err := cell.NewInteriorTablePageCells(&cell.NewInteriorTablePageCellRequest{
+		PageType:     ip.PageType,
+		Page:         ip.Data,
+		HeaderOffset: uint64(bhSize),
+		CellCount:    uint64(ip.BTreeHeader.CellCount),
+	})
+	if err != nil {
+		return err
+	}
+
+	children := make([]*cursorChild, 0, len(cells)+1)
+	if !c.byRowID {
+		for _, ic := range cells {
+			children = append(children, &cursorChild{pageNum: uint(ic.LeftChildPageNum)})
+		}
+		c.stack = append(c.stack, append(children, &cursorChild{pageNum: ip.RightMostPointer}))
+		return nil
+	}
+
+	// the left child of a cell holds the row ids greater than the previous cell's and lower than or equal to its own
+	previousKey := math.MinInt
+	for _, ic := range cells {
+		children = appendChildByRowID(children, uint(ic.LeftChildPageNum), child.rowIDs, previousKey, int(ic.RowID))
+		previousKey = int(ic.RowID)
+	}
+	children = appendChildByRowID(children, ip.RightMostPointer, child.rowIDs, previousKey, math.MaxInt)
+	c.stack = append(c.stack, children)
+	return nil
+}
+
+// appendChildByRowID appends the child holding the row ids in (lower, upper] unless none of rowIDs is among them
+func appendChildByRowID(children []*cursorChild, pageNum uint, rowIDs []int, lower, upper int) []*cursorChild {
+	childRowIDs := make([]int, 0)
+	for _, rowID := range rowIDs {
+		if lower < rowID && rowID <= upper {
+			childRowIDs = append(childRowIDs, rowID)
+		}
+	}
+	if len(childRowIDs) == 0 {
+		return children
+	}
+	return append(children, &cursorChild{pageNum: pageNum, rowIDs: childRowIDs})
+}
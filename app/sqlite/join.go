@@ -0,0 +1,525 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/eval"
+	"github/com/codecrafters-io/sqlite-starter-go/app/header"
+	"github/com/codecrafters-io/sqlite-starter-go/app/parser"
+	"github/com/codecrafters-io/sqlite-starter-go/app/schema"
+	"slices"
+	"strings"
+
+	"github.com/rqlite/sql"
+)
+
+// joinTable is a table of a FROM clause, joined to the tables before it by a nested loop
+type joinTable struct {
+	table string
+	// name is the alias of the table, or its name when it has none
+	name               string
+	pageNum            uint
+	columns            []*schema.Column
+	autoIncrKeyPosList []int
+	// left is set for a LEFT JOIN, whose rows without a match are joined to a row of NULLs
+	left bool
+	// on holds the terms of the join constraint, USING columns being turned into equality terms
+	on []sql.Expr
+	// using holds the columns of USING or NATURAL, which unqualified references resolve to the table on the left for
+	using []string
+	// where holds the terms of the WHERE clause that can be evaluated once the table is joined
+	where []sql.Expr
+	// lookups are the equality terms the rows of the table can be looked up through an index by
+	lookups []*joinLookup
+}
+
+// joinLookup looks the rows of a table up by the value of key, an expression on the tables joined before
+type joinLookup struct {
+	column *lookupColumn
+	key    sql.Expr
+}
+
+// join is the rowSource of a query reading several tables, or a single one referred to by an alias
+type join struct {
+	db     *sqlite
+	tables []*joinTable
+}
+
+var _ rowSource = (*join)(nil)
+
+func (db *sqlite) newJoin(source sql.Source, where sql.Expr) (*join, error) {
+	j := &join{db: db}
+	if err := j.addSource(source, nil, nil); err != nil {
+		return nil, err
+	}
+
+	for _, term := range parser.SplitConjunction(where) {
+		level, err := j.level(term)
+		if err != nil {
+			return nil, err
+		}
+		j.tables[level].where = append(j.tables[level].where, term)
+	}
+
+	for i, t := range j.tables {
+		terms := slices.Clone(t.on)
+		// for a LEFT JOIN the WHERE clause filters the rows once joined, so it cannot narrow down the rows to match
+		if !t.left {
+			terms = append(terms, t.where...)
+		}
+		lookups, err := j.newLookups(i, terms)
+		if err != nil {
+			return nil, err
+		}
+		t.lookups = lookups
+	}
+	return j, nil
+}
+
+// addSource appends the tables of source. The parser nests `a JOIN b JOIN c` as a join of a with `b JOIN c`,
+// the operator and constraint applying to a and b, so the first table of the right side gets them.
+func (j *join) addSource(source sql.Source, op *sql.JoinOperator, constraint sql.JoinConstraint) error {
+	switch s := source.(type) {
+	case nil:
+		return errors.New("no tables specified")
+	case *sql.QualifiedTableName:
+		return j.addTable(s, op, constraint)
+	case *sql.JoinClause:
+		if err := j.addSource(s.X, op, constraint); err != nil {
+			return err
+		}
+		return j.addSource(s.Y, s.Operator, s.Constraint)
+	default:
+		return fmt.Errorf("source is not supported: %s", source.String())
+	}
+}
+
+func (j *join) addTable(s *sql.QualifiedTableName, op *sql.JoinOperator, constraint sql.JoinConstraint) error {
+	table := s.Name.Name
+	for _, t := range j.tables {
+		if strings.EqualFold(t.name, s.TableName()) {
+			return fmt.Errorf("ambiguous table name: %s", s.TableName())
+		}
+	}
+
+	pageNum, err := j.db.PageNum(table)
+	if err != nil {
+		return err
+	}
+
+	columns, err := j.db.firstPage.SQLiteMasterRows.GetColumns(table)
+	if err != nil {
+		return err
+	}
+
+	autoIncrPrimaryKeys, err := j.db.firstPage.SQLiteMasterRows.RowIDAliasColumns(table)
+	if err != nil {
+		return err
+	}
+
+	autoIncrPrimaryKeyPosList, err := j.db.firstPage.SQLiteMasterRows.GetColumnPosList(table, autoIncrPrimaryKeys)
+	if err != nil {
+		return err
+	}
+
+	t := &joinTable{
+		table:              table,
+		name:               s.TableName(),
+		pageNum:            uint(pageNum),
+		columns:            columns,
+		autoIncrKeyPosList: autoIncrPrimaryKeyPosList,
+	}
+	if op != nil {
+		t.left = op.Left.IsValid()
+		if op.Natural.IsValid() {
+			if constraint != nil {
+				return errors.New("a NATURAL join may not have an ON or USING clause")
+			}
+			for _, c := range columns {
+				if _, _, err := j.resolve("", c.Name); err == nil {
+					t.using = append(t.using, c.Name)
+				}
+			}
+		}
+	}
+
+	switch c := constraint.(type) {
+	case *sql.OnConstraint:
+		t.on = parser.SplitConjunction(c.X)
+	case *sql.UsingConstraint:
+		for _, ident := range c.Columns {
+			t.using = append(t.using, ident.Name)
+		}
+	}
+
+	for _, name := range t.using {
+		left, _, err := j.resolve("", name)
+		if err != nil {
+			return fmt.Errorf("cannot join using column %s - column not present in both tables", name)
+		}
+		if !slices.ContainsFunc(columns, func(c *schema.Column) bool { return strings.EqualFold(c.Name, name) }) {
+			return fmt.Errorf("cannot join using column %s - column not present in both tables", name)
+		}
+		t.on = append(t.on, &sql.BinaryExpr{
+			X:  &sql.QualifiedRef{Table: &sql.Ident{Name: j.tables[left].name}, Column: &sql.Ident{Name: name}},
+			Op: sql.EQ,
+			Y:  &sql.QualifiedRef{Table: &sql.Ident{Name: t.name}, Column: &sql.Ident{Name: name}},
+		})
+	}
+
+	j.tables = append(j.tables, t)
+	return nil
+}
+
+// resolve returns the index of the table a column reference resolves to, along with the column, which is nil
+// for the row id
+func (j *join) resolve(table, column string) (int, *schema.Column, error) {
+	if table != "" {
+		for i, t := range j.tables {
+			if !strings.EqualFold(t.name, table) {
+				continue
+			}
+			for _, c := range t.columns {
+				if strings.EqualFold(c.Name, column) {
+					return i, c, nil
+				}
+			}
+			if isRowIDName(column) {
+				return i, nil, nil
+			}
+			break
+		}
+		return 0, nil, fmt.Errorf("%w: %s.%s", eval.ErrNoSuchColumn, table, column)
+	}
+
+	index := -1
+	var found *schema.Column
+	for i, t := range j.tables {
+		// a USING column is the column of the table on the left
+		if slices.ContainsFunc(t.using, func(name string) bool { return strings.EqualFold(name, column) }) {
+			continue
+		}
+		for _, c := range t.columns {
+			if !strings.EqualFold(c.Name, column) {
+				continue
+			}
+			if index >= 0 {
+				return 0, nil, fmt.Errorf("ambiguous column name: %s", column)
+			}
+			index, found = i, c
+		}
+	}
+	if index >= 0 {
+		return index, found, nil
+	}
+
+	if isRowIDName(column) {
+		if len(j.tables) > 1 {
+			return 0, nil, fmt.Errorf("ambiguous column name: %s", column)
+		}
+		return 0, nil, nil
+	}
+	return 0, nil, fmt.Errorf("%w: %s", eval.ErrNoSuchColumn, column)
+}
+
+// level returns the index of the last table expr refers to, after which expr can be evaluated
+func (j *join) level(expr sql.Expr) (int, error) {
+	refs, err := parser.ColumnRefs(expr)
+	if err != nil {
+		return 0, err
+	}
+
+	level := 0
+	for _, ref := range refs {
+		i, _, err := j.resolve(ref.Table, ref.Column)
+		// a double-quoted identifier that is not a column is a string literal
+		if errors.Is(err, eval.ErrNoSuchColumn) && ref.Table == "" && ref.Quoted {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		level = max(level, i)
+	}
+	return level, nil
+}
+
+// newLookups returns the terms of `column = expr` comparing a column the k-th table can be looked up by
+// with an expression on the tables before it
+func (j *join) newLookups(k int, terms []sql.Expr) ([]*joinLookup, error) {
+	lookups := make([]*joinLookup, 0)
+	for _, term := range terms {
+		be, ok := term.(*sql.BinaryExpr)
+		if !ok || be.Op != sql.EQ {
+			continue
+		}
+
+		// the index has to be sorted by the collation the terms compare with, that of their left column if any
+		collation, ok, err := exprCollation(be.X, j.resolveColumn)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if collation, _, err = exprCollation(be.Y, j.resolveColumn); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, sides := range [][2]sql.Expr{{be.X, be.Y}, {be.Y, be.X}} {
+			lookup, err := j.newLookup(k, sides[0], sides[1], collation)
+			if err != nil {
+				return nil, err
+			}
+			if lookup != nil {
+				lookups = append(lookups, lookup)
+				break
+			}
+		}
+	}
+	return lookups, nil
+}
+
+func (j *join) newLookup(k int, column, key sql.Expr, collation eval.Collation) (*joinLookup, error) {
+	var table, name string
+	switch c := column.(type) {
+	case *sql.Ident:
+		name = c.Name
+	case *sql.QualifiedRef:
+		if c.Star.IsValid() {
+			return nil, nil
+		}
+		table, name = c.Table.Name, c.Column.Name
+	default:
+		return nil, nil
+	}
+
+	i, _, err := j.resolve(table, name)
+	if err != nil || i != k {
+		return nil, nil
+	}
+
+	refs, err := parser.ColumnRefs(key)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		i, _, err := j.resolve(ref.Table, ref.Column)
+		if err != nil || i >= k {
+			return nil, nil
+		}
+	}
+
+	lc := j.db.getLookupColumn(j.tables[k].table, j.tables[k].columns, name)
+	if lc == nil || lc.index != nil && lc.compare.Collation != collation {
+		return nil, nil
+	}
+	return &joinLookup{column: lc, key: key}, nil
+}
+
+// lookup returns the lookup of the rows of the k-th table matching row, nil when they have to be scanned
+func (j *join) lookup(k int, row *joinRow) (*indexLookup, error) {
+	for _, l := range j.tables[k].lookups {
+		key, affinity, err := eval.EvalWithAffinity(l.key, row)
+		if err != nil {
+			return nil, err
+		}
+		// the index holds the column values as stored, which cannot be used when the comparison converts them
+		if affinity.IsNumeric() && !l.column.affinity.IsNumeric() ||
+			affinity == eval.AffinityText && l.column.affinity == eval.AffinityNone {
+			continue
+		}
+		return l.column.lookup(key), nil
+	}
+	return nil, nil
+}
+
+func (j *join) rows() (rowCursor, error) {
+	return &joinCursor{join: j, row: &joinRow{join: j}, levels: []*joinLevel{{}}}, nil
+}
+
+// joinCursor joins the rows of the tables by nested loops, the cursor of each table being reopened for every
+// row of the tables before it
+type joinCursor struct {
+	join *join
+	// row holds the current row of each table up to the last level
+	row    *joinRow
+	levels []*joinLevel
+	done   bool
+}
+
+// joinLevel is the state of the loop over the rows of a table
+type joinLevel struct {
+	cursor  *tableCursor
+	matched bool
+	// exhausted is set once the cursor is read through, a LEFT JOIN without a match joining a row of NULLs then
+	exhausted bool
+}
+
+func (c *joinCursor) next() (eval.Row, error) {
+	k := len(c.levels) - 1
+	for !c.done {
+		ok, err := c.advance(k)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case !ok && k == 0:
+			c.done = true
+		case !ok:
+			c.levels = c.levels[:k]
+			k--
+		case k == len(c.join.tables)-1:
+			return &joinRow{join: c.join, rows: slices.Clone(c.row.rows)}, nil
+		default:
+			c.levels = append(c.levels, &joinLevel{})
+			k++
+		}
+	}
+	return nil, nil
+}
+
+// advance moves the k-th table to its following row joined to the rows of the tables before it and passing
+// the WHERE terms evaluated at its level, returning false once there is none
+func (c *joinCursor) advance(k int) (bool, error) {
+	t, l := c.join.tables[k], c.levels[k]
+	if l.cursor == nil && !l.exhausted {
+		lookup, err := c.join.lookup(k, c.row)
+		if err != nil {
+			return false, err
+		}
+		l.cursor, err = c.join.db.newTableCursor(&ScanTable{
+			PageNum:            t.pageNum,
+			Table:              t.table,
+			AutoIncrKeyPosList: t.autoIncrKeyPosList,
+			Lookup:             lookup,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for !l.exhausted {
+		next, err := l.cursor.next()
+		if err != nil {
+			return false, err
+		}
+		if next == nil {
+			l.exhausted = true
+			break
+		}
+
+		tr, err := newTableRow(t.name, t.columns, c.join.db.firstPage.TextEncoding, next)
+		if err != nil {
+			return false, err
+		}
+		c.row.rows = append(c.row.rows[:k], tr)
+
+		ok, err := allTrue(t.on, c.row)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			continue
+		}
+		l.matched = true
+
+		if ok, err := allTrue(t.where, c.row); err != nil || ok {
+			return ok, err
+		}
+	}
+
+	if !t.left || l.matched {
+		return false, nil
+	}
+	l.matched = true
+	c.row.rows = append(c.row.rows[:k], nil)
+	return allTrue(t.where, c.row)
+}
+
+func allTrue(exprs []sql.Expr, row eval.Row) (bool, error) {
+	for _, expr := range exprs {
+		v, err := eval.Eval(expr, row)
+		if err != nil {
+			return false, err
+		}
+		if !eval.IsTrue(v) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (j *join) emptyRow() eval.Row {
+	return &joinRow{join: j, rows: make([]*tableRow, len(j.tables))}
+}
+
+// expandStar is the starExpander of the join, * leaving the USING columns of the tables on the right out
+func (j *join) expandStar(table string) ([]sql.Expr, error) {
+	if table == "" {
+		refs := make([]sql.Expr, 0)
+		for _, t := range j.tables {
+			refs = append(refs, t.columnRefs(true)...)
+		}
+		return refs, nil
+	}
+
+	k := slices.IndexFunc(j.tables, func(t *joinTable) bool { return strings.EqualFold(t.name, table) })
+	if k < 0 {
+		return nil, fmt.Errorf("no such table: %s", table)
+	}
+	return j.tables[k].columnRefs(false), nil
+}
+
+// resolveColumn is the columnResolver of the join
+func (j *join) resolveColumn(table, column string) (string, *schema.Column, error) {
+	i, _, err := j.resolve(table, column)
+	if err != nil {
+		return "", nil, err
+	}
+	c, err := findColumn(j.tables[i].columns, column)
+	return j.tables[i].table, c, err
+}
+
+// columnRefs returns references to the columns of t, without its USING columns when skipUsing is set
+func (t *joinTable) columnRefs(skipUsing bool) []sql.Expr {
+	columns := t.columns
+	if skipUsing {
+		columns = slices.DeleteFunc(slices.Clone(columns), func(c *schema.Column) bool {
+			return slices.ContainsFunc(t.using, func(name string) bool { return strings.EqualFold(name, c.Name) })
+		})
+	}
+	return columnRefs(t.name, columns)
+}
+
+// joinRow holds a row of each joined table, nil for the row of NULLs of a LEFT JOIN without a match
+type joinRow struct {
+	join *join
+	rows []*tableRow
+}
+
+var _ eval.Row = (*joinRow)(nil)
+
+func (r *joinRow) Encoding() header.TextEncoding {
+	return r.join.db.firstPage.TextEncoding
+}
+
+func (r *joinRow) Column(table, column string) (cell.Value, eval.Affinity, eval.Collation, error) {
+	i, c, err := r.join.resolve(table, column)
+	if err != nil {
+		return noColumn(err)
+	}
+	if i >= len(r.rows) {
+		return noColumn(errors.New("ON clause references tables to its right"))
+	}
+
+	if r.rows[i] == nil {
+		if c == nil {
+			return cell.NullValue(), eval.AffinityInteger, eval.CollationBinary, nil
+		}
+		collation, err := eval.ParseCollation(c.Collation)
+		return cell.NullValue(), eval.AffinityFromType(c.Type), collation, err
+	}
+	return r.rows[i].Column("", column)
+}
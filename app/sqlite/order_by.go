@@ -0,0 +1,173 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/eval"
+	"strconv"
+	"strings"
+
+	"github.com/rqlite/sql"
+)
+
+// orderingTerm is an ORDER BY term whose expression is resolved against the result columns of its query
+type orderingTerm struct {
+	expr       sql.Expr
+	desc       bool
+	nullsFirst bool
+	compare    eval.Comparator
+}
+
+// keyComparator returns the comparator of the values of expr, used as a sort or grouping key
+type keyComparator func(expr sql.Expr) (eval.Comparator, error)
+
+// keyComparator compares the values of an expression with the collation of the column resolve resolves it to
+func (db *sqlite) keyComparator(resolve columnResolver) keyComparator {
+	return func(expr sql.Expr) (eval.Comparator, error) {
+		c := db.comparator()
+		var err error
+		c.Collation, _, err = exprCollation(expr, resolve)
+		return c, err
+	}
+}
+
+// newOrderingTerms returns the ORDER BY terms of ss, whose keys are ordered by compare
+func newOrderingTerms(ss *sql.SelectStatement, compare keyComparator) ([]*orderingTerm, error) {
+	terms := make([]*orderingTerm, 0, len(ss.OrderingTerms))
+	for i, ot := range ss.OrderingTerms {
+		expr, err := resolveResultColumnRef(ss.Columns, ot.X, "ORDER BY", i)
+		if err != nil {
+			return nil, err
+		}
+
+		// NULL is the smallest value, so it comes first in ascending order unless told otherwise
+		desc := ot.Desc.IsValid()
+		nullsFirst := !desc
+		if ot.NullsFirst.IsValid() {
+			nullsFirst = true
+		} else if ot.NullsLast.IsValid() {
+			nullsFirst = false
+		}
+
+		c, err := compare(expr)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, &orderingTerm{
+			expr:       expr,
+			desc:       desc,
+			nullsFirst: nullsFirst,
+			compare:    c,
+		})
+	}
+	return terms, nil
+}
+
+// resolveResultColumnRef resolves an ORDER BY or GROUP BY term being a result column number or alias to the result
+// column's expression, the term itself being evaluated against the row otherwise
+func resolveResultColumnRef(columns []*sql.ResultColumn, expr sql.Expr, clause string, termIndex int) (sql.Expr, error) {
+	switch e := expr.(type) {
+	case *sql.NumberLit:
+		n, err := strconv.Atoi(e.Value)
+		if err != nil {
+			return expr, nil
+		}
+		if n < 1 || n > len(columns) {
+			return nil, fmt.Errorf("%s %s term out of range - should be between 1 and %d", ordinal(termIndex+1), clause, len(columns))
+		}
+		if columns[n-1].Expr == nil {
+			return nil, fmt.Errorf("%s term is not supported: %s", clause, expr.String())
+		}
+		return columns[n-1].Expr, nil
+	case *sql.Ident:
+		for _, c := range columns {
+			if c.Alias != nil && strings.EqualFold(c.Alias.Name, e.Name) {
+				return c.Expr, nil
+			}
+		}
+	}
+	return expr, nil
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// compareOrderingKeys compares rows by the keys of terms they start with
+func compareOrderingKeys(terms []*orderingTerm, x, y []cell.Value) int {
+	for i, t := range terms {
+		if x[i].IsNull() != y[i].IsNull() {
+			if x[i].IsNull() == t.nullsFirst {
+				return -1
+			}
+			return 1
+		}
+
+		c := t.compare.Compare(x[i], y[i])
+		if t.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// limitOffset holds the LIMIT and OFFSET of a query, limit being negative when rows are not limited
+type limitOffset struct {
+	limit  int64
+	offset int64
+}
+
+func newLimitOffset(ss *sql.SelectStatement) (*limitOffset, error) {
+	lo := &limitOffset{limit: -1}
+	if ss.LimitExpr == nil {
+		return lo, nil
+	}
+
+	// LIMIT x, y means LIMIT y OFFSET x
+	limitExpr, offsetExpr := ss.LimitExpr, ss.OffsetExpr
+	if ss.OffsetComma.IsValid() {
+		limitExpr, offsetExpr = offsetExpr, limitExpr
+	}
+
+	limit, err := evalLimitTerm(limitExpr)
+	if err != nil {
+		return nil, err
+	}
+	lo.limit = limit
+
+	if offsetExpr != nil {
+		offset, err := evalLimitTerm(offsetExpr)
+		if err != nil {
+			return nil, err
+		}
+		lo.offset = max(offset, 0)
+	}
+	return lo, nil
+}
+
+func evalLimitTerm(expr sql.Expr) (int64, error) {
+	v, err := eval.Eval(expr, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	v = eval.AffinityInteger.Apply(v)
+	if v.Type != cell.ValueTypeInteger {
+		return 0, errors.New("datatype mismatch")
+	}
+	return v.Integer, nil
+}
@@ -0,0 +1,531 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/header"
+	"github/com/codecrafters-io/sqlite-starter-go/app/pager"
+	"github/com/codecrafters-io/sqlite-starter-go/app/parser"
+	"github/com/codecrafters-io/sqlite-starter-go/app/schema"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rqlite/sql"
+)
+
+var errReadOnly = errors.New("attempt to write a readonly database")
+
+// pragmaResult is the result of a PRAGMA, which is read out of the schema and the header at once
+type pragmaResult struct {
+	columns []string
+	rows    [][]cell.Value
+}
+
+// pragmaFunc runs a PRAGMA taking arg, empty when it is given none
+type pragmaFunc func(db *sqlite, arg string) (*pragmaResult, error)
+
+// pragmas are the PRAGMAs reading the schema, the database list and the compile options
+var pragmas = map[string]pragmaFunc{
+	"table_info":       tableInfo(false),
+	"table_xinfo":      tableInfo(true),
+	"index_list":       indexList,
+	"index_info":       indexInfo(false),
+	"index_xinfo":      indexInfo(true),
+	"foreign_key_list": foreignKeyList,
+	"database_list":    databaseList,
+	"compile_options":  compileOptions,
+}
+
+// valuePragmas are the PRAGMAs reading a single value, which setting is not possible in a read-only database
+var valuePragmas = map[string]func(db *sqlite) cell.Value{
+	"page_size": func(db *sqlite) cell.Value {
+		return cell.IntegerValue(int64(db.pageSize))
+	},
+	"page_count": func(db *sqlite) cell.Value {
+		return cell.IntegerValue(int64(db.pager.PageCount()))
+	},
+	"freelist_count": func(db *sqlite) cell.Value {
+		return cell.IntegerValue(int64(db.firstPage.FreelistPageCount))
+	},
+	"encoding": func(db *sqlite) cell.Value {
+		return cell.TextValue(encodingName(db.firstPage.TextEncoding))
+	},
+	"user_version": func(db *sqlite) cell.Value {
+		return cell.IntegerValue(int64(int32(db.firstPage.UserVersion)))
+	},
+	"application_id": func(db *sqlite) cell.Value {
+		return cell.IntegerValue(int64(int32(db.firstPage.ApplicationID)))
+	},
+	"schema_version": func(db *sqlite) cell.Value {
+		return cell.IntegerValue(int64(int32(db.firstPage.SchemaCookie)))
+	},
+}
+
+// preparePragma plans a PRAGMA statement. An unknown PRAGMA, or one about a missing table or index, has no rows
+// as in SQLite.
+func (db *sqlite) preparePragma(q string) (*Stmt, error) {
+	p, err := parser.NewPragma(q)
+	if err != nil {
+		return nil, err
+	}
+	if p.Schema != "" && !strings.EqualFold(p.Schema, "main") {
+		return nil, fmt.Errorf("unknown database %s", p.Schema)
+	}
+
+	result := &pragmaResult{}
+	if value, ok := valuePragmas[p.Name]; ok {
+		if p.Arg != "" {
+			return nil, errReadOnly
+		}
+		result.columns = []string{p.Name}
+		result.rows = [][]cell.Value{{value(db)}}
+	} else if run, ok := pragmas[p.Name]; ok {
+		if result, err = run(db, p.Arg); err != nil {
+			return nil, err
+		}
+	}
+
+	ss := &sql.SelectStatement{}
+	params, err := parser.NewParams(ss)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stmt{
+		db:      db,
+		ss:      ss,
+		params:  params,
+		columns: make([]*Column, len(result.columns)),
+		lo:      &limitOffset{limit: -1},
+	}
+	for i, name := range result.columns {
+		s.columns[i] = &Column{Name: name}
+	}
+	s.execute = func() (*Rows, error) {
+		return newRows(valueRows(result.rows), nil), nil
+	}
+	return s, nil
+}
+
+// encodingName returns the name of the text encoding as PRAGMA encoding reports it
+func encodingName(e header.TextEncoding) string {
+	switch e {
+	case header.TextEncodingUTF16LE:
+		return "UTF-16le"
+	case header.TextEncodingUTF16BE:
+		return "UTF-16be"
+	default:
+		return "UTF-8"
+	}
+}
+
+// tableInfo returns table_info, or table_xinfo with the hidden generated columns when xinfo is set
+func tableInfo(xinfo bool) pragmaFunc {
+	return func(db *sqlite, arg string) (*pragmaResult, error) {
+		result := &pragmaResult{columns: []string{"cid", "name", "type", "notnull", "dflt_value", "pk"}}
+		if xinfo {
+			result.columns = append(result.columns, "hidden")
+		}
+
+		t, err := db.parseTable(arg)
+		if t == nil || err != nil {
+			return result, err
+		}
+
+		hidden := 0
+		for i, c := range t.Columns {
+			kind := int64(0)
+			switch c.Generated {
+			case "VIRTUAL":
+				kind = 2
+			case "STORED":
+				kind = 3
+			}
+			if kind != 0 && !xinfo {
+				hidden++
+				continue
+			}
+
+			dflt := cell.NullValue()
+			if c.Default != "" {
+				dflt = cell.TextValue(c.Default)
+			}
+			row := []cell.Value{
+				cell.IntegerValue(int64(i - hidden)),
+				cell.TextValue(c.Name),
+				cell.TextValue(declaredType(c.Type)),
+				boolValue(c.NotNull),
+				dflt,
+				cell.IntegerValue(int64(c.KeyPos)),
+			}
+			if xinfo {
+				row = append(row, cell.IntegerValue(kind))
+			}
+			result.rows = append(result.rows, row)
+		}
+		return result, nil
+	}
+}
+
+// standardTypes are the types SQLite reports in upper case whatever the case they are declared in
+var standardTypes = []string{"ANY", "BLOB", "INT", "INTEGER", "REAL", "TEXT"}
+
+func declaredType(t string) string {
+	if slices.Contains(standardTypes, strings.ToUpper(t)) {
+		return strings.ToUpper(t)
+	}
+	return t
+}
+
+func boolValue(b bool) cell.Value {
+	if b {
+		return cell.IntegerValue(1)
+	}
+	return cell.IntegerValue(0)
+}
+
+// parseTable returns the definition of table, nil when there is no such table
+func (db *sqlite) parseTable(table string) (*schema.Table, error) {
+	r, err := db.firstPage.SQLiteMasterRows.GetTable(table)
+	if err != nil {
+		return nil, nil
+	}
+	return schema.ParseTable(r.SQL)
+}
+
+// pragmaIndex is an index of a table as index_list and index_xinfo describe it
+type pragmaIndex struct {
+	name   string
+	table  string
+	unique bool
+	// origin is "c" for an index created by CREATE INDEX, "u" for a UNIQUE constraint and "pk" for a PRIMARY KEY
+	origin  string
+	partial bool
+	columns []*pragmaIndexColumn
+}
+
+// pragmaIndexColumn is a column of an index, expr being set for an expression
+type pragmaIndexColumn struct {
+	name      string
+	expr      bool
+	desc      bool
+	collation string
+}
+
+// tableIndexes returns the indexes of table, the PRIMARY KEY of a WITHOUT ROWID table, which is the table
+// itself, included
+func (db *sqlite) tableIndexes(table string, t *schema.Table) ([]*pragmaIndex, error) {
+	indexes := make([]*pragmaIndex, 0)
+	for _, r := range db.firstPage.SQLiteMasterRows {
+		if r.ObjectType != schema.ObjectTypeIndex || !strings.EqualFold(r.TableName, table) {
+			continue
+		}
+		index, err := db.newPragmaIndex(r, t)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+
+	if !t.WithoutRowID {
+		return indexes, nil
+	}
+	if r, err := db.firstPage.SQLiteMasterRows.GetTable(table); err == nil {
+		table = r.TableName
+	}
+	for n, k := range t.AutoIndexKeys() {
+		if !k.PrimaryKey {
+			continue
+		}
+		index := newAutoIndex(autoIndexName(table, n+1), table, t, k)
+		// autoindexes are created in the order of their keys
+		pos := slices.IndexFunc(indexes, func(i *pragmaIndex) bool {
+			return i.origin == "c" || autoIndexNumber(i.name) > n+1
+		})
+		if pos < 0 {
+			pos = len(indexes)
+		}
+		indexes = slices.Insert(indexes, pos, index)
+	}
+	return indexes, nil
+}
+
+// index returns the index named name, nil when there is no such index
+func (db *sqlite) index(name string) (*pragmaIndex, error) {
+	for _, r := range db.firstPage.SQLiteMasterRows {
+		if r.ObjectType == schema.ObjectTypeIndex && strings.EqualFold(r.Name, name) {
+			t, err := db.parseTable(r.TableName)
+			if t == nil || err != nil {
+				return nil, err
+			}
+			return db.newPragmaIndex(r, t)
+		}
+	}
+
+	// the PRIMARY KEY of a WITHOUT ROWID table has no row in sqlite_schema
+	for _, r := range db.firstPage.SQLiteMasterRows {
+		if r.ObjectType != schema.ObjectTypeTable {
+			continue
+		}
+		n := autoIndexNumber(name)
+		if n == 0 || !strings.EqualFold(name, autoIndexName(r.TableName, n)) {
+			continue
+		}
+		t, err := db.parseTable(r.TableName)
+		if t == nil || err != nil {
+			return nil, err
+		}
+		keys := t.AutoIndexKeys()
+		if t.WithoutRowID && n <= len(keys) && keys[n-1].PrimaryKey {
+			return newAutoIndex(name, r.TableName, t, keys[n-1]), nil
+		}
+	}
+	return nil, nil
+}
+
+// newPragmaIndex describes the index r of the table t
+func (db *sqlite) newPragmaIndex(r *schema.SQLiteMasterRow, t *schema.Table) (*pragmaIndex, error) {
+	// an index without SQL is created for a UNIQUE or PRIMARY KEY constraint
+	if r.SQL == "" {
+		n := autoIndexNumber(r.Name)
+		keys := t.AutoIndexKeys()
+		if n == 0 || n > len(keys) {
+			return nil, fmt.Errorf("no constraint found for index %s", r.Name)
+		}
+		return newAutoIndex(r.Name, r.TableName, t, keys[n-1]), nil
+	}
+
+	stmt, err := parser.NewStatement(r.SQL)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := stmt.(*sql.CreateIndexStatement)
+	if !ok {
+		return nil, fmt.Errorf("invalid index statement type: %T", stmt)
+	}
+
+	index := &pragmaIndex{
+		name:    r.Name,
+		table:   r.TableName,
+		unique:  s.Unique.IsValid(),
+		origin:  "c",
+		partial: s.WhereExpr != nil,
+		columns: make([]*pragmaIndexColumn, len(s.Columns)),
+	}
+	for i, ic := range s.Columns {
+		c := &pragmaIndexColumn{desc: ic.Desc.IsValid()}
+		if ident, ok := ic.X.(*sql.Ident); ok {
+			c.name = ident.Name
+		} else {
+			c.expr = true
+		}
+		if ic.Collation != nil {
+			c.collation = ic.Collation.Name
+		}
+		index.columns[i] = c
+	}
+	return index, nil
+}
+
+// newAutoIndex describes the index named name SQLite creates for the key k of the table t
+func newAutoIndex(name, table string, t *schema.Table, k *schema.KeyConstraint) *pragmaIndex {
+	index := &pragmaIndex{
+		name:    name,
+		table:   table,
+		unique:  true,
+		origin:  "u",
+		columns: make([]*pragmaIndexColumn, len(k.Columns)),
+	}
+	if k.PrimaryKey {
+		index.origin = "pk"
+	}
+	for i, c := range k.Columns {
+		index.columns[i] = &pragmaIndexColumn{name: c.Name, desc: c.Desc, collation: c.Collation}
+	}
+	return index
+}
+
+func autoIndexName(table string, n int) string {
+	return fmt.Sprintf("sqlite_autoindex_%s_%d", table, n)
+}
+
+// autoIndexNumber returns N of the name sqlite_autoindex_<table>_N, 0 for the name of another index
+func autoIndexNumber(name string) int {
+	if !strings.HasPrefix(name, "sqlite_autoindex_") {
+		return 0
+	}
+	n, err := strconv.Atoi(name[strings.LastIndex(name, "_")+1:])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func indexList(db *sqlite, arg string) (*pragmaResult, error) {
+	result := &pragmaResult{columns: []string{"seq", "name", "unique", "origin", "partial"}}
+	t, err := db.parseTable(arg)
+	if t == nil || err != nil {
+		return result, err
+	}
+
+	indexes, err := db.tableIndexes(arg, t)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite lists the indexes created last first
+	slices.Reverse(indexes)
+	for i, index := range indexes {
+		result.rows = append(result.rows, []cell.Value{
+			cell.IntegerValue(int64(i)),
+			cell.TextValue(index.name),
+			boolValue(index.unique),
+			cell.TextValue(index.origin),
+			boolValue(index.partial),
+		})
+	}
+	return result, nil
+}
+
+// indexInfo returns index_info, or index_xinfo with the columns following the key when xinfo is set,
+// i.e. the row id or the PRIMARY KEY of a WITHOUT ROWID table
+func indexInfo(xinfo bool) pragmaFunc {
+	return func(db *sqlite, arg string) (*pragmaResult, error) {
+		result := &pragmaResult{columns: []string{"seqno", "cid", "name"}}
+		if xinfo {
+			result.columns = append(result.columns, "desc", "coll", "key")
+		}
+
+		index, err := db.index(arg)
+		if index == nil || err != nil {
+			return result, err
+		}
+		t, err := db.parseTable(index.table)
+		if t == nil || err != nil {
+			return result, err
+		}
+
+		columns := slices.Clone(index.columns)
+		if xinfo {
+			columns = append(columns, auxColumns(t, index)...)
+		}
+		for i, c := range columns {
+			key := i < len(index.columns)
+			if !key && !xinfo {
+				break
+			}
+
+			cid, name, collation := int64(-1), cell.NullValue(), c.collation
+			switch pos := columnIndex(t, c.name); {
+			case c.expr:
+				cid = -2
+			case pos >= 0:
+				cid, name = int64(pos), cell.TextValue(t.Columns[pos].Name)
+				if collation == "" {
+					collation = t.Columns[pos].Collation
+				}
+			}
+			if collation == "" {
+				collation = "BINARY"
+			}
+
+			row := []cell.Value{cell.IntegerValue(int64(i)), cell.IntegerValue(cid), name}
+			if xinfo {
+				row = append(row, boolValue(c.desc), cell.TextValue(collation), boolValue(key))
+			}
+			result.rows = append(result.rows, row)
+		}
+		return result, nil
+	}
+}
+
+// auxColumns returns the columns an index holds after its key: the row id, or for a WITHOUT ROWID table the
+// PRIMARY KEY columns not in the key, every other column following the key of the PRIMARY KEY itself
+func auxColumns(t *schema.Table, index *pragmaIndex) []*pragmaIndexColumn {
+	if !t.WithoutRowID {
+		return []*pragmaIndexColumn{{}}
+	}
+
+	inKey := func(name string) bool {
+		return slices.ContainsFunc(index.columns, func(c *pragmaIndexColumn) bool {
+			return !c.expr && strings.EqualFold(c.name, name)
+		})
+	}
+	columns := make([]*pragmaIndexColumn, 0)
+	if index.origin == "pk" {
+		for _, c := range t.Columns {
+			if !inKey(c.Name) {
+				columns = append(columns, &pragmaIndexColumn{name: c.Name})
+			}
+		}
+		return columns
+	}
+	for _, k := range t.PrimaryKey {
+		if !inKey(k.Name) {
+			columns = append(columns, &pragmaIndexColumn{name: k.Name, desc: k.Desc, collation: k.Collation})
+		}
+	}
+	return columns
+}
+
+// columnIndex returns the position of the column named name in t, -1 when there is none
+func columnIndex(t *schema.Table, name string) int {
+	if name == "" {
+		return -1
+	}
+	return slices.IndexFunc(t.Columns, func(c *schema.Column) bool {
+		return strings.EqualFold(c.Name, name)
+	})
+}
+
+func foreignKeyList(db *sqlite, arg string) (*pragmaResult, error) {
+	result := &pragmaResult{
+		columns: []string{"id", "seq", "table", "from", "to", "on_update", "on_delete", "match"},
+	}
+	t, err := db.parseTable(arg)
+	if t == nil || err != nil {
+		return result, err
+	}
+
+	// SQLite numbers the foreign keys declared last first
+	for i := range t.ForeignKeys {
+		fk := t.ForeignKeys[len(t.ForeignKeys)-1-i]
+		for seq, from := range fk.From {
+			to := cell.NullValue()
+			if seq < len(fk.To) {
+				to = cell.TextValue(fk.To[seq])
+			}
+			result.rows = append(result.rows, []cell.Value{
+				cell.IntegerValue(int64(i)),
+				cell.IntegerValue(int64(seq)),
+				cell.TextValue(fk.Table),
+				cell.TextValue(from),
+				to,
+				cell.TextValue(fk.OnUpdate),
+				cell.TextValue(fk.OnDelete),
+				cell.TextValue("NONE"),
+			})
+		}
+	}
+	return result, nil
+}
+
+func databaseList(db *sqlite, _ string) (*pragmaResult, error) {
+	return &pragmaResult{
+		columns: []string{"seq", "name", "file"},
+		rows:    [][]cell.Value{{cell.IntegerValue(0), cell.TextValue("main"), cell.TextValue(db.path)}},
+	}, nil
+}
+
+func compileOptions(_ *sqlite, _ string) (*pragmaResult, error) {
+	options := []string{
+		fmt.Sprintf("DEFAULT_CACHE_SIZE=%d", pager.DefaultCacheSize),
+		"MAX_PAGE_SIZE=65536",
+	}
+	result := &pragmaResult{columns: []string{"compile_options"}}
+	for _, o := range options {
+		result.rows = append(result.rows, []cell.Value{cell.TextValue(o)})
+	}
+	return result, nil
+}
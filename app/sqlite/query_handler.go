@@ -4,10 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/eval"
 	"github/com/codecrafters-io/sqlite-starter-go/app/header"
 	"github/com/codecrafters-io/sqlite-starter-go/app/page"
-	"github/com/codecrafters-io/sqlite-starter-go/app/parser"
-	"strings"
+	"github/com/codecrafters-io/sqlite-starter-go/app/sorter"
 	"sync"
 
 	"github.com/rqlite/sql"
@@ -17,258 +17,227 @@ var mu sync.Mutex
 
 type SQLite interface {
 	Count(query string, args ...any) (int, error)
-	Select(query string, args ...any) (cell.LeafTablePageCells, error)
+	Select(query string, args ...any) (*ResultSet, error)
+	Query(query string, args ...any) (*Rows, error)
+	Prepare(query string) (*Stmt, error)
 }
 
 var _ SQLite = (*sqlite)(nil)
 
 func (db *sqlite) Count(q string, args ...any) (int, error) {
-	stmt, err := parser.NewStatement(q)
+	s, err := db.Prepare(q)
 	if err != nil {
 		return 0, err
 	}
+	if !s.isCount {
+		return 0, fmt.Errorf("invalid count statement: %s", q)
+	}
+	return s.Count(args...)
+}
 
-	ss, err := parser.NewSelectStatement(stmt)
+// Select reads every row of a query into a result set describing its columns
+func (db *sqlite) Select(q string, args ...any) (*ResultSet, error) {
+	s, err := db.Prepare(q)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	return s.Select(args...)
+}
 
-	table := strings.ReplaceAll(ss.Source.String(), `"`, "")
-	where := ss.WhereExpr
-
-	isCountStmt, err := parser.IsCountStatement(q, stmt)
+// Query runs a SELECT statement, returning Rows read from the database as they are iterated over
+func (db *sqlite) Query(q string, args ...any) (*Rows, error) {
+	s, err := db.Prepare(q)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	return s.Query(args...)
+}
 
-	if !isCountStmt {
-		return 0, fmt.Errorf("invalid count statement: %s", q)
-	}
+// isSingleTable reports whether source is a table without an alias, which is read without the join machinery
+func isSingleTable(source sql.Source) bool {
+	t, ok := source.(*sql.QualifiedTableName)
+	return ok && t.Alias == nil
+}
 
-	// simply count rows
-	if where == nil {
-		pageNum, err := db.PageNum(table)
-		if err != nil {
-			return 0, err
+// selectRows evaluates exprs over the rows of src as they are read, skipping OFFSET rows and stopping after
+// LIMIT rows. Duplicate rows are left out when distinct is set, as explained by distinctValues.
+func selectRows(src rowSource, exprs []sql.Expr, distinct []eval.Collation, lo *limitOffset) (*Rows, error) {
+	cursor, err := src.rows()
+	if err != nil {
+		return nil, err
+	}
+	return newRows(limitValues(distinctValues(func() ([]cell.Value, error) {
+		row, err := cursor.next()
+		if err != nil || row == nil {
+			return nil, err
 		}
 
-		lp, err := page.NewLeafTablePage(db.f, db.PageSize(), uint(pageNum))
-		if err != nil {
-			return 0, err
+		values := make([]cell.Value, len(exprs))
+		for i, expr := range exprs {
+			if values[i], err = eval.Eval(expr, row); err != nil {
+				return nil, err
+			}
 		}
+		return values, nil
+	}, distinct), lo), nil), nil
+}
 
-		bhSize, err := lp.BTreeHeader.PageType.GetBTreeHeaderSize()
-		if err != nil {
-			return 0, err
-		}
+// selectSorted reads the rows of src and sorts them by the ORDER BY terms, keeping the values of exprs
+func selectSorted(src rowSource, exprs []sql.Expr, terms []*orderingTerm, distinct []eval.Collation, lo *limitOffset) (*Rows, error) {
+	s := sorter.NewSorter(&sorter.NewSorterRequest{
+		Compare: func(x, y []cell.Value) int {
+			return compareOrderingKeys(terms, x, y)
+		},
+	})
 
-		cells, err := cell.NewLeafTablePageCells(db.f, &cell.NewLeafTablePageCellRequest{
-			PageType:           lp.PageType,
-			PageOffset:         uint64(lp.Offset),
-			HeaderOffset:       uint64(bhSize),
-			CellCount:          uint64(lp.BTreeHeader.CellCount),
-			ColumnPosList:      nil,
-			AutoIncrKeyPosList: nil,
-			Where:              nil,
-		})
-		if err != nil {
-			return 0, err
+	// each sorted row holds the ORDER BY keys followed by the selected values
+	err := scanRows(src, func(row eval.Row) (bool, error) {
+		sortRow := make([]cell.Value, 0, len(terms)+len(exprs))
+		for _, term := range terms {
+			key, err := eval.Eval(term.expr, row)
+			if err != nil {
+				return false, err
+			}
+			sortRow = append(sortRow, key)
+		}
+		for _, expr := range exprs {
+			v, err := eval.Eval(expr, row)
+			if err != nil {
+				return false, err
+			}
+			sortRow = append(sortRow, v)
 		}
-		return len(cells), nil
+		return true, s.Add(sortRow)
+	})
+	if err != nil {
+		return nil, errors.Join(err, s.Close())
 	}
 
-	return 0, nil
+	return readSorted(s, len(terms), distinct, lo)
 }
 
-func (db *sqlite) Select(q string, args ...any) (cell.LeafTablePageCells, error) {
-	stmt, err := parser.NewStatement(q)
-	if err != nil {
-		return nil, err
-	}
-
-	ss, err := parser.NewSelectStatement(stmt)
+// readSorted returns the rows of s, made of keyCount ORDER BY keys followed by the selected values, skipping
+// OFFSET rows and stopping after LIMIT rows. Duplicate rows are left out when distinct is set. Closing the rows
+// closes s.
+func readSorted(s *sorter.Sorter, keyCount int, distinct []eval.Collation, lo *limitOffset) (*Rows, error) {
+	it, err := s.Sort()
 	if err != nil {
-		return nil, err
+		return nil, errors.Join(err, s.Close())
 	}
 
-	table := strings.ReplaceAll(ss.Source.String(), `"`, "")
-	columns := ss.Columns
-
-	if len(columns) == 0 {
-		return nil, errors.New("no columns found")
-	}
+	return newRows(limitValues(distinctValues(func() ([]cell.Value, error) {
+		if !it.Next() {
+			return nil, it.Err()
+		}
+		return it.Row()[keyCount:], nil
+	}, distinct), lo), s.Close), nil
+}
 
-	where, err := parser.NewWhereClause(ss.WhereExpr)
-	if err != nil {
-		return nil, err
-	}
+// ScanTable is a read of the rows of a table matching a WHERE clause, looking them up through an index when one applies
+type ScanTable struct {
+	PageNum            uint
+	Table              string
+	WhereExpr          sql.Expr
+	ColumnPosList      []int
+	AutoIncrKeyPosList []int
+	// Lookup narrows the rows down in place of the index lookup derived from WhereExpr when set
+	Lookup *indexLookup
+}
 
-	wherePos := 0
-	if where != nil {
-		wherePos, err = db.firstPage.SQLiteMasterRows.GetColumnPos(table, where.Key)
-		if err != nil {
-			return nil, err
-		}
+// newWhere builds the filter evaluating expr against every row read from table
+func (db *sqlite) newWhere(table string, expr sql.Expr) (*cell.Where, error) {
+	if expr == nil {
+		return nil, nil
 	}
 
-	pageNum, err := db.GetTraverseRootPageNum(table, where)
+	columns, err := db.firstPage.SQLiteMasterRows.GetColumns(table)
 	if err != nil {
 		return nil, err
 	}
 
-	pageType, err := page.GetPageType(db.f, db.PageSize(), uint(pageNum))
-	if err != nil {
-		return nil, err
-	}
+	return &cell.Where{
+		Match: func(c *cell.LeafTablePageCell) (bool, error) {
+			row, err := newTableRow(table, columns, db.firstPage.TextEncoding, c)
+			if err != nil {
+				return false, err
+			}
 
-	traverse := &TraverseBTree{
-		PageNum: uint(pageNum),
-		Table:   table,
-		Columns: columns,
-		Where: &cell.Where{
-			Clause:    where,
-			ColumnPos: wherePos,
+			v, err := eval.Eval(expr, row)
+			if err != nil {
+				return false, err
+			}
+			return eval.IsTrue(v), nil
 		},
-	}
-
-	switch pageType {
-	case header.LeafTableBTree:
-		return db.getLeafTablePageCells(traverse)
-	case header.InteriorTableBTree:
-		return db.traverseInteriorTableToGetCells(traverse)
-	case header.InteriorIndexBTree:
-		targetRowIDs, err := db.traverseInteriorIndexesToGetTargetRowIDs(traverse)
-		if err != nil {
-			return nil, err
-		}
-		pn, err := db.PageNum(table)
-		if err != nil {
-			return nil, err
-		}
-		cells, err := db.traverseInteriorTablesToGetCellsByPK(&TraverseBTreeByPrimaryKey{
-			PageNum:     uint(pn),
-			Table:       table,
-			Columns:     columns,
-			PrimaryKeys: targetRowIDs,
-		})
-		return cells, nil
-	default:
-		return nil, fmt.Errorf("invalid page type: %v", pageType)
-	}
+	}, nil
 }
 
 type TraverseBTree struct {
-	PageNum uint
-	Table   string
-	Columns []*sql.ResultColumn
-	Where   *cell.Where
+	PageNum            uint
+	Table              string
+	ColumnPosList      []int
+	AutoIncrKeyPosList []int
+	Where              *cell.Where
+	// IndexKey is the value looked up when traversing an index b-tree, whose keys are ordered by Compare
+	IndexKey cell.Value
+	Compare  eval.Comparator
 }
 
 // TODO: handle multiple primary key types
 type TraverseBTreeByPrimaryKey struct {
-	PageNum     uint
-	Table       string
-	Columns     []*sql.ResultColumn
-	PrimaryKeys []int
+	PageNum            uint
+	Table              string
+	ColumnPosList      []int
+	AutoIncrKeyPosList []int
+	PrimaryKeys        []int
+	Where              *cell.Where
 }
 
-func (db *sqlite) getLeafTablePageCells(t *TraverseBTree) (cell.LeafTablePageCells, error) {
-	lp, err := page.NewLeafTablePage(db.f, db.PageSize(), t.PageNum)
-	if err != nil {
-		return nil, err
-	}
+// comparator compares values the way the database sorts them, text in its encoding
+func (db *sqlite) comparator() eval.Comparator {
+	return eval.Comparator{Encoding: db.firstPage.TextEncoding}
+}
 
-	bhSize, err := lp.BTreeHeader.PageType.GetBTreeHeaderSize()
+// bTreePage reads the usable part of page pageNum of a b-tree, decoding its header
+func (db *sqlite) bTreePage(pageNum uint) ([]byte, *header.BTreeHeader, uint, error) {
+	data, err := db.pager.Page(pageNum)
 	if err != nil {
-		return nil, err
+		return nil, nil, 0, err
 	}
+	data = data[:db.UsableSize()]
 
-	columnNames := make([]string, len(t.Columns))
-	for i, column := range t.Columns {
-		columnNames[i] = column.String()
-	}
-
-	columnPosList, err := db.firstPage.SQLiteMasterRows.GetColumnPosList(t.Table, columnNames)
+	b, bhSize, err := header.NewBTreeHeader(data, 0)
 	if err != nil {
-		return nil, err
+		return nil, nil, 0, err
 	}
+	return data, b, bhSize, nil
+}
 
-	autoIncrPrimaryKeys, err := db.firstPage.SQLiteMasterRows.AutoIncrIntegerPrimaryKeys(t.Table)
+func (db *sqlite) getLeafTablePageCells(t *TraverseBTree) (cell.LeafTablePageCells, error) {
+	lp, err := page.NewLeafTablePage(db.pager, t.PageNum)
 	if err != nil {
 		return nil, err
 	}
 
-	autoIncrPrimaryKeyPosList, err := db.firstPage.SQLiteMasterRows.GetColumnPosList(t.Table, autoIncrPrimaryKeys)
+	bhSize, err := lp.BTreeHeader.PageType.GetBTreeHeaderSize()
 	if err != nil {
 		return nil, err
 	}
 
-	return cell.NewLeafTablePageCells(db.f, &cell.NewLeafTablePageCellRequest{
+	return cell.NewLeafTablePageCells(db.pager, &cell.NewLeafTablePageCellRequest{
 		PageType:           lp.PageType,
-		PageOffset:         uint64(lp.Offset),
+		Page:               lp.Data,
 		HeaderOffset:       uint64(bhSize),
 		CellCount:          uint64(lp.BTreeHeader.CellCount),
-		ColumnPosList:      columnPosList,
-		AutoIncrKeyPosList: autoIncrPrimaryKeyPosList,
+		UsableSize:         uint64(db.UsableSize()),
+		TextEncoding:       db.firstPage.TextEncoding,
+		ColumnPosList:      t.ColumnPosList,
+		AutoIncrKeyPosList: t.AutoIncrKeyPosList,
 		Where:              t.Where,
 	})
 }
 
-func (db *sqlite) traverseInteriorTableToGetCells(t *TraverseBTree) (cell.LeafTablePageCells, error) {
-	b, bhSize, err := header.NewBTreeHeader(db.f, (t.PageNum-1)*db.PageSize())
-	if err != nil {
-		return nil, err
-	}
-
-	if b.PageType == header.LeafTableBTree {
-		return db.getLeafTablePageCells(t)
-	}
-
-	ip, err := page.NewInteriorTable(db.f, db.PageSize(), t.PageNum)
-	if err != nil {
-		return nil, err
-	}
-
-	cells, err := cell.NewInteriorTablePageCells(db.f, &cell.NewInteriorTablePageCellRequest{
-		PageType:     ip.PageType,
-		PageOffset:   uint64(ip.Offset),
-		HeaderOffset: uint64(bhSize),
-		CellCount:    uint64(ip.BTreeHeader.CellCount),
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	leafCells := make(cell.LeafTablePageCells, 0)
-	for _, c := range cells {
-		cs, err := db.traverseInteriorTableToGetCells(&TraverseBTree{
-			PageNum: uint(c.LeftChildPageNum),
-			Table:   t.Table,
-			Columns: t.Columns,
-			Where:   t.Where,
-		})
-		if err != nil {
-			return nil, err
-		}
-		leafCells = append(leafCells, cs...)
-	}
-
-	cs, err := db.traverseInteriorTableToGetCells(&TraverseBTree{
-		PageNum: ip.RightMostPointer,
-		Table:   t.Table,
-		Columns: t.Columns,
-		Where:   t.Where,
-	})
-	if err != nil {
-		return nil, err
-	}
-	leafCells = append(leafCells, cs...)
-
-	return leafCells, nil
-}
-
 func (db *sqlite) traverseInteriorIndexesToGetTargetRowIDs(t *TraverseBTree) ([]int, error) {
-	b, bhSize, err := header.NewBTreeHeader(db.f, (t.PageNum-1)*db.PageSize())
+	_, b, bhSize, err := db.bTreePage(t.PageNum)
 	if err != nil {
 		return nil, err
 	}
@@ -277,61 +246,64 @@ func (db *sqlite) traverseInteriorIndexesToGetTargetRowIDs(t *TraverseBTree) ([]
 		return db.traverseLeafIndexesToGetTargetPrimaryKeys(t)
 	}
 
-	ii, err := page.NewInteriorIndex(db.f, db.PageSize(), t.PageNum)
+	ii, err := page.NewInteriorIndex(db.pager, t.PageNum)
 	if err != nil {
 		return nil, err
 	}
 
-	cells, err := cell.NewInteriorIndexPageCells(db.f, &cell.NewInteriorIndexPageCellRequest{
+	cells, err := cell.NewInteriorIndexPageCells(db.pager, &cell.NewInteriorIndexPageCellRequest{
 		PageType:     ii.PageType,
-		PageOffset:   uint64(ii.Offset),
+		Page:         ii.Data,
 		HeaderOffset: uint64(bhSize),
 		CellCount:    uint64(b.CellCount),
-		Where:        t.Where,
+		UsableSize:   uint64(db.UsableSize()),
+		TextEncoding: db.firstPage.TextEncoding,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: handle multiple where conditions
+	// index cells are sorted by key, each left child holding the keys lower than or equal to its cell's
 	targetRowIDs := make([]int, 0)
-	leftChildPageNumsToDeepDive := make([]uint, 0)
-	previousKey := ""
 	for _, c := range cells {
-		rowIDColumn := c.SerialTypeAndRecords[1]
-		whereKey := c.SerialTypeAndRecords[0].Record.String()
+		key, err := c.SerialTypeAndRecords[0].Value()
+		if err != nil {
+			return nil, err
+		}
+
 		// TODO: binary search
-		if previousKey <= t.Where.Clause.Value && t.Where.Clause.Value <= whereKey {
-			if t.Where.Clause.Value == whereKey {
-				rowID, err := rowIDColumn.Int()
-				if err != nil {
-					return nil, err
-				}
-				targetRowIDs = append(targetRowIDs, rowID)
-			}
-			leftChildPageNumsToDeepDive = append(leftChildPageNumsToDeepDive, uint(c.LeftChildPageNum))
+		cmp := t.Compare.Compare(t.IndexKey, key)
+		if cmp > 0 {
+			continue
 		}
-		previousKey = whereKey
-	}
 
-	for _, leftChildPageNum := range leftChildPageNumsToDeepDive {
 		rowIDs, err := db.traverseInteriorIndexesToGetTargetRowIDs(&TraverseBTree{
-			PageNum: leftChildPageNum,
-			Table:   t.Table,
-			Columns: t.Columns,
-			Where:   t.Where,
+			PageNum:  uint(c.LeftChildPageNum),
+			Table:    t.Table,
+			IndexKey: t.IndexKey,
+			Compare:  t.Compare,
 		})
 		if err != nil {
 			return nil, err
 		}
 		targetRowIDs = append(targetRowIDs, rowIDs...)
+
+		if cmp < 0 {
+			return targetRowIDs, nil
+		}
+
+		rowID, err := c.SerialTypeAndRecords[len(c.SerialTypeAndRecords)-1].Int()
+		if err != nil {
+			return nil, err
+		}
+		targetRowIDs = append(targetRowIDs, rowID)
 	}
 
 	rowIDs, err := db.traverseInteriorIndexesToGetTargetRowIDs(&TraverseBTree{
-		PageNum: ii.RightMostPointer,
-		Table:   t.Table,
-		Columns: t.Columns,
-		Where:   t.Where,
+		PageNum:  ii.RightMostPointer,
+		Table:    t.Table,
+		IndexKey: t.IndexKey,
+		Compare:  t.Compare,
 	})
 	if err != nil {
 		return nil, err
@@ -341,149 +313,219 @@ func (db *sqlite) traverseInteriorIndexesToGetTargetRowIDs(t *TraverseBTree) ([]
 	return targetRowIDs, nil
 }
 
-// TODO: not only row ids
-func (db *sqlite) traverseLeafIndexesToGetTargetPrimaryKeys(t *TraverseBTree) ([]int, error) {
-	b, bhSize, err := header.NewBTreeHeader(db.f, (t.PageNum-1)*db.PageSize())
-	if err != nil {
-		return nil, err
-	}
-
-	li, err := page.NewLeafIndex(db.f, db.PageSize(), t.PageNum)
+// countBTreeEntries counts the entries of a table or index b-tree from the cell counts of its pages,
+// the cells of interior index pages being entries too
+func (db *sqlite) countBTreeEntries(pageNum uint) (int, error) {
+	data, b, bhSize, err := db.bTreePage(pageNum)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	cells, err := cell.NewLeafIndexPageCells(db.f, &cell.NewLeafIndexPageCellRequest{
-		PageType:     li.PageType,
-		PageOffset:   uint64(li.Offset),
-		HeaderOffset: uint64(bhSize),
-		CellCount:    uint64(b.CellCount),
-		Where:        t.Where,
-	})
-	if err != nil {
-		return nil, err
+	childPageNums := make([]uint, 0, b.CellCount+1)
+	count := 0
+	switch b.PageType {
+	case header.LeafTableBTree, header.LeafIndexBTree:
+		return int(b.CellCount), nil
+	case header.InteriorTableBTree:
+		cells, err := cell.NewInteriorTablePageCells(&cell.NewInteriorTablePageCellRequest{
+			PageType:     b.PageType,
+			Page:         data,
+			HeaderOffset: uint64(bhSize),
+			CellCount:    uint64(b.CellCount),
+		})
+		if err != nil {
+			return 0, err
+		}
+		for _, c := range cells {
+			childPageNums = append(childPageNums, uint(c.LeftChildPageNum))
+		}
+	default:
+		cells, err := cell.NewInteriorIndexPageCells(db.pager, &cell.NewInteriorIndexPageCellRequest{
+			PageType:     b.PageType,
+			Page:         data,
+			HeaderOffset: uint64(bhSize),
+			CellCount:    uint64(b.CellCount),
+			UsableSize:   uint64(db.UsableSize()),
+			TextEncoding: db.firstPage.TextEncoding,
+		})
+		if err != nil {
+			return 0, err
+		}
+		for _, c := range cells {
+			childPageNums = append(childPageNums, uint(c.LeftChildPageNum))
+		}
+		count += len(cells)
 	}
+	childPageNums = append(childPageNums, b.RightMostPointer)
 
-	targetRowIDs := make([]int, 0)
-	for _, c := range cells {
-		whereClauseColumn := c.SerialTypeAndRecords[0]
-		rowIDColumn := c.SerialTypeAndRecords[1]
-		if whereClauseColumn.Record.String() == t.Where.Clause.Value {
-			rowID, err := rowIDColumn.Int()
-			if err != nil {
-				return nil, err
-			}
-			targetRowIDs = append(targetRowIDs, rowID)
+	for _, childPageNum := range childPageNums {
+		n, err := db.countBTreeEntries(childPageNum)
+		if err != nil {
+			return 0, err
 		}
+		count += n
 	}
+	return count, nil
+}
 
-	return targetRowIDs, nil
+type TraverseIndex struct {
+	PageNum uint
+	// Visit is called with the values of every entry of the index in key order, traversal stopping when it returns false
+	Visit func(values []cell.Value) (bool, error)
 }
 
-// TODO: not only row ids
-func (db *sqlite) traverseInteriorTablesToGetCellsByPK(t *TraverseBTreeByPrimaryKey) (cell.LeafTablePageCells, error) {
-	b, bhSize, err := header.NewBTreeHeader(db.f, (t.PageNum-1)*db.PageSize())
+// traverseInteriorIndexToVisitRecords visits the entries of an index b-tree, returning false when the traversal
+// was stopped by t.Visit
+func (db *sqlite) traverseInteriorIndexToVisitRecords(t *TraverseIndex) (bool, error) {
+	_, b, bhSize, err := db.bTreePage(t.PageNum)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	if b.PageType == header.LeafTableBTree {
-		return db.getLeafTablesToGetCellsByPK(t)
+	if b.PageType == header.LeafIndexBTree {
+		li, err := page.NewLeafIndex(db.pager, t.PageNum)
+		if err != nil {
+			return false, err
+		}
+
+		cells, err := cell.NewLeafIndexPageCells(db.pager, &cell.NewLeafIndexPageCellRequest{
+			PageType:     li.PageType,
+			Page:         li.Data,
+			HeaderOffset: uint64(bhSize),
+			CellCount:    uint64(b.CellCount),
+			UsableSize:   uint64(db.UsableSize()),
+			TextEncoding: db.firstPage.TextEncoding,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		for _, c := range cells {
+			ok, err := visitRecords(c.SerialTypeAndRecords, t.Visit)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
 	}
 
-	it, err := page.NewInteriorTable(db.f, db.PageSize(), t.PageNum)
+	ii, err := page.NewInteriorIndex(db.pager, t.PageNum)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	cells, err := cell.NewInteriorTablePageCells(db.f, &cell.NewInteriorTablePageCellRequest{
-		PageType:     it.PageType,
-		PageOffset:   uint64(it.Offset),
+	cells, err := cell.NewInteriorIndexPageCells(db.pager, &cell.NewInteriorIndexPageCellRequest{
+		PageType:     ii.PageType,
+		Page:         ii.Data,
 		HeaderOffset: uint64(bhSize),
 		CellCount:    uint64(b.CellCount),
+		UsableSize:   uint64(db.UsableSize()),
+		TextEncoding: db.firstPage.TextEncoding,
 	})
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	leafCells := make(cell.LeafTablePageCells, 0)
-	previousKey := 0
+	// the entries of a left child come before the entry of its cell
 	for _, c := range cells {
-		primaryKeysToTraverseBy := make([]int, 0)
-		for _, pk := range t.PrimaryKeys {
-			if previousKey <= pk && pk <= int(c.RowID) {
-				if pk <= int(c.RowID) {
-					primaryKeysToTraverseBy = append(primaryKeysToTraverseBy, pk)
-				}
-			}
+		ok, err := db.traverseInteriorIndexToVisitRecords(&TraverseIndex{
+			PageNum: uint(c.LeftChildPageNum),
+			Visit:   t.Visit,
+		})
+		if err != nil || !ok {
+			return false, err
 		}
-		previousKey = int(c.RowID)
-		if len(primaryKeysToTraverseBy) > 0 {
-			cs, err := db.traverseInteriorTablesToGetCellsByPK(&TraverseBTreeByPrimaryKey{
-				PageNum:     uint(c.LeftChildPageNum),
-				Table:       t.Table,
-				Columns:     t.Columns,
-				PrimaryKeys: primaryKeysToTraverseBy,
-			})
-			if err != nil {
-				return nil, err
-			}
-			leafCells = append(leafCells, cs...)
+
+		ok, err = visitRecords(c.SerialTypeAndRecords, t.Visit)
+		if err != nil || !ok {
+			return false, err
 		}
 	}
 
-	cs, err := db.traverseInteriorTablesToGetCellsByPK(&TraverseBTreeByPrimaryKey{
-		PageNum:     it.RightMostPointer,
-		Table:       t.Table,
-		Columns:     t.Columns,
-		PrimaryKeys: t.PrimaryKeys,
+	return db.traverseInteriorIndexToVisitRecords(&TraverseIndex{
+		PageNum: ii.RightMostPointer,
+		Visit:   t.Visit,
 	})
-	if err != nil {
-		return nil, err
-	}
-	leafCells = append(leafCells, cs...)
+}
 
-	return leafCells, nil
+func visitRecords(srs []*cell.SerialTypeAndRecord, visit func(values []cell.Value) (bool, error)) (bool, error) {
+	values := make([]cell.Value, len(srs))
+	for i, sr := range srs {
+		v, err := sr.Value()
+		if err != nil {
+			return false, err
+		}
+		values[i] = v
+	}
+	return visit(values)
 }
 
-func (db *sqlite) getLeafTablesToGetCellsByPK(t *TraverseBTreeByPrimaryKey) (cell.LeafTablePageCells, error) {
-	lp, err := page.NewLeafTablePage(db.f, db.PageSize(), t.PageNum)
+// TODO: not only row ids
+func (db *sqlite) traverseLeafIndexesToGetTargetPrimaryKeys(t *TraverseBTree) ([]int, error) {
+	_, b, bhSize, err := db.bTreePage(t.PageNum)
 	if err != nil {
 		return nil, err
 	}
 
-	bhSize, err := lp.BTreeHeader.PageType.GetBTreeHeaderSize()
+	li, err := page.NewLeafIndex(db.pager, t.PageNum)
 	if err != nil {
 		return nil, err
 	}
 
-	columnNames := make([]string, len(t.Columns))
-	for i, column := range t.Columns {
-		columnNames[i] = column.String()
-	}
-
-	columnPosList, err := db.firstPage.SQLiteMasterRows.GetColumnPosList(t.Table, columnNames)
+	cells, err := cell.NewLeafIndexPageCells(db.pager, &cell.NewLeafIndexPageCellRequest{
+		PageType:     li.PageType,
+		Page:         li.Data,
+		HeaderOffset: uint64(bhSize),
+		CellCount:    uint64(b.CellCount),
+		UsableSize:   uint64(db.UsableSize()),
+		TextEncoding: db.firstPage.TextEncoding,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	autoIncrPrimaryKeys, err := db.firstPage.SQLiteMasterRows.AutoIncrIntegerPrimaryKeys(t.Table)
+	targetRowIDs := make([]int, 0)
+	for _, c := range cells {
+		key, err := c.SerialTypeAndRecords[0].Value()
+		if err != nil {
+			return nil, err
+		}
+		if t.Compare.Compare(t.IndexKey, key) != 0 {
+			continue
+		}
+
+		// the row id is the last column of an index record
+		rowID, err := c.SerialTypeAndRecords[len(c.SerialTypeAndRecords)-1].Int()
+		if err != nil {
+			return nil, err
+		}
+		targetRowIDs = append(targetRowIDs, rowID)
+	}
+
+	return targetRowIDs, nil
+}
+
+func (db *sqlite) getLeafTablesToGetCellsByPK(t *TraverseBTreeByPrimaryKey) (cell.LeafTablePageCells, error) {
+	lp, err := page.NewLeafTablePage(db.pager, t.PageNum)
 	if err != nil {
 		return nil, err
 	}
 
-	autoIncrPrimaryKeyPosList, err := db.firstPage.SQLiteMasterRows.GetColumnPosList(t.Table, autoIncrPrimaryKeys)
+	bhSize, err := lp.BTreeHeader.PageType.GetBTreeHeaderSize()
 	if err != nil {
 		return nil, err
 	}
 
-	return cell.NewLeafTablePageCellsByPK(db.f, &cell.NewLeafTablePageCellsByPKsRequest{
+	return cell.NewLeafTablePageCellsByPK(db.pager, &cell.NewLeafTablePageCellsByPKsRequest{
 		PageType:           lp.PageType,
-		PageOffset:         uint64(lp.Offset),
+		Page:               lp.Data,
 		HeaderOffset:       uint64(bhSize),
 		CellCount:          uint64(lp.BTreeHeader.CellCount),
-		ColumnPosList:      columnPosList,
-		AutoIncrKeyPosList: autoIncrPrimaryKeyPosList,
+		UsableSize:         uint64(db.UsableSize()),
+		TextEncoding:       db.firstPage.TextEncoding,
+		ColumnPosList:      t.ColumnPosList,
+		AutoIncrKeyPosList: t.AutoIncrKeyPosList,
 		PrimaryKeys:        t.PrimaryKeys,
+		Where:              t.Where,
 	})
 }
@@ -0,0 +1,198 @@
+package sqlite
+
+import (
+	"errors"
+	"github/com/codecrafters-io/sqlite-starter-go/app/eval"
+	"github/com/codecrafters-io/sqlite-starter-go/app/schema"
+	"slices"
+	"strings"
+
+	"github.com/rqlite/sql"
+)
+
+// Column describes a result column of a query
+type Column struct {
+	// Name is the name the column is labeled with: its alias, else the column it reads or the expression as written
+	Name string
+	// Alias is the name given with AS, empty without one
+	Alias string
+	// DeclType is the declared type of the table column the result column reads, empty for other expressions
+	DeclType string
+	// Table and Column are the table and the column the result column reads, empty for other expressions.
+	// The row id of a table without alias is read from column "rowid".
+	Table  string
+	Column string
+}
+
+// columnResolver returns the table a column reference reads along with its column, nil when it reads a row id
+// without alias
+type columnResolver func(table, column string) (string, *schema.Column, error)
+
+// newColumn describes the result column evaluating expr, text being the expression as written in the query
+func newColumn(rc *sql.ResultColumn, expr sql.Expr, text string, resolve columnResolver) (*Column, error) {
+	c := &Column{Name: text}
+	if c.Name == "" {
+		c.Name = expr.String()
+	}
+
+	var table, name string
+	switch e := expr.(type) {
+	case *sql.Ident:
+		name = e.Name
+	case *sql.QualifiedRef:
+		table, name = e.Table.Name, e.Column.Name
+	}
+	if name != "" {
+		source, column, err := resolve(table, name)
+		// a double-quoted identifier that is not a column is a string literal
+		if err != nil && !errors.Is(err, eval.ErrNoSuchColumn) {
+			return nil, err
+		}
+		switch {
+		case err != nil:
+		case column == nil:
+			c.Name, c.DeclType = name, "INTEGER"
+			c.Table, c.Column = source, "rowid"
+		default:
+			c.Name, c.DeclType = column.Name, column.Type
+			c.Table, c.Column = source, column.Name
+		}
+	}
+
+	if rc != nil && rc.Alias != nil {
+		c.Name, c.Alias = rc.Alias.Name, rc.Alias.Name
+	}
+	return c, nil
+}
+
+// newColumns describes the result columns, texts being their expressions as written in the query or nil
+func newColumns(columns []*sql.ResultColumn, texts []string, resolve columnResolver) ([]*Column, error) {
+	descs := make([]*Column, len(columns))
+	for i, c := range columns {
+		text := ""
+		if texts != nil {
+			text = texts[i]
+		}
+		var err error
+		if descs[i], err = newColumn(c, c.Expr, text, resolve); err != nil {
+			return nil, err
+		}
+	}
+	return descs, nil
+}
+
+// starExpander returns the references to the columns * stands for when table is empty, and table.* otherwise
+type starExpander func(table string) ([]sql.Expr, error)
+
+// expandStars replaces * and table.* with result columns referring to the columns they stand for, along with
+// the texts of the result columns when they are given, the expanded ones having none
+func expandStars(columns []*sql.ResultColumn, texts []string, expand starExpander) ([]*sql.ResultColumn, []string, error) {
+	expanded := make([]*sql.ResultColumn, 0, len(columns))
+	expandedTexts := make([]string, 0, len(columns))
+	for i, c := range columns {
+		table := ""
+		qr, ok := c.Expr.(*sql.QualifiedRef)
+		switch {
+		case c.Star.IsValid():
+		case ok && qr.Star.IsValid():
+			table = qr.Table.Name
+		default:
+			expanded = append(expanded, c)
+			if texts != nil {
+				expandedTexts = append(expandedTexts, texts[i])
+			}
+			continue
+		}
+
+		refs, err := expand(table)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, ref := range refs {
+			expanded = append(expanded, &sql.ResultColumn{Expr: ref})
+			expandedTexts = append(expandedTexts, "")
+		}
+	}
+	if texts == nil {
+		expandedTexts = nil
+	}
+	return expanded, expandedTexts, nil
+}
+
+// columnRefs returns references to columns qualified by table
+func columnRefs(table string, columns []*schema.Column) []sql.Expr {
+	refs := make([]sql.Expr, len(columns))
+	for i, c := range columns {
+		refs[i] = &sql.QualifiedRef{Table: &sql.Ident{Name: table}, Column: &sql.Ident{Name: c.Name}}
+	}
+	return refs
+}
+
+// selectedColumns returns the table columns the result columns read, false when one of them is another
+// expression, the row id included
+func selectedColumns(columns []*sql.ResultColumn, tableColumns []*schema.Column) ([]*schema.Column, bool) {
+	selected := make([]*schema.Column, len(columns))
+	for i, c := range columns {
+		var name string
+		switch e := c.Expr.(type) {
+		case *sql.Ident:
+			name = e.Name
+		case *sql.QualifiedRef:
+			name = e.Column.Name
+		default:
+			return nil, false
+		}
+		k := slices.IndexFunc(tableColumns, func(c *schema.Column) bool { return strings.EqualFold(c.Name, name) })
+		if k < 0 {
+			return nil, false
+		}
+		selected[i] = tableColumns[k]
+	}
+	return selected, true
+}
+
+// exprCollation returns the collation of the column expr refers to, ok being false when it is not a column reference
+func exprCollation(expr sql.Expr, resolve columnResolver) (c eval.Collation, ok bool, err error) {
+	return eval.ExprCollation(expr, func(table, column string) (eval.Collation, error) {
+		_, c, err := resolve(table, column)
+		if err != nil || c == nil {
+			return eval.CollationBinary, err
+		}
+		return eval.ParseCollation(c.Collation)
+	})
+}
+
+// noColumns is the columnResolver of a query without FROM clause
+func noColumns(string, string) (string, *schema.Column, error) {
+	return "", nil, eval.ErrNoSuchColumn
+}
+
+// tableColumnResolver resolves column references against the columns of table
+func tableColumnResolver(table string, columns []*schema.Column) columnResolver {
+	return func(qualifier, column string) (string, *schema.Column, error) {
+		if qualifier != "" && !strings.EqualFold(qualifier, table) {
+			return "", nil, eval.ErrNoSuchColumn
+		}
+		c, err := findColumn(columns, column)
+		return table, c, err
+	}
+}
+
+// findColumn returns the column named column, the row id alias for a row id name, and nil for the row id of
+// a table without alias
+func findColumn(columns []*schema.Column, column string) (*schema.Column, error) {
+	for _, c := range columns {
+		if strings.EqualFold(c.Name, column) {
+			return c, nil
+		}
+	}
+	if !isRowIDName(column) {
+		return nil, eval.ErrNoSuchColumn
+	}
+	for _, c := range columns {
+		if c.IsRowIDAlias() {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,18 @@
+package sqlite
+
+import "github/com/codecrafters-io/sqlite-starter-go/app/cell"
+
+// ResultSet is the whole result of a query, its rows read at once
+type ResultSet struct {
+	Columns []*Column
+	Rows    [][]cell.Value
+}
+
+// ColumnNames returns the names the columns are labeled with
+func (rs *ResultSet) ColumnNames() []string {
+	names := make([]string, len(rs.Columns))
+	for i, c := range rs.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
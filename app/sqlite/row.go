@@ -0,0 +1,280 @@
+package sqlite
+
+import (
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/eval"
+	"github/com/codecrafters-io/sqlite-starter-go/app/header"
+	"github/com/codecrafters-io/sqlite-starter-go/app/schema"
+	"strings"
+
+	"github.com/rqlite/sql"
+)
+
+// tableRow is a decoded row of a table, resolving the column references of WHERE expressions
+type tableRow struct {
+	table   string
+	columns []*schema.Column
+	enc     header.TextEncoding
+	rowID   uint64
+	values  []cell.Value
+}
+
+var _ eval.Row = (*tableRow)(nil)
+
+func newTableRow(table string, columns []*schema.Column, enc header.TextEncoding, c *cell.LeafTablePageCell) (*tableRow, error) {
+	values, err := c.Values()
+	if err != nil {
+		return nil, err
+	}
+	return &tableRow{
+		table:   table,
+		columns: columns,
+		enc:     enc,
+		rowID:   c.RowID,
+		values:  values,
+	}, nil
+}
+
+func (r *tableRow) Column(table, column string) (cell.Value, eval.Affinity, eval.Collation, error) {
+	if table != "" && !strings.EqualFold(table, r.table) {
+		return noColumn(fmt.Errorf("%w: %s.%s", eval.ErrNoSuchColumn, table, column))
+	}
+
+	for i, c := range r.columns {
+		if !strings.EqualFold(c.Name, column) {
+			continue
+		}
+		affinity := eval.AffinityFromType(c.Type)
+		collation, err := eval.ParseCollation(c.Collation)
+		if err != nil {
+			return noColumn(err)
+		}
+		// columns added by ALTER TABLE are missing from rows written before
+		if i >= len(r.values) {
+			return cell.NullValue(), affinity, collation, nil
+		}
+		return readValue(r.values[i], affinity), affinity, collation, nil
+	}
+
+	if isRowIDName(column) {
+		return cell.IntegerValue(int64(r.rowID)), eval.AffinityInteger, eval.CollationBinary, nil
+	}
+	return noColumn(fmt.Errorf("%w: %s", eval.ErrNoSuchColumn, column))
+}
+
+// noColumn is what Column returns along with err
+func noColumn(err error) (cell.Value, eval.Affinity, eval.Collation, error) {
+	return cell.Value{}, eval.AffinityNone, eval.CollationBinary, err
+}
+
+func (r *tableRow) Encoding() header.TextEncoding {
+	return r.enc
+}
+
+// readValue returns a value read from a column with affinity. A REAL column stores reals without fractional
+// part as integers to save space, which read back as reals.
+func readValue(v cell.Value, affinity eval.Affinity) cell.Value {
+	if affinity == eval.AffinityReal && v.Type == cell.ValueTypeInteger {
+		return cell.RealValue(float64(v.Integer))
+	}
+	return v
+}
+
+// isRowIDName reports whether column is one of the names the row id can be referred to by
+func isRowIDName(column string) bool {
+	switch strings.ToLower(column) {
+	case "rowid", "oid", "_rowid_":
+		return true
+	default:
+		return false
+	}
+}
+
+// indexRow is an entry of an index, holding the indexed columns of a table row followed by its row id
+type indexRow struct {
+	table   string
+	columns []*schema.Column
+	index   *schema.IndexPageAndColumns
+	enc     header.TextEncoding
+	values  []cell.Value
+}
+
+var _ eval.Row = (*indexRow)(nil)
+
+func (r *indexRow) Column(table, column string) (cell.Value, eval.Affinity, eval.Collation, error) {
+	if table != "" && !strings.EqualFold(table, r.table) {
+		return noColumn(fmt.Errorf("%w: %s.%s", eval.ErrNoSuchColumn, table, column))
+	}
+
+	var tableColumn *schema.Column
+	for _, c := range r.columns {
+		if strings.EqualFold(c.Name, column) {
+			tableColumn = c
+		}
+	}
+
+	rowID := r.values[len(r.values)-1]
+	if tableColumn == nil {
+		if isRowIDName(column) {
+			return rowID, eval.AffinityInteger, eval.CollationBinary, nil
+		}
+		return noColumn(fmt.Errorf("%w: %s", eval.ErrNoSuchColumn, column))
+	}
+	if tableColumn.IsRowIDAlias() {
+		return rowID, eval.AffinityInteger, eval.CollationBinary, nil
+	}
+
+	for i, name := range r.index.Columns {
+		if strings.EqualFold(name, column) && i < len(r.values)-1 {
+			affinity := eval.AffinityFromType(tableColumn.Type)
+			collation, err := eval.ParseCollation(tableColumn.Collation)
+			if err != nil {
+				return noColumn(err)
+			}
+			return readValue(r.values[i], affinity), affinity, collation, nil
+		}
+	}
+	return noColumn(fmt.Errorf("column %s is not in index %s", column, r.index.Name))
+}
+
+func (r *indexRow) Encoding() header.TextEncoding {
+	return r.enc
+}
+
+// rowSource reads the rows of the FROM clause of a query
+type rowSource interface {
+	// rows opens a cursor over the rows matching the WHERE clause
+	rows() (rowCursor, error)
+	// emptyRow is a row whose columns are all NULL, which an aggregate query without rows reads its bare columns from
+	emptyRow() eval.Row
+}
+
+// rowCursor reads the rows of a rowSource one at a time
+type rowCursor interface {
+	// next returns the following row, nil once the rows are read through
+	next() (eval.Row, error)
+}
+
+// scanRows calls visit with every row of src, reading stopping when visit returns false
+func scanRows(src rowSource, visit func(row eval.Row) (bool, error)) error {
+	cursor, err := src.rows()
+	if err != nil {
+		return err
+	}
+	for {
+		row, err := cursor.next()
+		if err != nil || row == nil {
+			return err
+		}
+		ok, err := visit(row)
+		if err != nil || !ok {
+			return err
+		}
+	}
+}
+
+// valuesSource is the rowSource of a query without FROM clause, made of a single row without columns unless
+// where is false
+type valuesSource struct {
+	where sql.Expr
+	enc   header.TextEncoding
+}
+
+var _ rowSource = (*valuesSource)(nil)
+
+func (s *valuesSource) rows() (rowCursor, error) {
+	ok := true
+	if s.where != nil {
+		v, err := eval.Eval(s.where, s.emptyRow())
+		if err != nil {
+			return nil, err
+		}
+		ok = eval.IsTrue(v)
+	}
+	return &valuesCursor{read: !ok, enc: s.enc}, nil
+}
+
+func (s *valuesSource) emptyRow() eval.Row {
+	return emptyValuesRow{enc: s.enc}
+}
+
+type valuesCursor struct {
+	read bool
+	enc  header.TextEncoding
+}
+
+func (c *valuesCursor) next() (eval.Row, error) {
+	if c.read {
+		return nil, nil
+	}
+	c.read = true
+	return emptyValuesRow{enc: c.enc}, nil
+}
+
+// emptyValuesRow is the row of a query without FROM clause, which has no column to refer to
+type emptyValuesRow struct {
+	enc header.TextEncoding
+}
+
+func (emptyValuesRow) Column(table, column string) (cell.Value, eval.Affinity, eval.Collation, error) {
+	if table != "" {
+		return noColumn(fmt.Errorf("%w: %s.%s", eval.ErrNoSuchColumn, table, column))
+	}
+	return noColumn(fmt.Errorf("%w: %s", eval.ErrNoSuchColumn, column))
+}
+
+func (r emptyValuesRow) Encoding() header.TextEncoding {
+	return r.enc
+}
+
+// tableScan is the rowSource of a query reading a single table
+type tableScan struct {
+	plan    *tablePlan
+	columns []*schema.Column
+}
+
+var _ rowSource = (*tableScan)(nil)
+
+func (db *sqlite) newTableScan(st *ScanTable) (*tableScan, error) {
+	columns, err := db.firstPage.SQLiteMasterRows.GetColumns(st.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	// rows are evaluated over every column
+	all := *st
+	all.ColumnPosList = nil
+	plan, err := db.newTablePlan(&all)
+	if err != nil {
+		return nil, err
+	}
+	return &tableScan{plan: plan, columns: columns}, nil
+}
+
+func (s *tableScan) rows() (rowCursor, error) {
+	cursor, err := s.plan.open()
+	if err != nil {
+		return nil, err
+	}
+	return &tableRowCursor{cursor: cursor, table: s.plan.st.Table, columns: s.columns}, nil
+}
+
+func (s *tableScan) emptyRow() eval.Row {
+	return &tableRow{table: s.plan.st.Table, columns: s.columns, enc: s.plan.db.firstPage.TextEncoding}
+}
+
+// tableRowCursor decodes the cells of a tableCursor into rows
+type tableRowCursor struct {
+	cursor  *tableCursor
+	table   string
+	columns []*schema.Column
+}
+
+func (c *tableRowCursor) next() (eval.Row, error) {
+	next, err := c.cursor.next()
+	if err != nil || next == nil {
+		return nil, err
+	}
+	return newTableRow(c.table, c.columns, c.cursor.db.firstPage.TextEncoding, next)
+}
@@ -0,0 +1,236 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/eval"
+)
+
+// Rows is the result of a query, whose rows are read from the database as Next is called
+type Rows struct {
+	columns []*Column
+	// next returns the values of the following row, nil once the rows are read through
+	next   func() ([]cell.Value, error)
+	close  func() error
+	values []cell.Value
+	err    error
+	closed bool
+}
+
+func newRows(next func() ([]cell.Value, error), close func() error) *Rows {
+	return &Rows{next: next, close: close}
+}
+
+// noRows is the next function of a result without rows
+func noRows() ([]cell.Value, error) {
+	return nil, nil
+}
+
+// singleRow is the next function of a result made of values alone
+func singleRow(values []cell.Value) func() ([]cell.Value, error) {
+	read := false
+	return func() ([]cell.Value, error) {
+		if read {
+			return nil, nil
+		}
+		read = true
+		return values, nil
+	}
+}
+
+// valueRows is the next function of a result made of rows of values alone
+func valueRows(rows [][]cell.Value) func() ([]cell.Value, error) {
+	return func() ([]cell.Value, error) {
+		if len(rows) == 0 {
+			return nil, nil
+		}
+		values := rows[0]
+		rows = rows[1:]
+		return values, nil
+	}
+}
+
+// Next advances to the following row, returning false once the rows are read through or reading them failed,
+// the rows being closed then
+func (r *Rows) Next() bool {
+	if r.closed {
+		return false
+	}
+
+	values, err := r.next()
+	if err != nil {
+		r.err = err
+	}
+	if err != nil || values == nil {
+		r.values = nil
+		if err := r.Close(); err != nil && r.err == nil {
+			r.err = err
+		}
+		return false
+	}
+	r.values = values
+	return true
+}
+
+func (r *Rows) Columns() []*Column {
+	return r.columns
+}
+
+// Values returns the values of the current row
+func (r *Rows) Values() []cell.Value {
+	return r.values
+}
+
+// Scan copies the values of the current row into dest, which may point to a cell.Value, any, string, []byte,
+// int, int64, float64 or bool
+func (r *Rows) Scan(dest ...any) error {
+	if r.values == nil {
+		return errors.New("Scan called without calling Next")
+	}
+	if len(dest) != len(r.values) {
+		return fmt.Errorf("expected %d destination arguments in Scan, not %d", len(r.values), len(dest))
+	}
+
+	for i, d := range dest {
+		if err := scanValue(r.values[i], d); err != nil {
+			return fmt.Errorf("scan error on column index %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close releases the resources held by the rows, e.g. the temporary files of a sort. It may be called more than once.
+func (r *Rows) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.close == nil {
+		return nil
+	}
+	return r.close()
+}
+
+func scanValue(v cell.Value, dest any) error {
+	switch d := dest.(type) {
+	case *cell.Value:
+		*d = v
+		return nil
+	case *any:
+		switch v.Type {
+		case cell.ValueTypeNull:
+			*d = nil
+		case cell.ValueTypeInteger:
+			*d = v.Integer
+		case cell.ValueTypeReal:
+			*d = v.Real
+		case cell.ValueTypeText:
+			*d = string(v.Bytes)
+		default:
+			*d = append([]byte(nil), v.Bytes...)
+		}
+		return nil
+	case *[]byte:
+		switch v.Type {
+		case cell.ValueTypeNull:
+			*d = nil
+		case cell.ValueTypeText, cell.ValueTypeBlob:
+			*d = append([]byte(nil), v.Bytes...)
+		default:
+			*d = []byte(v.String())
+		}
+		return nil
+	}
+
+	if v.IsNull() {
+		return fmt.Errorf("converting NULL to %T is unsupported", dest)
+	}
+
+	switch d := dest.(type) {
+	case *string:
+		*d = v.String()
+	case *int64:
+		i, err := scanInteger(v)
+		if err != nil {
+			return err
+		}
+		*d = i
+	case *int:
+		i, err := scanInteger(v)
+		if err != nil {
+			return err
+		}
+		*d = int(i)
+	case *float64:
+		n := eval.AffinityReal.Apply(v)
+		if n.Type != cell.ValueTypeReal {
+			return fmt.Errorf("converting %s %q to float64 is unsupported", v.Type, v.String())
+		}
+		*d = n.Real
+	case *bool:
+		*d = eval.IsTrue(v)
+	default:
+		return fmt.Errorf("unsupported Scan type %T", dest)
+	}
+	return nil
+}
+
+func scanInteger(v cell.Value) (int64, error) {
+	n := eval.AffinityInteger.Apply(v)
+	if n.Type != cell.ValueTypeInteger {
+		return 0, fmt.Errorf("converting %s %q to an integer is unsupported", v.Type, v.String())
+	}
+	return n.Integer, nil
+}
+
+// distinctValues returns the rows of next without those equal to a row returned before, their values comparing
+// with collations. It returns next itself when collations is nil, the query not being a SELECT DISTINCT.
+func distinctValues(next func() ([]cell.Value, error), collations []eval.Collation) func() ([]cell.Value, error) {
+	if collations == nil {
+		return next
+	}
+	seen := make(map[string]bool)
+	return func() ([]cell.Value, error) {
+		for {
+			values, err := next()
+			if err != nil || values == nil {
+				return nil, err
+			}
+			folded := make([]cell.Value, len(values))
+			for i, v := range values {
+				folded[i] = collations[i].Fold(v)
+			}
+			if key := eval.DistinctKey(folded...); !seen[key] {
+				seen[key] = true
+				return values, nil
+			}
+		}
+	}
+}
+
+// limitValues returns the rows of next without the OFFSET first ones, stopping after LIMIT rows
+func limitValues(next func() ([]cell.Value, error), lo *limitOffset) func() ([]cell.Value, error) {
+	offset, count := lo.offset, int64(0)
+	return func() ([]cell.Value, error) {
+		for ; offset > 0; offset-- {
+			values, err := next()
+			if err != nil || values == nil {
+				return nil, err
+			}
+		}
+
+		if lo.limit >= 0 && count >= lo.limit {
+			return nil, nil
+		}
+		values, err := next()
+		if values != nil {
+			count++
+		}
+		return values, err
+	}
+}
@@ -1,42 +1,212 @@
 package sqlite
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/eval"
+	"github/com/codecrafters-io/sqlite-starter-go/app/header"
 	"github/com/codecrafters-io/sqlite-starter-go/app/page"
+	"github/com/codecrafters-io/sqlite-starter-go/app/pager"
 	"github/com/codecrafters-io/sqlite-starter-go/app/parser"
 	"github/com/codecrafters-io/sqlite-starter-go/app/schema"
-	"github/com/codecrafters-io/sqlite-starter-go/app/utils"
+	"github/com/codecrafters-io/sqlite-starter-go/app/wal"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rqlite/sql"
 )
 
 type sqlite struct {
-	f          *os.File
+	pager *pager.Pager
+	// walFile is the write-ahead log the pager reads, closed along with the database
+	walFile *os.File
+	// path is the absolute path of the database file, empty for a database read from memory
+	path       string
 	pageSize   uint
 	tablePages map[string]int
-	indexPages map[string]*schema.IndexPageAndColumns
+	indexPages map[string][]*schema.IndexPageAndColumns
 	firstPage  *page.FirstPage
 }
 
 type DB interface {
 	PageSize() uint
+	// UsableSize returns the page size minus the bytes reserved at the end of every page
+	UsableSize() uint
+	// ReservedSpace returns the reserved bytes at the end of page pageNum, e.g. for checksum verification
+	ReservedSpace(pageNum uint) ([]byte, error)
 	PageNum(table string) (int, error)
 	TableCount() uint16
 	Tables() []string
+	// FileHeader returns the header of the database as of opening it
+	FileHeader() *header.FileHeader
+	// Schema returns the rows of sqlite_schema
+	Schema() schema.SQLiteMasterRows
+	// CacheStats returns the hits and misses of the page cache
+	CacheStats() pager.Stats
+	// Mode tells whether pages are read with pread or sliced out of a memory mapping
+	Mode() pager.Mode
+	// Close releases the memory mapping and the write-ahead log of the database, leaving the file open.
+	// Values read from a mapped database must not be used after it is closed.
+	Close() error
 	SQLite
 }
 
+// Options tune how a database file is opened
+type Options struct {
+	// Mmap maps the file in memory, pages and the values decoded from them being sliced out of the mapping.
+	// Pages are read with pread when the file cannot be mapped, which DB.Mode tells.
+	Mmap bool
+	// CacheSize is the number of pages kept in memory when reading them with pread, pager.DefaultCacheSize when 0
+	CacheSize int
+}
+
+// NewDB opens the database file f
 func NewDB(f *os.File) (DB, error) {
-	fp, err := page.NewDBFirstPage(f)
+	return OpenFile(f, nil)
+}
+
+// OpenFile opens the database file f with opts, which may be nil
+func OpenFile(f *os.File, opts *Options) (DB, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	info, err := f.Stat()
 	if err != nil {
 		return nil, err
 	}
 
-	ipc, err := fp.SQLiteMasterRows.RootIndexPageAndColumnMapByTableNames()
+	fh, err := readFileHeader(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	var p *pager.Pager
+	if opts.Mmap {
+		// failing to map the file, e.g. on a platform without mmap, falls back to pread
+		p, _ = pager.NewMmap(f, info.Size(), uint(fh.PageSize))
+	}
+	if p == nil {
+		p = pager.New(f, info.Size(), uint(fh.PageSize), cacheSize(opts.CacheSize))
+	}
+
+	walFile, err := openWAL(f.Name(), fh, p)
+	if err != nil {
+		return nil, errors.Join(err, p.Close())
+	}
+
+	db, err := newDB(p, fh)
+	if err != nil {
+		if walFile != nil {
+			err = errors.Join(err, walFile.Close())
+		}
+		return nil, err
+	}
+	db.walFile = walFile
+	// PRAGMA database_list reports the path, which is not worth failing to open the database for
+	db.path, _ = filepath.Abs(f.Name())
+	return db, nil
+}
+
+// openWAL reads the -wal file next to the database file path when the database is in WAL mode, making p read
+// the pages the log holds from it. It returns nil when there is no log to read.
+func openWAL(path string, fh *header.FileHeader, p *pager.Pager) (*os.File, error) {
+	if fh.ReadVersion != header.FileVersionWAL {
+		return nil, nil
+	}
+
+	f, err := os.Open(path + "-wal")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+	// an empty log is left once every frame is checkpointed
+	if info.Size() == 0 {
+		return nil, f.Close()
+	}
+
+	// a log without a valid header holds no frames, the database file being read alone
+	w, err := wal.New(f, info.Size())
+	if errors.Is(err, wal.ErrInvalidHeader) {
+		return nil, f.Close()
+	}
+	if err == nil {
+		err = p.SetWAL(w)
+	}
+	if err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+	return f, nil
+}
+
+// OpenBytes opens a database held in memory, e.g. embedded in the binary
+func OpenBytes(b []byte) (DB, error) {
+	return OpenReader(bytes.NewReader(b), int64(len(b)))
+}
+
+// OpenReader opens the database made of the size bytes of r
+func OpenReader(r io.ReaderAt, size int64) (DB, error) {
+	fh, err := readFileHeader(r, size)
 	if err != nil {
 		return nil, err
 	}
+	db, err := newDB(pager.New(r, size, uint(fh.PageSize), pager.DefaultCacheSize), fh)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func cacheSize(n int) int {
+	if n <= 0 {
+		return pager.DefaultCacheSize
+	}
+	return n
+}
+
+func readFileHeader(r io.ReaderAt, size int64) (*header.FileHeader, error) {
+	if size < header.FileHeaderSize {
+		return nil, errors.New("file is not a database")
+	}
+
+	buf := make([]byte, header.FileHeaderSize)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return nil, fmt.Errorf("failed to read the file header: %w", err)
+	}
+
+	fh, _, err := header.NewFileHeader(buf)
+	return fh, err
+}
+
+// newDB reads the schema of the database p reads, whose header is fh, closing p on failure
+func newDB(p *pager.Pager, fh *header.FileHeader) (*sqlite, error) {
+	if err := p.SetReservedBytes(uint(fh.ReservedBytes)); err != nil {
+		return nil, errors.Join(err, p.Close())
+	}
+
+	fp, err := page.NewDBFirstPage(p)
+	if err != nil {
+		return nil, errors.Join(err, p.Close())
+	}
+
+	ipc, err := fp.SQLiteMasterRows.RootIndexPageAndColumnMapByTableNames()
+	if err != nil {
+		return nil, errors.Join(err, p.Close())
+	}
 	return &sqlite{
-		f:          f,
+		pager:      p,
 		pageSize:   uint(fp.PageSize),
 		tablePages: fp.SQLiteMasterRows.RootTablePageMapByTableNames(),
 		indexPages: ipc,
@@ -48,6 +218,30 @@ func (db *sqlite) PageSize() uint {
 	return db.pageSize
 }
 
+func (db *sqlite) UsableSize() uint {
+	return db.pager.UsableSize()
+}
+
+func (db *sqlite) ReservedSpace(pageNum uint) ([]byte, error) {
+	return db.pager.ReservedSpace(pageNum)
+}
+
+func (db *sqlite) CacheStats() pager.Stats {
+	return db.pager.Stats()
+}
+
+func (db *sqlite) Mode() pager.Mode {
+	return db.pager.Mode()
+}
+
+func (db *sqlite) Close() error {
+	err := db.pager.Close()
+	if db.walFile != nil {
+		err = errors.Join(err, db.walFile.Close())
+	}
+	return err
+}
+
 func (db *sqlite) PageNum(table string) (int, error) {
 	p, ok := db.tablePages[table]
 	if !ok {
@@ -56,21 +250,187 @@ func (db *sqlite) PageNum(table string) (int, error) {
 	return p, nil
 }
 
-func (db *sqlite) GetTraverseRootPageNum(table string, where *parser.WhereClause) (int, error) {
-	ipc, ok := db.indexPages[table]
-	if !ok {
-		return db.PageNum(table)
+// indexLookup narrows the rows of a query down to the ones matching an equality term of its WHERE clause,
+// either by looking Key up in Index or by reading RowIDs directly
+type indexLookup struct {
+	Index *schema.IndexPageAndColumns
+	Key   cell.Value
+	// Compare orders the keys of Index
+	Compare eval.Comparator
+	RowIDs  []int
+}
+
+// lookupTerm is a `column = constant` term of a WHERE clause the rows of a table are looked up by
+type lookupTerm struct {
+	column *lookupColumn
+	key    sql.Expr
+}
+
+// getLookupTerm returns the first term of where looking rows up through the row id or an index, nil if there is none
+func (db *sqlite) getLookupTerm(table string, where sql.Expr) (*lookupTerm, error) {
+	terms := parser.NewEqualityTerms(where)
+	if len(terms) == 0 {
+		return nil, nil
 	}
-	if utils.SliceIncludes(ipc.Columns, fmt.Sprintf(`"%s"`, where.Key)) {
-		return ipc.PageNum, nil
+
+	columns, err := db.firstPage.SQLiteMasterRows.GetColumns(table)
+	if err != nil {
+		return nil, err
 	}
-	return db.PageNum(table)
+
+	for _, term := range terms {
+		if term.Table != "" && !strings.EqualFold(term.Table, table) {
+			continue
+		}
+
+		if lc := db.getLookupColumn(table, columns, term.Column); lc != nil {
+			return &lookupTerm{column: lc, key: term.Value}, nil
+		}
+	}
+	return nil, nil
+}
+
+// lookup evaluates the key of the term, whose bound parameters may change between executions
+func (t *lookupTerm) lookup() (*indexLookup, error) {
+	key, err := eval.Eval(t.key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return t.column.lookup(key), nil
+}
+
+// lookupColumn is a column the rows of a table can be looked up by, either the row id or the first column of an index
+type lookupColumn struct {
+	// index is nil for the row id
+	index    *schema.IndexPageAndColumns
+	affinity eval.Affinity
+	// compare orders the keys of index with the collation of the column, which the index must be sorted by for
+	// the comparisons of the column to find their rows in it
+	compare eval.Comparator
+}
+
+// getLookupColumn returns nil when rows of table cannot be looked up by column
+func (db *sqlite) getLookupColumn(table string, columns []*schema.Column, column string) *lookupColumn {
+	var c *schema.Column
+	for _, tc := range columns {
+		if strings.EqualFold(tc.Name, column) {
+			c = tc
+		}
+	}
+
+	if c == nil && isRowIDName(column) || c != nil && c.IsRowIDAlias() {
+		return &lookupColumn{affinity: eval.AffinityInteger}
+	}
+	if c == nil {
+		return nil
+	}
+	collation, err := eval.ParseCollation(c.Collation)
+	if err != nil {
+		return nil
+	}
+
+	for _, index := range db.indexPages[table] {
+		// a partial index misses the rows not matching its WHERE clause
+		if index.Partial || !strings.EqualFold(index.Columns[0], c.Name) {
+			continue
+		}
+		if index.Collations[0] != "" {
+			if indexCollation, err := eval.ParseCollation(index.Collations[0]); err != nil || indexCollation != collation {
+				continue
+			}
+		}
+		compare := db.comparator()
+		compare.Collation = collation
+		return &lookupColumn{index: index, affinity: eval.AffinityFromType(c.Type), compare: compare}
+	}
+	return nil
+}
+
+// lookup returns the lookup of the rows whose column equals key
+func (lc *lookupColumn) lookup(key cell.Value) *indexLookup {
+	// nothing equals NULL
+	if key.IsNull() {
+		return &indexLookup{RowIDs: []int{}}
+	}
+
+	key = lc.affinity.Apply(key)
+	if lc.index != nil {
+		return &indexLookup{Index: lc.index, Key: key, Compare: lc.compare}
+	}
+	if key.Type != cell.ValueTypeInteger {
+		return &indexLookup{RowIDs: []int{}}
+	}
+	return &indexLookup{RowIDs: []int{int(key.Integer)}}
 }
 
 func (db *sqlite) TableCount() uint16 {
-	return db.firstPage.CellCount
+	return uint16(db.firstPage.SQLiteMasterRows.Count(schema.ObjectTypeTable))
+}
+
+func (db *sqlite) FileHeader() *header.FileHeader {
+	return db.firstPage.FileHeader
+}
+
+func (db *sqlite) Schema() schema.SQLiteMasterRows {
+	return db.firstPage.SQLiteMasterRows
 }
 
 func (db *sqlite) Tables() []string {
 	return db.firstPage.SQLiteMasterRows.GetTableNames()
 }
+
+// getCoveringIndex returns the smallest full index of table holding every column where refers to, so that
+// the rows matching where can be counted without reading the table
+func (db *sqlite) getCoveringIndex(table string, where sql.Expr) (*schema.IndexPageAndColumns, error) {
+	indexes := db.indexPages[table]
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+
+	refs, err := parser.ColumnRefs(where)
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := db.firstPage.SQLiteMasterRows.GetColumns(table)
+	if err != nil {
+		return nil, err
+	}
+
+	var covering *schema.IndexPageAndColumns
+	for _, index := range indexes {
+		if index.Partial || !indexCovers(table, columns, index, refs) {
+			continue
+		}
+		if covering == nil || len(index.Columns) < len(covering.Columns) {
+			covering = index
+		}
+	}
+	return covering, nil
+}
+
+func indexCovers(table string, columns []*schema.Column, index *schema.IndexPageAndColumns, refs []*parser.ColumnRef) bool {
+	for _, ref := range refs {
+		if ref.Table != "" && !strings.EqualFold(ref.Table, table) {
+			return false
+		}
+
+		var column *schema.Column
+		for _, c := range columns {
+			if strings.EqualFold(c.Name, ref.Column) {
+				column = c
+			}
+		}
+		// references to the row id are covered by every index, and the others do not resolve either way
+		if column == nil || column.IsRowIDAlias() {
+			continue
+		}
+
+		if !slices.ContainsFunc(index.Columns, func(name string) bool {
+			return strings.EqualFold(name, column.Name)
+		}) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,462 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"github/com/codecrafters-io/sqlite-starter-go/app/cell"
+	"github/com/codecrafters-io/sqlite-starter-go/app/eval"
+	"github/com/codecrafters-io/sqlite-starter-go/app/parser"
+	"github/com/codecrafters-io/sqlite-starter-go/app/schema"
+	"strings"
+
+	"github.com/rqlite/sql"
+)
+
+// Stmt is a prepared SELECT statement. The table, the column positions, the index the rows are looked up by
+// and the slots of the bound parameters are resolved once, the statement being run again with other arguments.
+// A Stmt is not safe for concurrent use, and it cannot be run while the Rows of its previous execution are open.
+type Stmt struct {
+	db      *sqlite
+	ss      *sql.SelectStatement
+	params  *parser.Params
+	columns []*Column
+	// isCount tells whether the statement is a SELECT COUNT(*) that Count accepts
+	isCount bool
+	// distinct holds the collations the result columns of a SELECT DISTINCT compare with, nil without DISTINCT
+	distinct []eval.Collation
+	// lo is shared with the plan and set at every execution, as LIMIT and OFFSET may be bound parameters
+	lo *limitOffset
+	// execute starts reading the rows once the parameters are bound
+	execute func() (*Rows, error)
+	rows    *Rows
+}
+
+// Prepare parses and plans a SELECT or PRAGMA statement
+func (db *sqlite) Prepare(q string) (*Stmt, error) {
+	if parser.IsPragma(q) {
+		return db.preparePragma(q)
+	}
+
+	stmt, err := parser.NewStatement(q)
+	if err != nil {
+		return nil, err
+	}
+
+	ss, err := parser.NewSelectStatement(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ss.Columns) == 0 {
+		return nil, errors.New("no columns found")
+	}
+
+	isCount, err := parser.IsCountStatement(q, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := parser.NewParams(ss)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stmt{
+		db:      db,
+		ss:      ss,
+		params:  params,
+		isCount: isCount,
+		lo:      &limitOffset{limit: -1},
+	}
+	texts := parser.ResultColumnTexts(q, ss)
+	switch {
+	case ss.Source == nil:
+		err = s.prepareValues(texts)
+	case isSingleTable(ss.Source):
+		err = s.prepareTable(texts)
+	default:
+		err = s.prepareJoin(texts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NumInput returns the number of parameters of the statement
+func (s *Stmt) NumInput() int {
+	return len(s.params.List())
+}
+
+// Columns describes the result columns of the statement
+func (s *Stmt) Columns() []*Column {
+	return s.columns
+}
+
+// Query runs the statement with args bound to its parameters
+func (s *Stmt) Query(args ...any) (*Rows, error) {
+	if s.rows != nil && !s.rows.closed {
+		return nil, errors.New("the rows of the previous execution of the statement are not closed")
+	}
+
+	if err := s.params.Bind(args); err != nil {
+		return nil, err
+	}
+
+	lo, err := newLimitOffset(s.ss)
+	if err != nil {
+		return nil, err
+	}
+	*s.lo = *lo
+
+	rows := newRows(noRows, nil)
+	if lo.limit != 0 {
+		if rows, err = s.execute(); err != nil {
+			return nil, err
+		}
+	}
+	rows.columns = s.columns
+	s.rows = rows
+	return rows, nil
+}
+
+// Select runs the statement, reading every row into a result set along with the description of its columns
+func (s *Stmt) Select(args ...any) (*ResultSet, error) {
+	rows, err := s.Query(args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rs := &ResultSet{Columns: rows.Columns(), Rows: make([][]cell.Value, 0)}
+	for rows.Next() {
+		rs.Rows = append(rs.Rows, rows.Values())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// Count runs a SELECT COUNT(*) statement, returning the count
+func (s *Stmt) Count(args ...any) (int, error) {
+	if !s.isCount {
+		return 0, errors.New("invalid count statement")
+	}
+
+	rows, err := s.Query(args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	if rows.Next() {
+		count = int(rows.Values()[0].Integer)
+	}
+	return count, rows.Err()
+}
+
+// prepareTable plans a query reading a single table
+func (s *Stmt) prepareTable(texts []string) error {
+	db := s.db
+	table := strings.ReplaceAll(s.ss.Source.String(), `"`, "")
+	pageNum, err := db.PageNum(table)
+	if err != nil {
+		return err
+	}
+
+	autoIncrPrimaryKeys, err := db.firstPage.SQLiteMasterRows.RowIDAliasColumns(table)
+	if err != nil {
+		return err
+	}
+
+	autoIncrPrimaryKeyPosList, err := db.firstPage.SQLiteMasterRows.GetColumnPosList(table, autoIncrPrimaryKeys)
+	if err != nil {
+		return err
+	}
+
+	st := &ScanTable{
+		PageNum:            uint(pageNum),
+		Table:              table,
+		WhereExpr:          s.ss.WhereExpr,
+		AutoIncrKeyPosList: autoIncrPrimaryKeyPosList,
+	}
+
+	tableColumns, err := db.firstPage.SQLiteMasterRows.GetColumns(table)
+	if err != nil {
+		return err
+	}
+
+	resolve := tableColumnResolver(table, tableColumns)
+	texts, terms, err := s.expandColumns(texts, func(qualifier string) ([]sql.Expr, error) {
+		if qualifier != "" && !strings.EqualFold(qualifier, table) {
+			return nil, fmt.Errorf("no such table: %s", qualifier)
+		}
+		return columnRefs(table, tableColumns), nil
+	}, resolve)
+	if err != nil {
+		return err
+	}
+	ss := s.ss
+
+	if s.columns, err = newColumns(ss.Columns, texts, resolve); err != nil {
+		return err
+	}
+
+	if isRowCount(ss) {
+		return s.prepareCount(st)
+	}
+
+	aq, err := newAggregateQuery(ss, terms, s.lo, s.db.keyComparator(resolve))
+	if err != nil {
+		return err
+	}
+
+	// without ORDER BY rows come in row id order, and reading stops as soon as LIMIT is satisfied. Rows selecting
+	// table columns alone are read without being evaluated.
+	selected, ok := selectedColumns(ss.Columns, tableColumns)
+	if aq == nil && len(terms) == 0 && ok {
+		columnNames := make([]string, len(selected))
+		affinities := make([]eval.Affinity, len(selected))
+		for i, c := range selected {
+			columnNames[i], affinities[i] = c.Name, eval.AffinityFromType(c.Type)
+		}
+		if st.ColumnPosList, err = db.firstPage.SQLiteMasterRows.GetColumnPosList(table, columnNames); err != nil {
+			return err
+		}
+		plan, err := db.newTablePlan(st)
+		if err != nil {
+			return err
+		}
+		s.execute = func() (*Rows, error) {
+			cursor, err := plan.open()
+			if err != nil {
+				return nil, err
+			}
+			return newRows(limitValues(distinctValues(func() ([]cell.Value, error) {
+				c, err := cursor.next()
+				if err != nil || c == nil {
+					return nil, err
+				}
+				values, err := c.Values()
+				if err != nil {
+					return nil, err
+				}
+				for i, v := range values {
+					values[i] = readValue(v, affinities[i])
+				}
+				return values, nil
+			}, s.distinct), s.lo), nil), nil
+		}
+		return nil
+	}
+
+	src, err := db.newTableScan(st)
+	if err != nil {
+		return err
+	}
+	s.prepareSelect(src, aq, terms)
+	return nil
+}
+
+// isRowCount reports whether ss counts the rows of its table matching its WHERE clause and nothing else,
+// which is done without decoding them
+func isRowCount(ss *sql.SelectStatement) bool {
+	if len(ss.Columns) != 1 || ss.Distinct.IsValid() || len(ss.GroupByExprs) > 0 || ss.HavingExpr != nil ||
+		ss.LimitExpr != nil {
+		return false
+	}
+	call, ok := ss.Columns[0].Expr.(*sql.Call)
+	return ok && strings.EqualFold(call.Name.Name, "count") && call.Star.IsValid() && call.Filter == nil &&
+		call.Over == nil
+}
+
+// prepareCount plans counting the rows of st
+func (s *Stmt) prepareCount(st *ScanTable) error {
+	db, table, where := s.db, st.Table, st.WhereExpr
+
+	// a lookup through the row id or an index reads the matching rows alone, which beats any scan
+	term, err := db.getLookupTerm(table, where)
+	if err != nil {
+		return err
+	}
+
+	// otherwise an index holds an entry per row with fewer columns than the table, so it is cheaper to read
+	var index *schema.IndexPageAndColumns
+	if term == nil && st.Lookup == nil {
+		if index, err = db.getCoveringIndex(table, where); err != nil {
+			return err
+		}
+	}
+
+	var count func() (int, error)
+	switch {
+	// simply count cells
+	case where == nil && index != nil:
+		count = func() (int, error) {
+			return db.countBTreeEntries(uint(index.PageNum))
+		}
+	case where == nil:
+		count = func() (int, error) {
+			return db.countBTreeEntries(st.PageNum)
+		}
+	case index != nil:
+		tableColumns, err := db.firstPage.SQLiteMasterRows.GetColumns(table)
+		if err != nil {
+			return err
+		}
+		count = func() (int, error) {
+			count := 0
+			_, err := db.traverseInteriorIndexToVisitRecords(&TraverseIndex{
+				PageNum: uint(index.PageNum),
+				Visit: func(values []cell.Value) (bool, error) {
+					v, err := eval.Eval(where, &indexRow{
+						table:   table,
+						columns: tableColumns,
+						index:   index,
+						enc:     db.firstPage.TextEncoding,
+						values:  values,
+					})
+					if err != nil {
+						return false, err
+					}
+					if eval.IsTrue(v) {
+						count++
+					}
+					return true, nil
+				},
+			})
+			return count, err
+		}
+	default:
+		plan, err := db.newTablePlan(st)
+		if err != nil {
+			return err
+		}
+		count = func() (int, error) {
+			cursor, err := plan.open()
+			if err != nil {
+				return 0, err
+			}
+			count := 0
+			for {
+				c, err := cursor.next()
+				if err != nil || c == nil {
+					return count, err
+				}
+				count++
+			}
+		}
+	}
+
+	s.execute = func() (*Rows, error) {
+		n, err := count()
+		if err != nil {
+			return nil, err
+		}
+		return newRows(singleRow([]cell.Value{cell.IntegerValue(int64(n))}), nil), nil
+	}
+	return nil
+}
+
+// prepareJoin plans a query reading a join of tables, or a table referred to by an alias, by nested loops
+func (s *Stmt) prepareJoin(texts []string) error {
+	j, err := s.db.newJoin(s.ss.Source, s.ss.WhereExpr)
+	if err != nil {
+		return err
+	}
+
+	texts, terms, err := s.expandColumns(texts, j.expandStar, j.resolveColumn)
+	if err != nil {
+		return err
+	}
+	if s.columns, err = newColumns(s.ss.Columns, texts, j.resolveColumn); err != nil {
+		return err
+	}
+
+	aq, err := newAggregateQuery(s.ss, terms, s.lo, s.db.keyComparator(j.resolveColumn))
+	if err != nil {
+		return err
+	}
+	s.prepareSelect(j, aq, terms)
+	return nil
+}
+
+// prepareValues plans a query without FROM clause, whose result columns are evaluated once, or not at all when
+// its WHERE clause is false
+func (s *Stmt) prepareValues(texts []string) error {
+	texts, terms, err := s.expandColumns(texts, func(string) ([]sql.Expr, error) {
+		return nil, errors.New("no tables specified")
+	}, noColumns)
+	if err != nil {
+		return err
+	}
+	if s.columns, err = newColumns(s.ss.Columns, texts, noColumns); err != nil {
+		return err
+	}
+
+	aq, err := newAggregateQuery(s.ss, terms, s.lo, s.db.keyComparator(noColumns))
+	if err != nil {
+		return err
+	}
+	s.prepareSelect(&valuesSource{where: s.ss.WhereExpr, enc: s.db.firstPage.TextEncoding}, aq, terms)
+	return nil
+}
+
+// expandColumns expands the stars of the result columns with expand, the statement being replaced by a copy
+// selecting the expanded columns. It returns the texts of the expanded columns and the ORDER BY terms, which
+// refer to them by number and compare with the collation of the columns resolve resolves them to, as do the
+// result columns of a SELECT DISTINCT.
+func (s *Stmt) expandColumns(texts []string, expand starExpander, resolve columnResolver) ([]string, []*orderingTerm, error) {
+	columns, texts, err := expandStars(s.ss.Columns, texts, expand)
+	if err != nil {
+		return nil, nil, err
+	}
+	ss := *s.ss
+	ss.Columns = columns
+	s.ss = &ss
+
+	compare := s.db.keyComparator(resolve)
+	terms, err := newOrderingTerms(s.ss, compare)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.distinct = nil
+	if ss.Distinct.IsValid() {
+		s.distinct = make([]eval.Collation, len(columns))
+		for i, c := range columns {
+			cmp, err := compare(c.Expr)
+			if err != nil {
+				return nil, nil, err
+			}
+			s.distinct[i] = cmp.Collation
+		}
+	}
+	return texts, terms, nil
+}
+
+// prepareSelect plans reading the result rows from src: grouped when aq is set, sorted when there are ORDER BY
+// terms, and as they are read otherwise
+func (s *Stmt) prepareSelect(src rowSource, aq *aggregateQuery, terms []*orderingTerm) {
+	exprs := make([]sql.Expr, len(s.ss.Columns))
+	for i, c := range s.ss.Columns {
+		exprs[i] = c.Expr
+	}
+
+	switch {
+	case aq != nil:
+		s.execute = func() (*Rows, error) {
+			return selectAggregate(src, aq, s.distinct)
+		}
+	case len(terms) > 0:
+		s.execute = func() (*Rows, error) {
+			return selectSorted(src, exprs, terms, s.distinct, s.lo)
+		}
+	default:
+		s.execute = func() (*Rows, error) {
+			return selectRows(src, exprs, s.distinct, s.lo)
+		}
+	}
+}
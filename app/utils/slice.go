@@ -1,10 +1,5 @@
 package utils
 
-import (
-	"fmt"
-	"strings"
-)
-
 func SliceIncludes[T comparable](slice []T, value T) bool {
 	for _, v := range slice {
 		if v == value {
@@ -13,9 +8,3 @@ func SliceIncludes[T comparable](slice []T, value T) bool {
 	}
 	return false
 }
-
-func PrintRows(rows [][]string) {
-	for _, row := range rows {
-		fmt.Println(strings.Join(row, "|"))
-	}
-}
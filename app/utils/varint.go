@@ -1,39 +1,63 @@
 package utils
 
-import (
-	"os"
-)
+import "fmt"
 
 const (
 	maxVarIntSize = 9
 )
 
-// Uvarint decodes Big-endian bytes to uint64
+// Uvarint decodes a Big-endian varint at the start of buf, returning the number of bytes it spans.
+// A varint spans at most nine bytes, the ninth one holding 8 bits.
 func Uvarint(buf []byte) (uint64, int) {
-	var result int64
-	var bytesRead int
-
-	for bytesRead < len(buf) {
-		b := buf[bytesRead : bytesRead+1][0]
+	var result uint64
+	for i := 0; i < len(buf) && i < maxVarIntSize; i++ {
+		b := buf[i]
+		if i == maxVarIntSize-1 {
+			return result<<8 | uint64(b), maxVarIntSize
+		}
 
-		bytesRead++
+		result = result<<7 | uint64(b&0x7F)
+		if b&0x80 == 0 {
+			return result, i + 1
+		}
+	}
+	return result, min(len(buf), maxVarIntSize)
+}
 
-		result = (result << 7) | int64(b&0x7F)
+// AppendUvarint appends v to buf as a Big-endian varint, the inverse of Uvarint
+func AppendUvarint(buf []byte, v uint64) []byte {
+	// the ninth byte of a varint holds 8 bits
+	if v > 1<<56-1 {
+		var b [maxVarIntSize]byte
+		b[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			b[i] = byte(v&0x7F) | 0x80
+			v >>= 7
+		}
+		return append(buf, b[:]...)
+	}
 
-		if b&0x80 == 0 {
+	var b [maxVarIntSize - 1]byte
+	n := len(b)
+	for {
+		n--
+		b[n] = byte(v&0x7F) | 0x80
+		v >>= 7
+		if v == 0 {
 			break
 		}
 	}
-
-	return uint64(result), bytesRead
+	b[len(b)-1] &= 0x7F
+	return append(buf, b[n:]...)
 }
 
-func ReadUvarint(f *os.File, offset int64) (uint64, int, error) {
-	buf := make([]byte, maxVarIntSize)
-	if _, err := f.ReadAt(buf, offset); err != nil {
-		return 0, 0, err
+// ReadUvarint decodes the varint at offset in buf, e.g. a page
+func ReadUvarint(buf []byte, offset int64) (uint64, int, error) {
+	if offset < 0 || offset >= int64(len(buf)) {
+		return 0, 0, fmt.Errorf("varint offset %d is out of bounds", offset)
 	}
 
-	uv, read := Uvarint(buf)
+	uv, read := Uvarint(buf[offset:])
 	return uv, read, nil
 }
@@ -0,0 +1,160 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	HeaderSize      = 32
+	FrameHeaderSize = 24
+
+	// the least significant bit of the magic number tells whether checksums are computed on big-endian words
+	magicLittleEndian = 0x377f0682
+	magicBigEndian    = 0x377f0683
+	formatVersion     = 3007000
+)
+
+// ErrInvalidHeader is returned by New when the log does not start with a valid header, which SQLite reads as
+// an empty log
+var ErrInvalidHeader = errors.New("invalid write-ahead log header")
+
+// Header is the header of a write-ahead log. See https://www.sqlite.org/fileformat.html#wal_file_format
+type Header struct {
+	PageSize           uint32
+	CheckpointSequence uint32
+	Salt1              uint32
+	Salt2              uint32
+	bigEndian          bool
+}
+
+// WAL is the write-ahead log of a database, holding the pages changed by the transactions not yet
+// checkpointed into the database file
+type WAL struct {
+	r io.ReaderAt
+	*Header
+	// frames is the wal-index, the offset of the content of the latest committed frame of every page
+	frames map[uint]int64
+	// dbSize is the size of the database in pages as of the last commit
+	dbSize uint
+}
+
+// New reads the write-ahead log made of the size bytes of r, indexing the frames of the committed transactions.
+// Reading stops at the first frame whose salts or checksum do not match, as SQLite does, since frames past it
+// are left from an earlier generation of the log or were never fully written. The error wraps ErrInvalidHeader
+// when the header is not valid.
+func New(r io.ReaderAt, size int64) (*WAL, error) {
+	if size < HeaderSize {
+		return nil, fmt.Errorf("%w: the log is too short for it", ErrInvalidHeader)
+	}
+
+	buf := make([]byte, HeaderSize)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return nil, fmt.Errorf("failed to read the write-ahead log header: %w", err)
+	}
+
+	h, err := newHeader(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WAL{r: r, Header: h, frames: make(map[uint]int64)}
+	s0, s1 := binary.BigEndian.Uint32(buf[24:28]), binary.BigEndian.Uint32(buf[28:32])
+
+	pending := make(map[uint]int64)
+	frame := make([]byte, FrameHeaderSize+int(h.PageSize))
+	for offset := int64(HeaderSize); offset+int64(len(frame)) <= size; offset += int64(len(frame)) {
+		if _, err := r.ReadAt(frame, offset); err != nil {
+			return nil, fmt.Errorf("failed to read the write-ahead log frame at offset %d: %w", offset, err)
+		}
+
+		pageNum := binary.BigEndian.Uint32(frame[0:4])
+		dbSize := binary.BigEndian.Uint32(frame[4:8])
+		if pageNum == 0 || binary.BigEndian.Uint32(frame[8:12]) != h.Salt1 || binary.BigEndian.Uint32(frame[12:16]) != h.Salt2 {
+			break
+		}
+
+		s0, s1 = checksum(h.bigEndian, s0, s1, frame[0:8])
+		s0, s1 = checksum(h.bigEndian, s0, s1, frame[FrameHeaderSize:])
+		if s0 != binary.BigEndian.Uint32(frame[16:20]) || s1 != binary.BigEndian.Uint32(frame[20:24]) {
+			break
+		}
+
+		pending[uint(pageNum)] = offset + FrameHeaderSize
+		// a frame with the database size set commits the transaction made of it and the frames before it
+		if dbSize != 0 {
+			for n, off := range pending {
+				w.frames[n] = off
+			}
+			clear(pending)
+			w.dbSize = uint(dbSize)
+		}
+	}
+	return w, nil
+}
+
+func newHeader(buf []byte) (*Header, error) {
+	magic := binary.BigEndian.Uint32(buf[0:4])
+	if magic != magicLittleEndian && magic != magicBigEndian {
+		return nil, fmt.Errorf("%w: magic number %#x", ErrInvalidHeader, magic)
+	}
+
+	if version := binary.BigEndian.Uint32(buf[4:8]); version != formatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrInvalidHeader, version)
+	}
+
+	h := &Header{
+		PageSize:           binary.BigEndian.Uint32(buf[8:12]),
+		CheckpointSequence: binary.BigEndian.Uint32(buf[12:16]),
+		Salt1:              binary.BigEndian.Uint32(buf[16:20]),
+		Salt2:              binary.BigEndian.Uint32(buf[20:24]),
+		bigEndian:          magic == magicBigEndian,
+	}
+	if h.PageSize < 512 || h.PageSize > 65536 || h.PageSize&(h.PageSize-1) != 0 {
+		return nil, fmt.Errorf("%w: page size %d", ErrInvalidHeader, h.PageSize)
+	}
+
+	s0, s1 := checksum(h.bigEndian, 0, 0, buf[0:24])
+	if s0 != binary.BigEndian.Uint32(buf[24:28]) || s1 != binary.BigEndian.Uint32(buf[28:32]) {
+		return nil, fmt.Errorf("%w: checksum does not match", ErrInvalidHeader)
+	}
+	return h, nil
+}
+
+// checksum continues the checksum s0, s1 over b, whose length is a multiple of 8
+func checksum(bigEndian bool, s0, s1 uint32, b []byte) (uint32, uint32) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	for i := 0; i+8 <= len(b); i += 8 {
+		s0 += order.Uint32(b[i:]) + s1
+		s1 += order.Uint32(b[i+4:]) + s0
+	}
+	return s0, s1
+}
+
+// DBSize returns the size of the database in pages as of the last commit, 0 when no transaction is committed
+func (w *WAL) DBSize() uint {
+	return w.dbSize
+}
+
+// HasPage tells whether a committed transaction of the log holds page pageNum
+func (w *WAL) HasPage(pageNum uint) bool {
+	_, ok := w.frames[pageNum]
+	return ok
+}
+
+// ReadPage reads the latest committed content of page pageNum into buf
+func (w *WAL) ReadPage(pageNum uint, buf []byte) error {
+	offset, ok := w.frames[pageNum]
+	if !ok {
+		return fmt.Errorf("page %d is not in the write-ahead log", pageNum)
+	}
+	if _, err := w.r.ReadAt(buf, offset); err != nil {
+		return fmt.Errorf("failed to read page %d from the write-ahead log: %w", pageNum, err)
+	}
+	return nil
+}
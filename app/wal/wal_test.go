@@ -0,0 +1,168 @@
+package wal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"testing"
+)
+
+const testPageSize = 512
+
+// testFrame is a frame of a test log, whose page is filled with fill
+type testFrame struct {
+	page   uint32
+	dbSize uint32
+	fill   byte
+	// staleSalt gives the frame the salts of an earlier generation of the log, and badChecksum a wrong checksum
+	staleSalt   bool
+	badChecksum bool
+}
+
+// newTestLog writes a log made of frames, chaining their checksums from the header the way SQLite does
+func newTestLog(bigEndian bool, frames []testFrame) []byte {
+	magic := uint32(magicLittleEndian)
+	if bigEndian {
+		magic = magicBigEndian
+	}
+	const salt1, salt2 = 0x11223344, 0x55667788
+
+	header := make([]byte, HeaderSize)
+	binary.BigEndian.PutUint32(header[0:4], magic)
+	binary.BigEndian.PutUint32(header[4:8], formatVersion)
+	binary.BigEndian.PutUint32(header[8:12], testPageSize)
+	binary.BigEndian.PutUint32(header[16:20], salt1)
+	binary.BigEndian.PutUint32(header[20:24], salt2)
+	s0, s1 := checksum(bigEndian, 0, 0, header[0:24])
+	binary.BigEndian.PutUint32(header[24:28], s0)
+	binary.BigEndian.PutUint32(header[28:32], s1)
+
+	log := bytes.NewBuffer(header)
+	for _, f := range frames {
+		frame := make([]byte, FrameHeaderSize+testPageSize)
+		binary.BigEndian.PutUint32(frame[0:4], f.page)
+		binary.BigEndian.PutUint32(frame[4:8], f.dbSize)
+		binary.BigEndian.PutUint32(frame[8:12], salt1)
+		binary.BigEndian.PutUint32(frame[12:16], salt2)
+		if f.staleSalt {
+			binary.BigEndian.PutUint32(frame[8:12], salt1+1)
+		}
+		copy(frame[FrameHeaderSize:], bytes.Repeat([]byte{f.fill}, testPageSize))
+
+		s0, s1 = checksum(bigEndian, s0, s1, frame[0:8])
+		s0, s1 = checksum(bigEndian, s0, s1, frame[FrameHeaderSize:])
+		binary.BigEndian.PutUint32(frame[16:20], s0)
+		binary.BigEndian.PutUint32(frame[20:24], s1)
+		if f.badChecksum {
+			frame[19] ^= 0xff
+		}
+		log.Write(frame)
+	}
+	return log.Bytes()
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		bigEndian bool
+		frames    []testFrame
+		// want maps the pages read from the log to the byte they are filled with
+		want       map[uint]byte
+		wantDBSize uint
+	}{
+		{name: "empty", want: map[uint]byte{}},
+		{
+			name:       "committed transaction",
+			frames:     []testFrame{{page: 1, fill: 'a'}, {page: 2, dbSize: 2, fill: 'b'}},
+			want:       map[uint]byte{1: 'a', 2: 'b'},
+			wantDBSize: 2,
+		},
+		{
+			name:       "big-endian checksums",
+			bigEndian:  true,
+			frames:     []testFrame{{page: 1, fill: 'a'}, {page: 2, dbSize: 2, fill: 'b'}},
+			want:       map[uint]byte{1: 'a', 2: 'b'},
+			wantDBSize: 2,
+		},
+		{
+			name:       "uncommitted frames",
+			frames:     []testFrame{{page: 1, dbSize: 1, fill: 'a'}, {page: 1, fill: 'b'}, {page: 2, fill: 'c'}},
+			want:       map[uint]byte{1: 'a'},
+			wantDBSize: 1,
+		},
+		{
+			name:       "later commit",
+			frames:     []testFrame{{page: 1, dbSize: 1, fill: 'a'}, {page: 2, fill: 'b'}, {page: 1, dbSize: 3, fill: 'c'}},
+			want:       map[uint]byte{1: 'c', 2: 'b'},
+			wantDBSize: 3,
+		},
+		{
+			name: "bad checksum",
+			frames: []testFrame{
+				{page: 1, dbSize: 1, fill: 'a'},
+				{page: 1, dbSize: 1, fill: 'b', badChecksum: true},
+				{page: 2, dbSize: 2, fill: 'c'},
+			},
+			want:       map[uint]byte{1: 'a'},
+			wantDBSize: 1,
+		},
+		{
+			name:       "stale salt",
+			frames:     []testFrame{{page: 1, dbSize: 1, fill: 'a'}, {page: 2, dbSize: 2, fill: 'b', staleSalt: true}},
+			want:       map[uint]byte{1: 'a'},
+			wantDBSize: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := newTestLog(tt.bigEndian, tt.frames)
+			w, err := New(bytes.NewReader(log), int64(len(log)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if w.DBSize() != tt.wantDBSize {
+				t.Errorf("DBSize() = %d, want %d", w.DBSize(), tt.wantDBSize)
+			}
+			got := make(map[uint]byte)
+			buf := make([]byte, testPageSize)
+			for page := uint(1); page <= 3; page++ {
+				if !w.HasPage(page) {
+					continue
+				}
+				if err := w.ReadPage(page, buf); err != nil {
+					t.Fatal(err)
+				}
+				got[page] = buf[0]
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("pages %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewInvalidHeader(t *testing.T) {
+	valid := newTestLog(false, []testFrame{{page: 1, dbSize: 1}})
+	tests := []struct {
+		name   string
+		offset int
+	}{
+		{name: "magic number", offset: 0},
+		{name: "format version", offset: 7},
+		{name: "page size", offset: 10},
+		{name: "checksum", offset: 27},
+	}
+	for _, tt := range tests {
+		log := bytes.Clone(valid)
+		log[tt.offset] ^= 0x01
+		if _, err := New(bytes.NewReader(log), int64(len(log))); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("%s: New() = %v, want %v", tt.name, err, ErrInvalidHeader)
+		}
+	}
+
+	if _, err := New(bytes.NewReader(valid), HeaderSize-1); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("short log: New() = %v, want %v", err, ErrInvalidHeader)
+	}
+}
@@ -10,9 +10,9 @@ module github/com/codecrafters-io/sqlite-starter-go
 
 go 1.22
 
-require github.com/xwb1989/sqlparser v0.0.0-20180606152119-120387863bf2
-
 require (
-	github.com/rqlite/sql v0.0.0-20240312185922-ffac88a740bd // indirect
-	golang.org/x/sync v0.8.0 // indirect
+	github.com/rqlite/sql v0.0.0-20240312185922-ffac88a740bd
+	github.com/xwb1989/sqlparser v0.0.0-20180606152119-120387863bf2
 )
+
+require golang.org/x/sync v0.8.0 // indirect